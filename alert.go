@@ -0,0 +1,225 @@
+package evaluator
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// AlertState is the state of AlertCondition.
+type AlertState int
+
+// The states of AlertCondition
+const (
+	// AlertOK is the state where the trigger condition is not satisfied.
+	AlertOK AlertState = iota
+	// AlertPending is the state where the trigger condition is satisfied, but it has not held long enough.
+	AlertPending
+	// AlertFiring is the state where the trigger condition has held, it lasts until the recover condition is satisfied.
+	AlertFiring
+	// AlertResolved is the state just after the recover condition is satisfied, it turns into AlertOK or AlertPending by the next evaluation.
+	AlertResolved
+)
+
+func (s AlertState) String() string {
+	switch s {
+	case AlertOK:
+		return "OK"
+	case AlertPending:
+		return "Pending"
+	case AlertFiring:
+		return "Firing"
+	case AlertResolved:
+		return "Resolved"
+	default:
+		return fmt.Sprintf("AlertState(%d)", int(s))
+	}
+}
+
+// AlertTransition is the change of the state of AlertCondition.
+type AlertTransition struct {
+	From AlertState
+	To   AlertState
+	// At is the time of the evaluation which changed the state.
+	At time.Time
+}
+
+// AlertOption is an option of AlertCondition.
+type AlertOption func(*AlertCondition)
+
+// WithRecoverCondition sets the condition to leave AlertFiring, the default is that the trigger condition is not satisfied.
+// The separate condition gives the hysteresis, like `cpu > 90` to trigger and `cpu < 80` to recover.
+func WithRecoverCondition(c Comparator) AlertOption {
+	return func(a *AlertCondition) {
+		a.recover = c
+	}
+}
+
+// WithForEvaluations sets the number of the consecutive evaluations the trigger condition must hold to fire.
+func WithForEvaluations(n int) AlertOption {
+	return func(a *AlertCondition) {
+		a.forEvaluations = n
+	}
+}
+
+// WithForDuration sets the duration the trigger condition must hold to fire.
+func WithForDuration(d time.Duration) AlertOption {
+	return func(a *AlertCondition) {
+		a.forDuration = d
+	}
+}
+
+// WithClock sets the function which returns the current time, the default is time.Now.
+func WithClock(now func() time.Time) AlertOption {
+	return func(a *AlertCondition) {
+		a.now = now
+	}
+}
+
+// WithTransitionHandler sets the function called for each transition of the state.
+func WithTransitionHandler(fn func(AlertTransition)) AlertOption {
+	return func(a *AlertCondition) {
+		a.onTransition = fn
+	}
+}
+
+// WithExprOptions sets the options of New used by ParseAlertCondition to parse the expressions.
+func WithExprOptions(opts ...Option) AlertOption {
+	return func(a *AlertCondition) {
+		a.exprOpts = opts
+	}
+}
+
+// AlertCondition is the state machine of an alert built on Comparator.
+// The state is AlertOK at first, and it turns into AlertPending when the trigger condition is satisfied,
+// and into AlertFiring when the trigger condition has held for the evaluations and the duration given by the options.
+// Without the options it fires at once. AlertFiring lasts until the recover condition is satisfied.
+type AlertCondition struct {
+	mu             sync.Mutex
+	trigger        Comparator
+	recover        Comparator
+	forEvaluations int
+	forDuration    time.Duration
+	now            func() time.Time
+	onTransition   func(AlertTransition)
+	exprOpts       []Option
+
+	state        AlertState
+	since        time.Time
+	pendingCount int
+}
+
+// NewAlertCondition creates an AlertCondition of the trigger condition.
+func NewAlertCondition(trigger Comparator, opts ...AlertOption) *AlertCondition {
+	a := &AlertCondition{
+		trigger: trigger,
+		now:     time.Now,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	a.since = a.now()
+	return a
+}
+
+// ParseAlertCondition parses the trigger and the recover expressions to create an AlertCondition, recover can be empty.
+func ParseAlertCondition(trigger string, recover string, opts ...AlertOption) (*AlertCondition, error) {
+	parsing := &AlertCondition{}
+	for _, opt := range opts {
+		opt(parsing)
+	}
+	t, err := newComparator(trigger, parsing.exprOpts...)
+	if err != nil {
+		return nil, err
+	}
+	if recover != "" {
+		r, err := newComparator(recover, parsing.exprOpts...)
+		if err != nil {
+			return nil, err
+		}
+		opts = append([]AlertOption{WithRecoverCondition(r)}, opts...)
+	}
+	return NewAlertCondition(t, opts...), nil
+}
+
+func newComparator(expr string, opts ...Option) (Comparator, error) {
+	e, err := New(expr, opts...)
+	if err != nil {
+		return nil, err
+	}
+	c, ok := e.AsComparator()
+	if !ok {
+		return nil, fmt.Errorf("`%s` is not comparator", e)
+	}
+	return c, nil
+}
+
+// State returns the current state.
+func (a *AlertCondition) State() AlertState {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.state
+}
+
+// Since returns the time when the state changed last.
+func (a *AlertCondition) Since() time.Time {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.since
+}
+
+// Evaluate evaluates the conditions and returns the new state.
+// If the evaluation fails, the state is not changed.
+// The transition handler is called after the state is changed, it can call the methods of the AlertCondition.
+func (a *AlertCondition) Evaluate(vars Variables) (AlertState, error) {
+	a.mu.Lock()
+	now := a.now()
+	from := a.state
+	to, err := a.evaluate(vars, now)
+	if to != from {
+		a.state, a.since = to, now
+	}
+	at := a.since
+	a.mu.Unlock()
+	if to != from && a.onTransition != nil {
+		a.onTransition(AlertTransition{From: from, To: to, At: at})
+	}
+	return to, err
+}
+
+func (a *AlertCondition) evaluate(vars Variables, now time.Time) (AlertState, error) {
+	if a.state == AlertFiring {
+		recovered, err := a.recovered(vars)
+		if err != nil || !recovered {
+			return a.state, err
+		}
+		return AlertResolved, nil
+	}
+	triggered, err := a.trigger.Compare(vars)
+	if err != nil {
+		return a.state, err
+	}
+	if !triggered {
+		a.pendingCount = 0
+		return AlertOK, nil
+	}
+	pendingSince := now
+	if a.state == AlertPending {
+		pendingSince = a.since
+		a.pendingCount++
+	} else {
+		a.pendingCount = 1
+	}
+	if a.pendingCount >= a.forEvaluations && now.Sub(pendingSince) >= a.forDuration {
+		return AlertFiring, nil
+	}
+	return AlertPending, nil
+}
+
+func (a *AlertCondition) recovered(vars Variables) (bool, error) {
+	if a.recover != nil {
+		return a.recover.Compare(vars)
+	}
+	triggered, err := a.trigger.Compare(vars)
+	return !triggered, err
+}
@@ -0,0 +1,106 @@
+package evaluator_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mashiike/evaluator"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func TestAlertCondition(t *testing.T) {
+	cases := []struct {
+		name     string
+		trigger  string
+		recover  string
+		opts     []evaluator.AlertOption
+		cpu      []float64
+		expected []evaluator.AlertState
+	}{
+		{
+			name:     "fires at once",
+			trigger:  "cpu > 90",
+			cpu:      []float64{50, 95, 95, 50, 50},
+			expected: []evaluator.AlertState{evaluator.AlertOK, evaluator.AlertFiring, evaluator.AlertFiring, evaluator.AlertResolved, evaluator.AlertOK},
+		},
+		{
+			name:     "hysteresis",
+			trigger:  "cpu > 90",
+			recover:  "cpu < 80",
+			cpu:      []float64{95, 85, 89, 79, 95},
+			expected: []evaluator.AlertState{evaluator.AlertFiring, evaluator.AlertFiring, evaluator.AlertFiring, evaluator.AlertResolved, evaluator.AlertFiring},
+		},
+		{
+			name:     "for evaluations",
+			trigger:  "cpu > 90",
+			opts:     []evaluator.AlertOption{evaluator.WithForEvaluations(3)},
+			cpu:      []float64{95, 95, 50, 95, 95, 95, 95},
+			expected: []evaluator.AlertState{evaluator.AlertPending, evaluator.AlertPending, evaluator.AlertOK, evaluator.AlertPending, evaluator.AlertPending, evaluator.AlertFiring, evaluator.AlertFiring},
+		},
+		{
+			name:     "for duration",
+			trigger:  "cpu > 90",
+			opts:     []evaluator.AlertOption{evaluator.WithForDuration(2 * time.Minute)},
+			cpu:      []float64{95, 95, 95, 50},
+			expected: []evaluator.AlertState{evaluator.AlertPending, evaluator.AlertPending, evaluator.AlertFiring, evaluator.AlertResolved},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			clock := &fakeClock{now: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)}
+			a, err := evaluator.ParseAlertCondition(c.trigger, c.recover, append(c.opts, evaluator.WithClock(clock.Now))...)
+			require.NoError(t, err)
+			require.Equal(t, evaluator.AlertOK, a.State())
+			actual := make([]evaluator.AlertState, 0, len(c.cpu))
+			for _, cpu := range c.cpu {
+				state, err := a.Evaluate(evaluator.Variables{"cpu": cpu})
+				require.NoError(t, err)
+				actual = append(actual, state)
+				clock.now = clock.now.Add(time.Minute)
+			}
+			require.Equal(t, c.expected, actual)
+		})
+	}
+}
+
+func TestAlertConditionTransitions(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)}
+	start := clock.now
+	var transitions []evaluator.AlertTransition
+	var a *evaluator.AlertCondition
+	a, err := evaluator.ParseAlertCondition("errors > 0", "", evaluator.WithClock(clock.Now), evaluator.WithForEvaluations(2),
+		evaluator.WithTransitionHandler(func(tr evaluator.AlertTransition) {
+			require.Equal(t, tr.To, a.State(), "the handler can call the methods")
+			transitions = append(transitions, tr)
+		}),
+	)
+	require.NoError(t, err)
+	for _, errors := range []int{1, 1, 1, 0, 0} {
+		_, err := a.Evaluate(evaluator.Variables{"errors": errors})
+		require.NoError(t, err)
+		clock.now = clock.now.Add(time.Minute)
+	}
+	require.Equal(t, []evaluator.AlertTransition{
+		{From: evaluator.AlertOK, To: evaluator.AlertPending, At: start},
+		{From: evaluator.AlertPending, To: evaluator.AlertFiring, At: start.Add(time.Minute)},
+		{From: evaluator.AlertFiring, To: evaluator.AlertResolved, At: start.Add(3 * time.Minute)},
+		{From: evaluator.AlertResolved, To: evaluator.AlertOK, At: start.Add(4 * time.Minute)},
+	}, transitions)
+	require.Equal(t, start.Add(4*time.Minute), a.Since())
+
+	_, err = a.Evaluate(evaluator.Variables{"errors": "a"})
+	require.Error(t, err)
+	require.Equal(t, evaluator.AlertOK, a.State(), "the state is not changed by the error")
+
+	_, err = evaluator.ParseAlertCondition("errors + 1", "", evaluator.WithClock(clock.Now))
+	require.EqualError(t, err, "`errors + 1` is not comparator")
+	require.Equal(t, "Firing", evaluator.AlertFiring.String())
+}
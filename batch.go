@@ -0,0 +1,379 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+	"go/token"
+	"sort"
+)
+
+// EvalBatch evaluates the expression for n rows given as the columns of the variables, and returns the result of each row.
+// The arithmetic, comparative, logical and unary expressions of numbers are evaluated column at a time,
+// the other expressions fall back to the evaluation of each row with the same results as Eval.
+func EvalBatch(e Evaluator, columns map[string][]float64, n int) ([]interface{}, error) {
+	if err := checkBatchColumns(columns, n); err != nil {
+		return nil, err
+	}
+	if v, ok := evalVector(e, columns, n); ok {
+		ret := make([]interface{}, n)
+		for i := 0; i < n; i++ {
+			if v.nums != nil {
+				ret[i] = v.nums[i]
+			} else {
+				ret[i] = v.bools[i]
+			}
+		}
+		return ret, nil
+	}
+	ret := make([]interface{}, n)
+	err := evalRows(e, columns, n, func(i int, v interface{}) error {
+		ret[i] = v
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// EvalBatchFloat64 is EvalBatch for the expression whose results are numbers.
+func EvalBatchFloat64(e Evaluator, columns map[string][]float64, n int) ([]float64, error) {
+	if err := checkBatchColumns(columns, n); err != nil {
+		return nil, err
+	}
+	if v, ok := evalVector(e, columns, n); ok && v.nums != nil {
+		return v.nums, nil
+	}
+	ret := make([]float64, n)
+	err := evalRows(e, columns, n, func(i int, v interface{}) error {
+		f, ok := isRealNumber(v)
+		if !ok {
+			return fmt.Errorf("Eval(`%s`) v[%v]::%T is not number", e, v, v)
+		}
+		ret[i] = f
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// EvalBatchBool is EvalBatch for the expression whose results are bools.
+func EvalBatchBool(e Evaluator, columns map[string][]float64, n int) ([]bool, error) {
+	if err := checkBatchColumns(columns, n); err != nil {
+		return nil, err
+	}
+	if v, ok := evalVector(e, columns, n); ok && v.bools != nil {
+		return v.bools, nil
+	}
+	ret := make([]bool, n)
+	err := evalRows(e, columns, n, func(i int, v interface{}) error {
+		b, ok := isBool(v)
+		if !ok {
+			return fmt.Errorf("Eval(`%s`) v[%v]::%T is not bool", e, v, v)
+		}
+		ret[i] = b
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func checkBatchColumns(columns map[string][]float64, n int) error {
+	if n < 0 {
+		return fmt.Errorf("EvalBatch n must be non-negative, but given %d", n)
+	}
+	names := make([]string, 0, len(columns))
+	for name := range columns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if len(columns[name]) < n {
+			return fmt.Errorf("EvalBatch column `%s` has %d values, but n is %d", name, len(columns[name]), n)
+		}
+	}
+	return nil
+}
+
+// evalRows evaluates each row with the variables which is reused between the rows.
+func evalRows(e Evaluator, columns map[string][]float64, n int, fn func(int, interface{}) error) error {
+	referenced := make(map[string][]float64, len(columns))
+	for _, name := range ReferencedVariables(e) {
+		if column, ok := columns[name]; ok {
+			referenced[name] = column
+		}
+	}
+	vars := make(Variables, len(referenced))
+	for i := 0; i < n; i++ {
+		for name, column := range referenced {
+			vars[name] = column[i]
+		}
+		v, err := e.Eval(vars)
+		if err == nil {
+			err = fn(i, v)
+		}
+		if err != nil {
+			return fmt.Errorf("EvalBatch row %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// vector is the values of a node for all rows, either nums or bools is set.
+type vector struct {
+	nums  []float64
+	bools []bool
+}
+
+// errNotVectorizable is returned when the node or the values can not be evaluated as the vector,
+// for example the division by zero, in which case the rows are evaluated one by one to report the same error as Eval.
+var errNotVectorizable = errors.New("not vectorizable")
+
+func evalVector(e Evaluator, columns map[string][]float64, n int) (vector, bool) {
+	v, err := vectorize(e, columns, n)
+	return v, err == nil
+}
+
+func vectorize(e Evaluator, columns map[string][]float64, n int) (vector, error) {
+	switch e := e.(type) {
+	case *realNumericLiteralEvaluator:
+		nums := make([]float64, n)
+		for i := range nums {
+			nums[i] = e.value
+		}
+		return vector{nums: nums}, nil
+	case *lockupVariableEvaluator:
+		column, ok := columns[e.name]
+		if !ok {
+			return vector{}, errNotVectorizable
+		}
+		nums := make([]float64, n)
+		copy(nums, column)
+		return vector{nums: nums}, nil
+	case *parenEvaluator:
+		return vectorize(e.x, columns, n)
+	case *budgetEvaluator:
+		// the vectorized nodes cost only the static cost, the rows are evaluated to fail if it exceeds the budget
+		if e.cost > e.budget {
+			return vector{}, errNotVectorizable
+		}
+		return vectorize(e.x, columns, n)
+	case *recoverEvaluator:
+		return vectorizeRecover(e.x, columns, n)
+	case *unaryEvaluator:
+		x, err := vectorize(e.x, columns, n)
+		if err != nil {
+			return vector{}, err
+		}
+		return vectorizeUnary(e.op, x)
+	case *computableEvaluator:
+		x, y, err := vectorizeBoth(e.x, e.y, columns, n)
+		if err != nil {
+			return vector{}, err
+		}
+		return vectorizeComputable(e.op, x, y)
+	case *comparativeEvaluator:
+		x, y, err := vectorizeBoth(e.x, e.y, columns, n)
+		if err != nil {
+			return vector{}, err
+		}
+		return vectorizeComparative(e.op, x, y)
+	case *logicalEvaluator:
+		x, y, err := vectorizeBoth(e.x, e.y, columns, n)
+		if err != nil {
+			return vector{}, err
+		}
+		if x.bools == nil || y.bools == nil {
+			return vector{}, errNotVectorizable
+		}
+		bools := make([]bool, n)
+		switch e.op {
+		case token.LAND.String():
+			for i := range bools {
+				bools[i] = x.bools[i] && y.bools[i]
+			}
+		case token.LOR.String():
+			for i := range bools {
+				bools[i] = x.bools[i] || y.bools[i]
+			}
+		default:
+			return vector{}, errNotVectorizable
+		}
+		return vector{bools: bools}, nil
+	case *callEvaluator:
+		// the functions which take numbers and return a number are vectorized.
+		// The rows whose result is not a number, like nil of rate() by zero, fall back to the evaluation of each row.
+		if f, ok := getBuiltinFunc(e.funcName); !ok || !f.numeric {
+			return vector{}, errNotVectorizable
+		}
+		args := make([]vector, 0, len(e.args))
+		for _, arg := range e.args {
+			v, err := vectorize(arg, columns, n)
+			if err != nil {
+				return vector{}, err
+			}
+			if v.nums == nil {
+				return vector{}, errNotVectorizable
+			}
+			args = append(args, v)
+		}
+		nums := make([]float64, n)
+		if e.funcName == "rate" {
+			for i := range nums {
+				if args[1].nums[i] == 0 {
+					return vector{}, errNotVectorizable
+				}
+				nums[i] = args[0].nums[i] / args[1].nums[i]
+			}
+			return vector{nums: nums}, nil
+		}
+		values := make([]interface{}, len(args))
+		for i := range nums {
+			for j, arg := range args {
+				values[j] = arg.nums[i]
+			}
+			ret, err := e.f(values...)
+			if err != nil {
+				return vector{}, errNotVectorizable
+			}
+			f, ok := ret.(float64)
+			if !ok {
+				return vector{}, errNotVectorizable
+			}
+			nums[i] = f
+		}
+		return vector{nums: nums}, nil
+	default:
+		return vector{}, errNotVectorizable
+	}
+}
+
+// vectorizeRecover falls back to the evaluation of each row on a panic, in which the panic is converted into PanicError by Recover.
+func vectorizeRecover(e Evaluator, columns map[string][]float64, n int) (v vector, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			v, err = vector{}, errNotVectorizable
+		}
+	}()
+	return vectorize(e, columns, n)
+}
+
+func vectorizeBoth(x, y Evaluator, columns map[string][]float64, n int) (vector, vector, error) {
+	vx, err := vectorize(x, columns, n)
+	if err != nil {
+		return vector{}, vector{}, err
+	}
+	vy, err := vectorize(y, columns, n)
+	if err != nil {
+		return vector{}, vector{}, err
+	}
+	return vx, vy, nil
+}
+
+func vectorizeUnary(op string, x vector) (vector, error) {
+	switch {
+	case op == token.SUB.String() && x.nums != nil:
+		nums := make([]float64, len(x.nums))
+		for i, v := range x.nums {
+			nums[i] = -v
+		}
+		return vector{nums: nums}, nil
+	case op == token.ADD.String() && x.nums != nil:
+		return x, nil
+	case op == token.NOT.String() && x.bools != nil:
+		bools := make([]bool, len(x.bools))
+		for i, v := range x.bools {
+			bools[i] = !v
+		}
+		return vector{bools: bools}, nil
+	default:
+		return vector{}, errNotVectorizable
+	}
+}
+
+func vectorizeComputable(op string, x, y vector) (vector, error) {
+	if x.nums == nil || y.nums == nil {
+		return vector{}, errNotVectorizable
+	}
+	nums := make([]float64, len(x.nums))
+	switch op {
+	case token.ADD.String():
+		for i := range nums {
+			nums[i] = x.nums[i] + y.nums[i]
+		}
+	case token.SUB.String():
+		for i := range nums {
+			nums[i] = x.nums[i] - y.nums[i]
+		}
+	case token.MUL.String():
+		for i := range nums {
+			nums[i] = x.nums[i] * y.nums[i]
+		}
+	case token.QUO.String():
+		for i := range nums {
+			if y.nums[i] == 0 {
+				return vector{}, errNotVectorizable
+			}
+			nums[i] = x.nums[i] / y.nums[i]
+		}
+	default:
+		return vector{}, errNotVectorizable
+	}
+	return vector{nums: nums}, nil
+}
+
+func vectorizeComparative(op string, x, y vector) (vector, error) {
+	if x.bools != nil && y.bools != nil {
+		bools := make([]bool, len(x.bools))
+		switch op {
+		case token.EQL.String(), token.ASSIGN.String():
+			for i := range bools {
+				bools[i] = x.bools[i] == y.bools[i]
+			}
+		case token.NEQ.String():
+			for i := range bools {
+				bools[i] = x.bools[i] != y.bools[i]
+			}
+		default:
+			return vector{}, errNotVectorizable
+		}
+		return vector{bools: bools}, nil
+	}
+	if x.nums == nil || y.nums == nil {
+		return vector{}, errNotVectorizable
+	}
+	bools := make([]bool, len(x.nums))
+	switch op {
+	case token.EQL.String(), token.ASSIGN.String():
+		for i := range bools {
+			bools[i] = x.nums[i] == y.nums[i]
+		}
+	case token.NEQ.String():
+		for i := range bools {
+			bools[i] = x.nums[i] != y.nums[i]
+		}
+	case token.LSS.String():
+		for i := range bools {
+			bools[i] = x.nums[i] < y.nums[i]
+		}
+	case token.LEQ.String():
+		for i := range bools {
+			bools[i] = x.nums[i] <= y.nums[i]
+		}
+	case token.GTR.String():
+		for i := range bools {
+			bools[i] = x.nums[i] > y.nums[i]
+		}
+	case token.GEQ.String():
+		for i := range bools {
+			bools[i] = x.nums[i] >= y.nums[i]
+		}
+	default:
+		return vector{}, errNotVectorizable
+	}
+	return vector{bools: bools}, nil
+}
@@ -0,0 +1,146 @@
+package evaluator_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/mashiike/evaluator"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvalBatch(t *testing.T) {
+	columns := map[string][]float64{
+		"var1": {1, -2, 0.5, 0, math.NaN()},
+		"var2": {2, 4, 0.5, 3, 1},
+	}
+	cases := []string{
+		"var1",
+		"(var1 + 0.5) * var2 - var1 / var2",
+		"-var1 + +var2",
+		"var1 <= var2 && !(var1 == 0) || var2 != 4",
+		"(var1 < 0) == (var2 > 3)",
+		"3 < var2 >= 2",
+		"rate(var1, var2) <= 0.5",
+		"rate(var1, var2 - 3)",
+		"abs(var1) + pow(var2, 2) - clamp(var1, 0, 1)",
+		"coalesce(var3, var1) * 2",
+		"if(var1 > 0, `positive`, `not positive`)",
+	}
+	for _, expr := range cases {
+		t.Run(expr, func(t *testing.T) {
+			e, err := evaluator.New(expr)
+			require.NoError(t, err)
+			actual, err := evaluator.EvalBatch(e, columns, 5)
+			require.NoError(t, err)
+			require.Len(t, actual, 5)
+			for i := 0; i < 5; i++ {
+				expected, err := e.Eval(evaluator.Variables{"var1": columns["var1"][i], "var2": columns["var2"][i]})
+				require.NoError(t, err)
+				if f, ok := expected.(float64); ok && math.IsNaN(f) {
+					require.True(t, math.IsNaN(actual[i].(float64)), "row %d", i)
+					continue
+				}
+				require.EqualValues(t, expected, actual[i], "row %d", i)
+			}
+		})
+	}
+}
+
+func TestEvalBatchTyped(t *testing.T) {
+	columns := map[string][]float64{
+		"var1": {1, 2, 3},
+		"var2": {2, 0, 1},
+	}
+	e, err := evaluator.New("var1 * 2 + var2")
+	require.NoError(t, err)
+	nums, err := evaluator.EvalBatchFloat64(e, columns, 3)
+	require.NoError(t, err)
+	require.Equal(t, []float64{4, 4, 7}, nums)
+	nums[0] = 100
+	require.Equal(t, []float64{1, 2, 3}, columns["var1"], "must not modify the columns")
+
+	e, err = evaluator.New("var1 >= var2 || var1 > 2")
+	require.NoError(t, err)
+	bools, err := evaluator.EvalBatchBool(e, columns, 2)
+	require.NoError(t, err)
+	require.Equal(t, []bool{false, true}, bools)
+
+	e, err = evaluator.New("round(var1 / 2)")
+	require.NoError(t, err)
+	nums, err = evaluator.EvalBatchFloat64(e, columns, 3)
+	require.NoError(t, err)
+	require.Equal(t, []float64{1, 1, 2}, nums)
+
+	_, err = evaluator.EvalBatchBool(e, columns, 3)
+	require.EqualError(t, err, "EvalBatch row 0: Eval(`round(var1 / 2)`) v[1]::float64 is not bool")
+}
+
+func TestEvalBatchError(t *testing.T) {
+	columns := map[string][]float64{
+		"var1": {1, 2, 3},
+		"var2": {2, 0, 1},
+	}
+	cases := map[string]struct {
+		n        int
+		expected string
+	}{
+		"var1 / var2": {
+			n:        3,
+			expected: "EvalBatch row 1: Eval(`var1 / var2`) divide by 0",
+		},
+		"var1 + var3": {
+			n:        3,
+			expected: "EvalBatch row 0: Eval(`var1 + var3`) var3 variable not found",
+		},
+		"var1 + var2": {
+			n:        4,
+			expected: "EvalBatch column `var1` has 3 values, but n is 4",
+		},
+	}
+	for expr, c := range cases {
+		t.Run(expr, func(t *testing.T) {
+			e, err := evaluator.New(expr)
+			require.NoError(t, err)
+			e.Strict(true)
+			_, err = evaluator.EvalBatch(e, columns, c.n)
+			require.EqualError(t, err, c.expected)
+		})
+	}
+	e, _ := evaluator.New("var1 / var2")
+	_, err := evaluator.EvalBatch(e, columns, 3)
+	require.True(t, evaluator.IsDivideByZero(err))
+}
+
+func TestEvalBatchWithCostBudget(t *testing.T) {
+	columns := map[string][]float64{
+		"var1": {1, -2},
+		"var2": {2, 4},
+	}
+	e, err := evaluator.New("(var1 + 0.5) * var2", evaluator.WithCostBudget(100))
+	require.NoError(t, err)
+	actual, err := evaluator.EvalBatchFloat64(e, columns, 2)
+	require.NoError(t, err)
+	require.Equal(t, []float64{3, -6}, actual)
+
+	e, err = evaluator.New("(var1 + 0.5) * var2", evaluator.WithCostBudget(3))
+	require.NoError(t, err)
+	_, err = evaluator.EvalBatchFloat64(e, columns, 2)
+	require.True(t, errors.Is(err, evaluator.ErrBudgetExceeded))
+}
+
+func TestEvalBatchWithRecover(t *testing.T) {
+	columns := map[string][]float64{
+		"var1": {1, -2},
+		"var2": {2, 4},
+	}
+	e, err := evaluator.New("(var1 + 0.5) * var2")
+	require.NoError(t, err)
+	actual, err := evaluator.EvalBatchFloat64(evaluator.Recover(e), columns, 2)
+	require.NoError(t, err)
+	require.Equal(t, []float64{3, -6}, actual)
+
+	_, err = evaluator.EvalBatch(evaluator.Recover(panicEvaluator{}), columns, 2)
+	var panicErr *evaluator.PanicError
+	require.True(t, errors.As(err, &panicErr))
+}
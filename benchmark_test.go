@@ -80,6 +80,40 @@ func BenchmarkEvalutorEval(b *testing.B) {
 	}
 }
 
+func BenchmarkEvalBatch(b *testing.B) {
+
+	cases := []struct {
+		casename string
+		expr     string
+	}{
+		{casename: "ref_only", expr: "var1"},
+		{casename: "simple", expr: "var1 <= 30.0"},
+		{casename: "add_compare", expr: "var1 + var2 <= 30.0"},
+		{casename: "rate", expr: "rate(var1,var1 + var2) <= 0.95"},
+		{casename: "fallback", expr: "coalesce(var1, 10.0) * var2 <= 30.0"},
+	}
+	for _, c := range cases {
+		e, err := evaluator.New(c.expr)
+		require.NoError(b, err)
+		b.Run(c.casename, func(b *testing.B) {
+			columns := map[string][]float64{
+				"var1": make([]float64, b.N),
+				"var2": make([]float64, b.N),
+			}
+			for i := 0; i < b.N; i++ {
+				columns["var1"][i] = rand.NormFloat64()
+				columns["var2"][i] = rand.NormFloat64()
+			}
+			b.ResetTimer()
+			if _, ok := e.AsComparator(); ok {
+				evaluator.EvalBatchBool(e, columns, b.N)
+			} else {
+				evaluator.EvalBatchFloat64(e, columns, b.N)
+			}
+		})
+	}
+}
+
 var numOnlyLetters = []rune("0123456789")
 var letters = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
 
@@ -108,6 +108,9 @@ func addComputableFunc(v1, v2 interface{}) (interface{}, error) {
 	if n1, n2, ok := isBothRealNumbers(v1, v2); ok {
 		return n1 + n2, nil
 	}
+	if s1, s2, ok := isBothStrings(v1, v2); ok {
+		return s1 + s2, nil
+	}
 	return false, fmt.Errorf("v1[%v]::%T and v2[%v]::%T can not `+` comparatable", v1, v1, v2, v2)
 }
 
@@ -0,0 +1,125 @@
+package evaluator
+
+import (
+	"fmt"
+)
+
+// Check performs a static type check of the expression.
+// It detects the operations which fail regardless of the variables, for example `"a" < 1` or `1 && var1`.
+// The variables are assumed to be any type.
+func Check(e Evaluator) error {
+	_, err := inferKind(e)
+	return err
+}
+
+type valueKind int
+
+const (
+	kindAny valueKind = iota
+	kindNumber
+	kindString
+	kindBool
+	kindList
+)
+
+func (k valueKind) String() string {
+	switch k {
+	case kindNumber:
+		return "number"
+	case kindString:
+		return "string"
+	case kindBool:
+		return "bool"
+	case kindList:
+		return "list"
+	default:
+		return "any"
+	}
+}
+
+func inferKind(e Evaluator) (valueKind, error) {
+	children := childrenOf(e)
+	kinds := make([]valueKind, 0, len(children))
+	for _, child := range children {
+		kind, err := inferKind(child)
+		if err != nil {
+			return kindAny, err
+		}
+		kinds = append(kinds, kind)
+	}
+	switch e := e.(type) {
+	case *realNumericLiteralEvaluator:
+		return kindNumber, nil
+	case *stringLiteralEvaluator:
+		return kindString, nil
+	case *parenEvaluator:
+		return kinds[0], nil
+	case *unaryEvaluator:
+		if e.op == "!" {
+			return kindBool, nil
+		}
+		if !isKindOf(kinds[0], kindNumber) {
+			return kindAny, fmt.Errorf("Check(`%s`) %s can not `%s` operation", e, kinds[0], e.op)
+		}
+		return kindNumber, nil
+	case *comparativeEvaluator:
+		if kinds[0] != kindAny && kinds[1] != kindAny && kinds[0] != kinds[1] {
+			return kindAny, fmt.Errorf("Check(`%s`) %s and %s can not `%s` comparatable", e, kinds[0], kinds[1], e.op)
+		}
+		kind := kinds[0]
+		if kind == kindAny {
+			kind = kinds[1]
+		}
+		switch e.op {
+		case "==", "=", "!=":
+			if kind == kindList {
+				return kindAny, fmt.Errorf("Check(`%s`) %s and %s can not `%s` comparatable", e, kinds[0], kinds[1], e.op)
+			}
+		default:
+			if kind == kindBool || kind == kindList {
+				return kindAny, fmt.Errorf("Check(`%s`) %s and %s can not `%s` comparatable", e, kinds[0], kinds[1], e.op)
+			}
+		}
+		return kindBool, nil
+	case *logicalEvaluator:
+		if !isKindOf(kinds[0], kindBool) || !isKindOf(kinds[1], kindBool) {
+			return kindAny, fmt.Errorf("Check(`%s`) %s and %s can not `%s` operation", e, kinds[0], kinds[1], e.op)
+		}
+		return kindBool, nil
+	case *computableEvaluator:
+		if e.op == "+" && isKindOf(kinds[0], kindString) && isKindOf(kinds[1], kindString) && (kinds[0] == kindString || kinds[1] == kindString) {
+			return kindString, nil
+		}
+		if !isKindOf(kinds[0], kindNumber) || !isKindOf(kinds[1], kindNumber) {
+			return kindAny, fmt.Errorf("Check(`%s`) %s and %s can not `%s` operation", e, kinds[0], kinds[1], e.op)
+		}
+		if kinds[0] == kindAny && kinds[1] == kindAny && e.op == "+" {
+			return kindAny, nil
+		}
+		return kindNumber, nil
+	case *callEvaluator:
+		if e.funcName == "if" {
+			if !isKindOf(kinds[0], kindBool) && kinds[0] != kindNumber && kinds[0] != kindString {
+				return kindAny, fmt.Errorf("Check(`%s`) condition %s can not convert to bool", e, kinds[0])
+			}
+			if kinds[1] == kinds[2] {
+				return kinds[1], nil
+			}
+			return kindAny, nil
+		}
+		return resultKindOf(e.funcName), nil
+	case *statefulCallEvaluator:
+		return resultKindOf(e.funcName), nil
+	case *higherOrderEvaluator:
+		if !isKindOf(kinds[0], kindList) {
+			return kindAny, fmt.Errorf("Check(`%s`) %s is not list", e, kinds[0])
+		}
+		return resultKindOf(e.funcName), nil
+	default:
+		return kindAny, nil
+	}
+}
+
+func isKindOf(kind, expected valueKind) bool {
+	return kind == kindAny || kind == expected
+}
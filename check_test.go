@@ -0,0 +1,65 @@
+package evaluator_test
+
+import (
+	"testing"
+
+	"github.com/mashiike/evaluator"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	cases := map[string]string{
+		"var1 + 1 <= var2":                             "",
+		"`a` + var1 == `ab`":                           "",
+		"if(var1 > 0, `a`, `b`) + `c`":                 "",
+		"any(split(var1, `,`), v -> v == `a`) && var2": "",
+		"`a` < 1":                    "Check(`\"a\" < 1`) string and number can not `<` comparatable",
+		"1 && var1 > 0":              "Check(`1 && (var1 > 0)`) number and bool can not `&&` operation",
+		"upper(var1) * 2":            "Check(`upper(var1) * 2`) string and number can not `*` operation",
+		"(var1 > 1) < (var2 > 1)":    "Check(`(var1 > 1) < (var2 > 1)`) bool and bool can not `<` comparatable",
+		"any(len(var1), v -> v > 0)": "Check(`any(len(var1), v -> v > 0)`) number is not list",
+		"-`a`":                       "Check(`-\"a\"`) string can not `-` operation",
+		"coalesce(var1, 1) + sum(var2) > as_numeric(`1`) - 1": "",
+	}
+	for expr, expected := range cases {
+		t.Run(expr, func(t *testing.T) {
+			e, err := evaluator.New(expr)
+			require.NoError(t, err, "must parse success")
+			err = evaluator.Check(e)
+			if expected == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, expected)
+			}
+		})
+	}
+}
+
+func TestReferencedVariables(t *testing.T) {
+	e, err := evaluator.New("any(disks, d -> d.used / d.size > threshold) && rate(var1, var2) < 0.5 && var1 > 0")
+	require.NoError(t, err, "must parse success")
+	require.Equal(t, []string{"disks", "threshold", "var1", "var2"}, evaluator.ReferencedVariables(e))
+}
+
+func TestEvaluatorStringRoundTrip(t *testing.T) {
+	cases := []string{
+		"(var1 + 0.5) * var2",
+		"1.0 <= var1 <= 5",
+		"if(!string_contains(as_string(var1), `hoge`), 1.8, -3.14)",
+		"regexp_match(var1, `^\\d+$`) || var2 == \"a\\\"b\\n\"",
+		"map(filter(values, v -> v > 1), v -> -v * 2)",
+		"reduce(values, 0, (acc, v) -> acc + v) > 5.5 + 4.5",
+		"-var1 + d.used",
+	}
+	for _, expr := range cases {
+		t.Run(expr, func(t *testing.T) {
+			e, err := evaluator.New(expr)
+			require.NoError(t, err, "must parse success")
+			formatted := e.String()
+			t.Log(formatted)
+			again, err := evaluator.New(formatted)
+			require.NoError(t, err, "formatted expression must parse success")
+			require.Equal(t, formatted, again.String())
+		})
+	}
+}
@@ -0,0 +1,414 @@
+// Command evaluator evaluates and checks expressions of github.com/mashiike/evaluator.
+//
+// Usage:
+//
+//	evaluator [eval] [flags] <expr>
+//	evaluator check [flags] <expr>
+//	evaluator vars [flags] <expr>
+//	evaluator fmt [flags] <expr>
+//	evaluator explain [flags] <expr>
+//	evaluator repl [flags]
+//	evaluator gen -pkg <package> -func <name> [-o <file>] <expr>
+//	evaluator conformance [flags] <file>...
+//
+// Variables are given by -var name=value flags, a JSON or YAML file by -vars, or stdin.
+// With -batch, each line of stdin is a JSON object of variables, and the result is printed for each line.
+// With -stream jsonl or -stream csv, the records of stdin are written with the result column.
+// The gen command generates the Go function equivalent to the expression, like `func IsHot(var1, var2 float64) bool`.
+// The conformance command runs the cases of the conformance corpus in YAML or JSON files, see evaluator.ConformanceCase.
+// The repl command reads expressions and commands like `:set var1 = 3` interactively, type `:help` for the commands.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/mashiike/evaluator"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+type command struct {
+	description string
+	run         func(*app, *options) error
+}
+
+var commands = map[string]command{
+	"eval":        {description: "evaluate the expression", run: (*app).eval},
+	"check":       {description: "parse and type-check the expression", run: (*app).check},
+	"vars":        {description: "list the variables referenced by the expression", run: (*app).vars},
+	"fmt":         {description: "print the expression in the canonical format", run: (*app).format},
+	"explain":     {description: "evaluate the expression and print the trace of each node", run: (*app).explain},
+	"repl":        {description: "evaluate the expressions interactively", run: (*app).repl},
+	"gen":         {description: "generate the Go function equivalent to the expression", run: (*app).gen},
+	"conformance": {description: "run the cases of the conformance corpus files", run: (*app).conformance},
+}
+
+type app struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// errSilent is returned when the error is already reported to the output.
+var errSilent = errors.New("silent")
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	a := &app{
+		stdin:  stdin,
+		stdout: stdout,
+		stderr: stderr,
+	}
+	name := "eval"
+	if len(args) > 0 {
+		if _, ok := commands[args[0]]; ok {
+			name, args = args[0], args[1:]
+		} else if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+			a.usage()
+			return 0
+		}
+	}
+	opts, err := parseOptions(name, args, stderr)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if err := commands[name].run(a, opts); err != nil {
+		if !errors.Is(err, errSilent) {
+			fmt.Fprintf(stderr, "evaluator %s: %s\n", name, err)
+		}
+		return 1
+	}
+	return 0
+}
+
+func (a *app) usage() {
+	fmt.Fprintln(a.stderr, "Usage: evaluator [command] [flags] [<expr>]")
+	fmt.Fprintln(a.stderr, "")
+	fmt.Fprintln(a.stderr, "Commands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(a.stderr, "  %-12s %s\n", name, commands[name].description)
+	}
+	fmt.Fprintln(a.stderr, "")
+	fmt.Fprintln(a.stderr, "Run `evaluator <command> -h` for the flags of the command.")
+}
+
+type options struct {
+	expr     string
+	vars     variableFlags
+	varsFile string
+	batch    bool
+	stream   string
+	onError  string
+	column   string
+	output   string
+	strict   bool
+	pkg      string
+	funcName string
+	outFile  string
+	files    []string
+}
+
+func parseOptions(name string, args []string, stderr io.Writer) (*options, error) {
+	opts := &options{}
+	fs := flag.NewFlagSet("evaluator "+name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&opts.expr, "expr", "", "expression, or give it as the argument")
+	fs.StringVar(&opts.output, "output", "text", "output format: text or json")
+	switch name {
+	case "eval", "explain", "repl":
+		fs.Var(&opts.vars, "var", "variable as name=value, the value is parsed as JSON if possible (repeatable)")
+		fs.StringVar(&opts.varsFile, "vars", "", "JSON or YAML file of variables, `-` for stdin")
+		fs.BoolVar(&opts.strict, "strict", false, "evaluate in strict mode")
+	}
+	if name == "eval" {
+		fs.BoolVar(&opts.batch, "batch", false, "read variables as JSON Lines from stdin, and evaluate for each line")
+		fs.StringVar(&opts.stream, "stream", "", "read records as jsonl or csv from stdin, and write them with the result column")
+		fs.StringVar(&opts.onError, "on-error", "abort", "error policy of -stream: abort, skip or null")
+		fs.StringVar(&opts.column, "column", "result", "name of the result column of -stream")
+	}
+	if name == "gen" {
+		fs.StringVar(&opts.pkg, "pkg", "main", "package name of the generated code")
+		fs.StringVar(&opts.funcName, "func", "", "function name of the generated code (required)")
+		fs.StringVar(&opts.outFile, "o", "", "file to write the generated code, stdout if empty")
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if opts.output != "text" && opts.output != "json" {
+		fmt.Fprintf(stderr, "unknown output format `%s`\n", opts.output)
+		return nil, errors.New("invalid arguments")
+	}
+	if name == "conformance" {
+		if opts.expr != "" || fs.NArg() == 0 {
+			fmt.Fprintln(stderr, "conformance takes the files of the cases instead of the expression")
+			return nil, errors.New("invalid arguments")
+		}
+		opts.files = fs.Args()
+		return opts, nil
+	}
+	if opts.expr == "" {
+		opts.expr = strings.Join(fs.Args(), " ")
+	} else if fs.NArg() > 0 {
+		fmt.Fprintln(stderr, "both -expr and the argument are given")
+		return nil, errors.New("invalid arguments")
+	}
+	if name == "repl" {
+		if opts.expr != "" {
+			fmt.Fprintln(stderr, "repl does not take the expression")
+			return nil, errors.New("invalid arguments")
+		}
+		if opts.varsFile == "-" {
+			fmt.Fprintln(stderr, "repl can not read variables from stdin")
+			return nil, errors.New("invalid arguments")
+		}
+		return opts, nil
+	}
+	if opts.expr == "" {
+		fmt.Fprintln(stderr, "expression is required")
+		fs.Usage()
+		return nil, errors.New("invalid arguments")
+	}
+	if name == "gen" && opts.funcName == "" {
+		fmt.Fprintln(stderr, "-func is required")
+		return nil, errors.New("invalid arguments")
+	}
+	if opts.stream != "" {
+		if opts.batch {
+			fmt.Fprintln(stderr, "-stream and -batch can not be used together")
+			return nil, errors.New("invalid arguments")
+		}
+		if _, ok := errorPolicies[opts.onError]; !ok {
+			fmt.Fprintf(stderr, "unknown error policy `%s`\n", opts.onError)
+			return nil, errors.New("invalid arguments")
+		}
+	}
+	return opts, nil
+}
+
+func (a *app) eval(opts *options) error {
+	e, err := opts.newEvaluator()
+	if err != nil {
+		return err
+	}
+	base, err := opts.variables(a.stdin)
+	if err != nil {
+		return err
+	}
+	if opts.stream != "" {
+		return a.evalStream(e, base, opts)
+	}
+	if !opts.batch {
+		ret, err := e.Eval(base)
+		if opts.output == "json" {
+			return a.writeJSON(resultJSON(ret, err))
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(a.stdout, formatText(ret))
+		return nil
+	}
+	failed := false
+	err = readJSONLines(a.stdin, func(lineNum int, vars evaluator.Variables) error {
+		merged := make(evaluator.Variables, len(base)+len(vars))
+		for k, v := range base {
+			merged[k] = v
+		}
+		for k, v := range vars {
+			merged[k] = v
+		}
+		ret, err := e.Eval(merged)
+		if err != nil {
+			failed = true
+		}
+		if opts.output == "json" {
+			return a.writeJSON(resultJSON(ret, err))
+		}
+		if err != nil {
+			fmt.Fprintf(a.stderr, "line %d: %s\n", lineNum, err)
+			fmt.Fprintln(a.stdout)
+			return nil
+		}
+		fmt.Fprintln(a.stdout, formatText(ret))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if failed {
+		return errSilent
+	}
+	return nil
+}
+
+var errorPolicies = map[string]evaluator.ErrorPolicy{
+	"abort": evaluator.ErrorPolicyAbort,
+	"skip":  evaluator.ErrorPolicySkip,
+	"null":  evaluator.ErrorPolicyNull,
+}
+
+func (a *app) evalStream(e evaluator.Evaluator, base evaluator.Variables, opts *options) error {
+	policy := errorPolicies[opts.onError]
+	streamOpts := []evaluator.StreamOption{
+		evaluator.WithBaseVariables(base),
+		evaluator.WithResultColumn(opts.column),
+		evaluator.WithErrorPolicy(policy),
+	}
+	if policy != evaluator.ErrorPolicyAbort {
+		streamOpts = append(streamOpts, evaluator.WithRowErrorHandler(func(err *evaluator.RowError) {
+			fmt.Fprintln(a.stderr, err)
+		}))
+	}
+	return evaluator.EvalStream(e, a.stdin, evaluator.StreamFormat(opts.stream), a.stdout, streamOpts...)
+}
+
+func (a *app) check(opts *options) error {
+	e, err := evaluator.New(opts.expr)
+	if err == nil {
+		err = evaluator.Check(e)
+	}
+	if opts.output == "json" {
+		ret := map[string]interface{}{"ok": err == nil}
+		if err != nil {
+			ret["error"] = err.Error()
+		}
+		if werr := a.writeJSON(ret); werr != nil {
+			return werr
+		}
+		if err != nil {
+			return errSilent
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(a.stdout, "ok")
+	return nil
+}
+
+func (a *app) vars(opts *options) error {
+	e, err := evaluator.New(opts.expr)
+	if err != nil {
+		return err
+	}
+	names := evaluator.ReferencedVariables(e)
+	if opts.output == "json" {
+		return a.writeJSON(names)
+	}
+	for _, name := range names {
+		fmt.Fprintln(a.stdout, name)
+	}
+	return nil
+}
+
+func (a *app) format(opts *options) error {
+	e, err := evaluator.New(opts.expr)
+	if err != nil {
+		return err
+	}
+	if opts.output == "json" {
+		return a.writeJSON(map[string]interface{}{"expr": e.String()})
+	}
+	fmt.Fprintln(a.stdout, e.String())
+	return nil
+}
+
+func (a *app) gen(opts *options) error {
+	e, err := evaluator.New(opts.expr)
+	if err != nil {
+		return err
+	}
+	src, err := evaluator.GenerateGo(e, opts.pkg, opts.funcName)
+	if err != nil {
+		return err
+	}
+	if opts.outFile == "" {
+		_, err = a.stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(opts.outFile, src, 0644)
+}
+
+func (a *app) conformance(opts *options) error {
+	var failures []map[string]interface{}
+	total := 0
+	for _, file := range opts.files {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		cases, err := evaluator.LoadConformanceCases(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		for _, c := range cases {
+			total++
+			if err := c.Run(); err != nil {
+				failures = append(failures, map[string]interface{}{"file": file, "name": c.Name, "error": err.Error()})
+				if opts.output == "text" {
+					fmt.Fprintf(a.stdout, "FAIL %s: %s\n", file, err)
+				}
+			}
+		}
+	}
+	if opts.output == "json" {
+		if failures == nil {
+			failures = []map[string]interface{}{}
+		}
+		if err := a.writeJSON(map[string]interface{}{"total": total, "failures": failures}); err != nil {
+			return err
+		}
+	} else {
+		fmt.Fprintf(a.stdout, "%d/%d passed\n", total-len(failures), total)
+	}
+	if len(failures) > 0 {
+		return errSilent
+	}
+	return nil
+}
+
+func (a *app) explain(opts *options) error {
+	e, err := opts.newEvaluator()
+	if err != nil {
+		return err
+	}
+	vars, err := opts.variables(a.stdin)
+	if err != nil {
+		return err
+	}
+	explanation := evaluator.Explain(e, vars)
+	if opts.output == "json" {
+		return a.writeJSON(explanationJSON(explanation))
+	}
+	fmt.Fprint(a.stdout, explanation)
+	if explanation.Err != nil {
+		return errSilent
+	}
+	return nil
+}
+
+func (opts *options) newEvaluator() (evaluator.Evaluator, error) {
+	e, err := evaluator.New(opts.expr)
+	if err != nil {
+		return nil, err
+	}
+	e.Strict(opts.strict)
+	return e, nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	yamlFile := filepath.Join(dir, "vars.yaml")
+	require.NoError(t, os.WriteFile(yamlFile, []byte("var1: 2\nvar2: [1, 2, 3]\n"), 0o644))
+	jsonFile := filepath.Join(dir, "vars.json")
+	require.NoError(t, os.WriteFile(jsonFile, []byte(`{"var1": 0.5, "var2": 3}`), 0o644))
+	casesFile := filepath.Join(dir, "cases.yaml")
+	require.NoError(t, os.WriteFile(casesFile, []byte("- {expr: 'var1 * 2', vars: {var1: 2}, expected: 4}\n- {expr: 'var1 / 0', vars: {var1: 1}, error: divide_by_zero}\n"), 0o644))
+	failedCasesFile := filepath.Join(dir, "failed_cases.yaml")
+	require.NoError(t, os.WriteFile(failedCasesFile, []byte("- {expr: 'var1 * 2', vars: {var1: 2}, expected: 5}\n"), 0o644))
+
+	cases := []struct {
+		name     string
+		args     []string
+		stdin    string
+		code     int
+		expected string
+	}{
+		{
+			name:     "eval with flags",
+			args:     []string{"-var", "var1=0.5", "-var", "var2=3", "(var1 + 0.5) * var2"},
+			expected: "3\n",
+		},
+		{
+			name:     "eval with string flag",
+			args:     []string{"eval", "-var", "var1=hoge", "-expr", "upper(var1)"},
+			expected: "HOGE\n",
+		},
+		{
+			name:     "eval with yaml file",
+			args:     []string{"eval", "-vars", yamlFile, "sum(var2) * var1"},
+			expected: "12\n",
+		},
+		{
+			name:     "eval with json file and json output",
+			args:     []string{"eval", "-vars", jsonFile, "-output", "json", "(var1 + 0.5) * var2 <= 3"},
+			expected: `{"result":true}` + "\n",
+		},
+		{
+			name:     "eval with stdin",
+			args:     []string{"eval", "-vars", "-", "var1 * 2"},
+			stdin:    `{"var1": 4}`,
+			expected: "8\n",
+		},
+		{
+			name:     "eval strict",
+			args:     []string{"eval", "-strict", "var1 * 2"},
+			code:     1,
+			expected: "",
+		},
+		{
+			name:     "batch",
+			args:     []string{"eval", "-batch", "-var", "var2=10", "-output", "json", "var1 * var2"},
+			stdin:    "{\"var1\": 1}\n\n{\"var1\": 2, \"var2\": 2}\n{\"var1\": \"a\"}\n",
+			code:     1,
+			expected: `{"result":10}` + "\n" + `{"result":4}` + "\n" + `{"error":"Eval(` + "`var1 * var2`" + `) v1[a]::string and v2[10]::float64 can not ` + "`*`" + ` comparatable","result":null}` + "\n",
+		},
+		{
+			name:     "stream csv",
+			args:     []string{"eval", "-stream", "csv", "-on-error", "null", "-column", "total", "-var", "var2=2", "var1 * var2"},
+			stdin:    "var1\n1\na\n3\n",
+			expected: "var1,total\n1,2\na,\n3,6\n",
+		},
+		{
+			name:     "stream jsonl abort",
+			args:     []string{"eval", "-stream", "jsonl", "var1 * 2"},
+			stdin:    "{\"var1\": 1}\n{\"var1\": \"a\"}\n{\"var1\": 3}\n",
+			code:     1,
+			expected: `{"result":2,"var1":1}` + "\n",
+		},
+		{
+			name: "stream unknown error policy",
+			args: []string{"eval", "-stream", "csv", "-on-error", "ignore", "var1"},
+			code: 2,
+		},
+		{
+			name:     "check",
+			args:     []string{"check", "var1 + 1 > 2"},
+			expected: "ok\n",
+		},
+		{
+			name:     "check failed",
+			args:     []string{"check", "-output", "json", "`a` < 1"},
+			code:     1,
+			expected: `{"error":"Check(` + "`\\\"a\\\" < 1`" + `) string and number can not ` + "`<`" + ` comparatable","ok":false}` + "\n",
+		},
+		{
+			name:     "vars",
+			args:     []string{"vars", "any(values, v -> v > threshold) && var1 > 0"},
+			expected: "threshold\nvalues\nvar1\n",
+		},
+		{
+			name:     "fmt",
+			args:     []string{"fmt", "if(var1>1,`a`,'b')"},
+			expected: `if(var1 > 1, "a", "b")` + "\n",
+		},
+		{
+			name:     "explain",
+			args:     []string{"explain", "-var", "var1=97", "-var", "var2=100", "rate(var1, var2) <= 0.95"},
+			expected: "rate(var1, var2)=0.97 <= 0.95 → false\n  rate(var1=97, var2=100) → 0.97\n    var1 → 97\n    var2 → 100\n",
+		},
+		{
+			name:     "gen",
+			args:     []string{"gen", "-pkg", "rules", "-func", "IsHot", "(var1 + 0.5) * var2 > 3"},
+			expected: "// Code generated by evaluator gen. DO NOT EDIT.\n\npackage rules\n\n// IsHot evaluates `(var1 + 0.5) * var2 > 3`.\nfunc IsHot(var1, var2 float64) bool {\n\treturn (var1+0.5)*var2 > 3.0\n}\n",
+		},
+		{
+			name: "gen without func",
+			args: []string{"gen", "var1 > 3"},
+			code: 2,
+		},
+		{
+			name:     "conformance",
+			args:     []string{"conformance", casesFile},
+			expected: "2/2 passed\n",
+		},
+		{
+			name:     "conformance failed",
+			args:     []string{"conformance", casesFile, failedCasesFile},
+			code:     1,
+			expected: "FAIL " + failedCasesFile + ": Conformance(`var1 * 2`) expected v[5]::int, but got v[4]::float64\n2/3 passed\n",
+		},
+		{
+			name: "conformance without files",
+			args: []string{"conformance"},
+			code: 2,
+		},
+		{
+			name: "parse error",
+			args: []string{"var1 +"},
+			code: 1,
+		},
+		{
+			name: "no expression",
+			args: []string{"eval"},
+			code: 2,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := run(c.args, strings.NewReader(c.stdin), &stdout, &stderr)
+			t.Log(stderr.String())
+			require.Equal(t, c.code, code, "exit code")
+			require.Equal(t, c.expected, stdout.String())
+		})
+	}
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mashiike/evaluator"
+)
+
+// lineReader reads the lines of the input and keeps the history.
+// A line which ends with `\` continues to the next line,
+// `!!` recalls the previous line and `!n` recalls the n-th line of the history.
+type lineReader struct {
+	reader  *bufio.Reader
+	prompt  io.Writer
+	history []string
+}
+
+func newLineReader(r io.Reader, prompt io.Writer) *lineReader {
+	return &lineReader{
+		reader: bufio.NewReader(r),
+		prompt: prompt,
+	}
+}
+
+// ReadLine returns the next line, io.EOF is returned when the input is exhausted.
+func (r *lineReader) ReadLine(prompt string) (string, error) {
+	var builder strings.Builder
+	for {
+		fmt.Fprint(r.prompt, prompt)
+		line, err := r.reader.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			if err == io.EOF && builder.Len() > 0 {
+				break
+			}
+			return "", err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if strings.HasSuffix(line, `\`) {
+			builder.WriteString(strings.TrimSuffix(line, `\`))
+			builder.WriteString("\n")
+			prompt = "... "
+			continue
+		}
+		builder.WriteString(line)
+		break
+	}
+	line, err := r.expand(strings.TrimSpace(builder.String()))
+	if err != nil {
+		return "", err
+	}
+	if line != "" {
+		r.history = append(r.history, line)
+	}
+	return line, nil
+}
+
+func (r *lineReader) expand(line string) (string, error) {
+	if !strings.HasPrefix(line, "!") || line == "!" {
+		return line, nil
+	}
+	if line == "!!" {
+		if len(r.history) == 0 {
+			return "", errHistoryNotFound("!!")
+		}
+		return r.history[len(r.history)-1], nil
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return line, nil
+	}
+	if n < 1 || n > len(r.history) {
+		return "", errHistoryNotFound(line)
+	}
+	return r.history[n-1], nil
+}
+
+// History returns the lines read so far.
+func (r *lineReader) History() []string {
+	return r.history
+}
+
+type errHistoryNotFound string
+
+func (e errHistoryNotFound) Error() string {
+	return fmt.Sprintf("%s: event not found", string(e))
+}
+
+type replCommand struct {
+	usage       string
+	description string
+	run         func(*repl, string) error
+}
+
+var replCommands map[string]replCommand
+
+func init() {
+	replCommands = map[string]replCommand{
+		":set":     {usage: ":set <name> = <value>", description: "set the variable, the value is parsed as JSON if possible", run: (*repl).set},
+		":unset":   {usage: ":unset <name>", description: "remove the variable", run: (*repl).unset},
+		":vars":    {usage: ":vars", description: "list the variables", run: (*repl).listVars},
+		":load":    {usage: ":load <file>", description: "load the variables from a JSON or YAML file", run: (*repl).load},
+		":strict":  {usage: ":strict [on|off]", description: "toggle or show strict mode", run: (*repl).toggleStrict},
+		":parse":   {usage: ":parse <expr>", description: "print the parsed expression in the canonical format", run: (*repl).parse},
+		":funcs":   {usage: ":funcs", description: "list the built-in functions", run: (*repl).funcs},
+		":history": {usage: ":history", description: "list the history", run: (*repl).history},
+		":help":    {usage: ":help", description: "show this help", run: (*repl).help},
+		":quit":    {usage: ":quit", description: "exit the REPL, or give EOF", run: nil},
+	}
+}
+
+type repl struct {
+	out    io.Writer
+	reader *lineReader
+	vars   evaluator.Variables
+	strict bool
+}
+
+// errQuit is returned by the REPL commands to exit.
+var errQuit = errors.New("quit")
+
+func (a *app) repl(opts *options) error {
+	vars, err := opts.variables(nil)
+	if err != nil {
+		return err
+	}
+	r := &repl{
+		out:    a.stdout,
+		reader: newLineReader(a.stdin, a.stdout),
+		vars:   vars,
+		strict: opts.strict,
+	}
+	for {
+		line, err := r.reader.ReadLine("> ")
+		if err == io.EOF {
+			fmt.Fprintln(a.stdout)
+			return nil
+		}
+		if err != nil {
+			var notFound errHistoryNotFound
+			if errors.As(err, &notFound) {
+				fmt.Fprintf(a.stdout, "error: %s\n", err)
+				continue
+			}
+			return err
+		}
+		if err := r.exec(line); err != nil {
+			if errors.Is(err, errQuit) {
+				return nil
+			}
+			fmt.Fprintf(a.stdout, "error: %s\n", err)
+		}
+	}
+}
+
+func (r *repl) exec(line string) error {
+	if line == "" {
+		return nil
+	}
+	if !strings.HasPrefix(line, ":") {
+		return r.eval(line)
+	}
+	name, arg := line, ""
+	if i := strings.IndexAny(line, " \t"); i >= 0 {
+		name, arg = line[:i], strings.TrimSpace(line[i+1:])
+	}
+	if name == ":quit" || name == ":q" || name == ":exit" {
+		return errQuit
+	}
+	cmd, ok := replCommands[name]
+	if !ok {
+		return fmt.Errorf("unknown command `%s`, see :help", name)
+	}
+	return cmd.run(r, arg)
+}
+
+func (r *repl) eval(expr string) error {
+	e, err := evaluator.New(expr)
+	if err != nil {
+		return err
+	}
+	e.Strict(r.strict)
+	ret, err := e.Eval(r.vars)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(r.out, formatText(ret))
+	return nil
+}
+
+func (r *repl) set(arg string) error {
+	parts := strings.SplitN(arg, "=", 2)
+	name := strings.TrimSpace(parts[0])
+	if len(parts) != 2 || name == "" {
+		return errors.New("usage: " + replCommands[":set"].usage)
+	}
+	r.vars[name] = parseValue(strings.TrimSpace(parts[1]))
+	return nil
+}
+
+func (r *repl) unset(arg string) error {
+	if arg == "" {
+		return errors.New("usage: " + replCommands[":unset"].usage)
+	}
+	delete(r.vars, arg)
+	return nil
+}
+
+func (r *repl) listVars(string) error {
+	names := make([]string, 0, len(r.vars))
+	for name := range r.vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(r.out, "%s = %s\n", name, formatText(r.vars[name]))
+	}
+	return nil
+}
+
+func (r *repl) load(arg string) error {
+	if arg == "" {
+		return errors.New("usage: " + replCommands[":load"].usage)
+	}
+	loaded, err := loadVariablesFile(arg)
+	if err != nil {
+		return err
+	}
+	for k, v := range loaded {
+		r.vars[k] = v
+	}
+	fmt.Fprintf(r.out, "loaded %d variables\n", len(loaded))
+	return nil
+}
+
+func (r *repl) toggleStrict(arg string) error {
+	switch arg {
+	case "":
+	case "on":
+		r.strict = true
+	case "off":
+		r.strict = false
+	default:
+		return errors.New("usage: " + replCommands[":strict"].usage)
+	}
+	if r.strict {
+		fmt.Fprintln(r.out, "strict mode is on")
+	} else {
+		fmt.Fprintln(r.out, "strict mode is off")
+	}
+	return nil
+}
+
+func (r *repl) parse(arg string) error {
+	if arg == "" {
+		return errors.New("usage: " + replCommands[":parse"].usage)
+	}
+	e, err := evaluator.New(arg)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(r.out, e.String())
+	return nil
+}
+
+func (r *repl) funcs(string) error {
+	for _, signature := range evaluator.Functions() {
+		fmt.Fprintln(r.out, signature)
+	}
+	return nil
+}
+
+func (r *repl) history(string) error {
+	for i, line := range r.reader.History() {
+		fmt.Fprintf(r.out, "%4d  %s\n", i+1, line)
+	}
+	return nil
+}
+
+func (r *repl) help(string) error {
+	names := make([]string, 0, len(replCommands))
+	for name := range replCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(r.out, "Type an expression to evaluate it, or a command:")
+	for _, name := range names {
+		fmt.Fprintf(r.out, "  %-22s %s\n", replCommands[name].usage, replCommands[name].description)
+	}
+	fmt.Fprintln(r.out, "A line ending with `\\` continues to the next line, `!!` and `!n` recall the history.")
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepl(t *testing.T) {
+	dir := t.TempDir()
+	varsFile := filepath.Join(dir, "vars.yaml")
+	require.NoError(t, os.WriteFile(varsFile, []byte("var2: [1, 2, 3]\n"), 0o644))
+
+	input := strings.Join([]string{
+		":set var1 = 3",
+		"var1 * 2",
+		"!!",
+		":load " + varsFile,
+		"sum(var2) + \\",
+		"var1",
+		":vars",
+		":parse if(var1>1,`a`,'b')",
+		"var3 + 1",
+		":strict on",
+		"var3 + 1",
+		"!1",
+		"!99",
+		":history",
+		":unknown",
+		":quit",
+		"var1",
+	}, "\n")
+	expected := strings.Join([]string{
+		"> > 6",
+		"> 6",
+		"> loaded 1 variables",
+		"> ... 9",
+		"> var1 = 3",
+		"var2 = [1,2,3]",
+		`> if(var1 > 1, "a", "b")`,
+		"> error: Eval(`var3 + 1`) v1[<nil>]::<nil> and v2[1]::float64 can not `+` comparatable",
+		"> strict mode is on",
+		"> error: Eval(`var3 + 1`) var3 variable not found",
+		"> > error: !99: event not found",
+		">    1  :set var1 = 3",
+		"   2  var1 * 2",
+		"   3  var1 * 2",
+		"   4  :load " + varsFile,
+		"   5  sum(var2) + \nvar1",
+		"   6  :vars",
+		"   7  :parse if(var1>1,`a`,'b')",
+		"   8  var3 + 1",
+		"   9  :strict on",
+		"  10  var3 + 1",
+		"  11  :set var1 = 3",
+		"  12  :history",
+		"> error: unknown command `:unknown`, see :help",
+		"> ",
+	}, "\n")
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"repl"}, strings.NewReader(input), &stdout, &stderr)
+	t.Log(stderr.String())
+	require.Equal(t, 0, code, "exit code")
+	require.Equal(t, expected, stdout.String())
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mashiike/evaluator"
+	"gopkg.in/yaml.v3"
+)
+
+// variableFlags is the flag.Value of `-var name=value`
+type variableFlags []string
+
+func (f *variableFlags) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *variableFlags) Set(s string) error {
+	if !strings.Contains(s, "=") {
+		return errors.New("variable must be name=value")
+	}
+	*f = append(*f, s)
+	return nil
+}
+
+// variables loads the variables from the file and the flags, the flags override the file.
+func (opts *options) variables(stdin io.Reader) (evaluator.Variables, error) {
+	vars := make(evaluator.Variables)
+	if opts.varsFile != "" {
+		var loaded evaluator.Variables
+		var err error
+		if opts.varsFile == "-" {
+			if opts.batch || opts.stream != "" {
+				return nil, errors.New("-vars - can not be used with -batch or -stream")
+			}
+			loaded, err = loadVariables(stdin, "")
+		} else {
+			loaded, err = loadVariablesFile(opts.varsFile)
+		}
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range loaded {
+			vars[k] = v
+		}
+	}
+	for _, s := range opts.vars {
+		parts := strings.SplitN(s, "=", 2)
+		vars[strings.TrimSpace(parts[0])] = parseValue(parts[1])
+	}
+	return vars, nil
+}
+
+// parseValue parses the value as JSON if possible, otherwise it is a string.
+func parseValue(s string) interface{} {
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err == nil {
+		return v
+	}
+	return s
+}
+
+func loadVariablesFile(path string) (evaluator.Variables, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return loadVariables(f, filepath.Ext(path))
+}
+
+// loadVariables decodes the JSON or YAML object, YAML is used for the extension .yaml and .yml or the content which is not JSON.
+func loadVariables(r io.Reader, ext string) (evaluator.Variables, error) {
+	bs, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	vars := make(evaluator.Variables)
+	if ext != ".yaml" && ext != ".yml" && json.Valid(bs) {
+		if err := json.Unmarshal(bs, &vars); err != nil {
+			return nil, fmt.Errorf("decode variables as JSON: %w", err)
+		}
+		return vars, nil
+	}
+	if err := yaml.Unmarshal(bs, &vars); err != nil {
+		return nil, fmt.Errorf("decode variables as YAML: %w", err)
+	}
+	return vars, nil
+}
+
+// readJSONLines calls fn for each line which is a JSON object, the empty lines are skipped.
+func readJSONLines(r io.Reader, fn func(lineNum int, vars evaluator.Variables) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var vars evaluator.Variables
+		if err := json.Unmarshal(line, &vars); err != nil {
+			return fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		if err := fn(lineNum, vars); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func formatText(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return v
+	case []interface{}, map[string]interface{}:
+		bs, err := json.Marshal(jsonValue(v))
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(bs)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+func resultJSON(v interface{}, err error) map[string]interface{} {
+	ret := map[string]interface{}{"result": jsonValue(v)}
+	if err != nil {
+		ret["error"] = err.Error()
+	}
+	return ret
+}
+
+// jsonValue converts the value which can not be encoded to JSON, like NaN and Inf.
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Sprintf("%v", v)
+		}
+		return v
+	case []interface{}:
+		ret := make([]interface{}, 0, len(v))
+		for _, elem := range v {
+			ret = append(ret, jsonValue(elem))
+		}
+		return ret
+	case map[string]interface{}:
+		ret := make(map[string]interface{}, len(v))
+		for k, elem := range v {
+			ret[k] = jsonValue(elem)
+		}
+		return ret
+	default:
+		return v
+	}
+}
+
+func explanationJSON(x *evaluator.Explanation) map[string]interface{} {
+	ret := map[string]interface{}{
+		"expr":      x.Expr,
+		"evaluated": x.Evaluated,
+	}
+	if x.Evaluated {
+		ret["value"] = jsonValue(x.Value)
+		ret["type"] = x.Type
+	}
+	if x.Err != nil {
+		ret["error"] = x.Err.Error()
+	}
+	if len(x.Children) > 0 {
+		children := make([]interface{}, 0, len(x.Children))
+		for _, child := range x.Children {
+			children = append(children, explanationJSON(child))
+		}
+		ret["children"] = children
+	}
+	return ret
+}
+
+func (a *app) writeJSON(v interface{}) error {
+	enc := json.NewEncoder(a.stdout)
+	enc.SetEscapeHTML(false)
+	return enc.Encode(v)
+}
@@ -0,0 +1,135 @@
+package evaluator
+
+import (
+	"fmt"
+)
+
+// Condition is a leaf condition of the Comparator with the evaluated operands.
+type Condition struct {
+	// Expr is the condition expression, like `disk_free < 10`
+	Expr string
+	// Op is the comparative operator, it is empty if the condition is not a comparison, like `string_contains(var1, "a")`
+	Op string
+	// XExpr and YExpr are the operand expressions. YExpr is empty if the condition is not a comparison.
+	XExpr string
+	YExpr string
+	// X and Y are the evaluated operand values. X is the value of the condition if it is not a comparison.
+	X interface{}
+	Y interface{}
+	// Result is the evaluated value of the condition.
+	Result bool
+
+	annotated string
+}
+
+// String returns the condition annotated by the operand values, like `disk_free=12 < 10`
+func (c Condition) String() string {
+	return c.annotated
+}
+
+// CompareDetailed performs the comparison, and reports the leaf conditions which decided the result.
+// If the comparator is not a DetailedComparator, the comparator itself is reported as the only condition.
+func CompareDetailed(c Comparator, vars Variables) (bool, []Condition, error) {
+	if d, ok := c.(DetailedComparator); ok {
+		return d.CompareDetailed(vars)
+	}
+	ret, err := c.Compare(vars)
+	if err != nil {
+		return false, nil, err
+	}
+	return ret, []Condition{valueCondition(c, ret, ret)}, nil
+}
+
+func (e *comparativeEvaluator) CompareDetailed(vars Variables) (bool, []Condition, error) {
+	v1, err := e.x.Eval(vars)
+	if err != nil {
+		return false, nil, fmt.Errorf("Eval(`%s`) %w", e, err)
+	}
+	v2, err := e.y.Eval(vars)
+	if err != nil {
+		return false, nil, fmt.Errorf("Eval(`%s`) %w", e, err)
+	}
+	ret, err := e.f(v1, v2)
+	if err != nil {
+		return false, nil, fmt.Errorf("Eval(`%s`) %w", e, err)
+	}
+	annotated := e.withChildren([]Evaluator{annotateOperand(e.x, v1), annotateOperand(e.y, v2)})
+	return ret, []Condition{
+		{
+			Expr:      e.String(),
+			Op:        e.op,
+			XExpr:     e.x.String(),
+			YExpr:     e.y.String(),
+			X:         v1,
+			Y:         v2,
+			Result:    ret,
+			annotated: annotated.String(),
+		},
+	}, nil
+}
+
+func (e *logicalEvaluator) CompareDetailed(vars Variables) (bool, []Condition, error) {
+	b1, c1, err := compareDetailed(e.x, vars)
+	if err != nil {
+		return false, nil, fmt.Errorf("Eval(`%s`) %w", e, err)
+	}
+	b2, c2, err := compareDetailed(e.y, vars)
+	if err != nil {
+		return false, nil, fmt.Errorf("Eval(`%s`) %w", e, err)
+	}
+	ret := e.f(b1, b2)
+	// the decisive value is false for `&&` and true for `||`
+	decisive := e.op == "||"
+	if ret != decisive {
+		return ret, append(c1, c2...), nil
+	}
+	conditions := make([]Condition, 0, len(c1)+len(c2))
+	if b1 == decisive {
+		conditions = append(conditions, c1...)
+	}
+	if b2 == decisive {
+		conditions = append(conditions, c2...)
+	}
+	return ret, conditions, nil
+}
+
+func compareDetailed(e Evaluator, vars Variables) (bool, []Condition, error) {
+	if p, ok := e.(*parenEvaluator); ok {
+		return compareDetailed(p.x, vars)
+	}
+	if c, ok := e.(DetailedComparator); ok {
+		return c.CompareDetailed(vars)
+	}
+	v, err := e.Eval(vars)
+	if err != nil {
+		return false, nil, err
+	}
+	b, ok := isBool(v)
+	if !ok {
+		return false, nil, fmt.Errorf("v[%v]::%T is not bool", v, v)
+	}
+	return b, []Condition{valueCondition(e, v, b)}, nil
+}
+
+// valueCondition is the condition which is not a comparison, like `string_contains(var1, "a")`
+func valueCondition(e fmt.Stringer, v interface{}, b bool) Condition {
+	return Condition{
+		Expr:      e.String(),
+		XExpr:     e.String(),
+		X:         v,
+		Result:    b,
+		annotated: fmt.Sprintf("%s=%s", e, formatValue(v)),
+	}
+}
+
+func annotateOperand(e Evaluator, v interface{}) Evaluator {
+	if isLiteralEvaluator(e) {
+		return e
+	}
+	switch e.(type) {
+	case *computableEvaluator:
+		return labelEvaluator(fmt.Sprintf("(%s)=%s", e, formatValue(v)))
+	default:
+		return labelEvaluator(fmt.Sprintf("%s=%s", e, formatValue(v)))
+	}
+}
@@ -0,0 +1,92 @@
+package evaluator_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/mashiike/evaluator"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComparatorCompareDetailed(t *testing.T) {
+	cases := []struct {
+		expr      string
+		variables evaluator.Variables
+		expected  bool
+		reported  []string
+	}{
+		{
+			expr:      "1.0 <= var1 <= 5",
+			variables: evaluator.Variables{"var1": 10},
+			expected:  false,
+			reported:  []string{"var1=10 <= 5"},
+		},
+		{
+			expr:      "1.0 <= var1 <= 5",
+			variables: evaluator.Variables{"var1": 3},
+			expected:  true,
+			reported:  []string{"1.0 <= var1=3", "var1=3 <= 5"},
+		},
+		{
+			expr:      "(var1 > 1) && (var2 > 1) && var3 + 1 > 1",
+			variables: evaluator.Variables{"var1": 0, "var2": 2, "var3": -1},
+			expected:  false,
+			reported:  []string{"var1=0 > 1", "(var3 + 1)=0 > 1"},
+		},
+		{
+			expr:      "var1 > 1 || var2 > 1 || string_contains(var3, `a`)",
+			variables: evaluator.Variables{"var1": 0, "var2": 2, "var3": "abc"},
+			expected:  true,
+			reported:  []string{"var2=2 > 1", `string_contains(var3, "a")=true`},
+		},
+		{
+			expr:      "(var1 > 1 || var2 > 1) && var3 == `abc`",
+			variables: evaluator.Variables{"var1": 0, "var2": 0, "var3": "abc"},
+			expected:  false,
+			reported:  []string{"var1=0 > 1", "var2=0 > 1"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.expr, func(t *testing.T) {
+			e, err := evaluator.New(c.expr)
+			require.NoError(t, err, "must parse success")
+			comparator, ok := e.AsComparator()
+			require.True(t, ok, "must be comparator")
+			actual, conditions, err := evaluator.CompareDetailed(comparator, c.variables)
+			require.NoError(t, err, "must compare success")
+			require.Equal(t, c.expected, actual)
+			reported := make([]string, 0, len(conditions))
+			for _, condition := range conditions {
+				reported = append(reported, condition.String())
+			}
+			require.EqualValues(t, c.reported, reported)
+		})
+	}
+}
+
+// thresholdComparator is a Comparator implemented outside of the package, which is not a DetailedComparator.
+type thresholdComparator float64
+
+func (c thresholdComparator) Compare(vars evaluator.Variables) (bool, error) {
+	v, _ := vars.Lookup("value")
+	n, ok := v.(float64)
+	if !ok {
+		return false, errors.New("value is not float64")
+	}
+	return n > float64(c), nil
+}
+
+func (c thresholdComparator) String() string { return fmt.Sprintf("value > %v", float64(c)) }
+
+func TestCompareDetailedNotDetailedComparator(t *testing.T) {
+	actual, conditions, err := evaluator.CompareDetailed(thresholdComparator(1), evaluator.Variables{"value": 2.0})
+	require.NoError(t, err)
+	require.True(t, actual)
+	require.Len(t, conditions, 1)
+	require.Equal(t, "value > 1", conditions[0].Expr)
+	require.Equal(t, "value > 1=true", conditions[0].String())
+
+	_, _, err = evaluator.CompareDetailed(thresholdComparator(1), evaluator.Variables{})
+	require.EqualError(t, err, "value is not float64")
+}
@@ -0,0 +1,157 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConformanceCase is a case of the conformance corpus, which pins down the result of an expression for the variables.
+// The corpus is a list of the cases written in YAML or JSON, like:
+//
+//	# arithmetic.yaml
+//	- expr: (var1 + 0.5) * var2
+//	  vars: {var1: 0.5, var2: 3}
+//	  expected: 3
+//	- expr: var1 / var2
+//	  vars: {var1: 1, var2: 0}
+//	  error: divide_by_zero
+//
+// The numbers are compared as float64, so `3` and `3.0` are the same.
+type ConformanceCase struct {
+	Name     string      `yaml:"name,omitempty" json:"name,omitempty"`
+	Expr     string      `yaml:"expr" json:"expr"`
+	Vars     Variables   `yaml:"vars,omitempty" json:"vars,omitempty"`
+	Expected interface{} `yaml:"expected,omitempty" json:"expected,omitempty"`
+	// Error is the kind of the expected error, empty if the evaluation succeeds.
+	// The kinds are `parse`, `eval`, `arguments`, `divide_by_zero`, `variable_not_found` and `any`.
+	Error string `yaml:"error,omitempty" json:"error,omitempty"`
+	// Message is the expected message of the error, not checked if empty.
+	Message string `yaml:"message,omitempty" json:"message,omitempty"`
+	// Strict evaluates the expression in strict mode.
+	Strict bool `yaml:"strict,omitempty" json:"strict,omitempty"`
+	// Coercion is false to parse the expression WithoutCoercion, the implicit conversions are applied if nil or true.
+	Coercion *bool `yaml:"coercion,omitempty" json:"coercion,omitempty"`
+}
+
+// LoadConformanceCases reads the cases of the conformance corpus in YAML or JSON.
+func LoadConformanceCases(r io.Reader) ([]ConformanceCase, error) {
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	var cases []ConformanceCase
+	if err := dec.Decode(&cases); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("LoadConformanceCases: %w", err)
+	}
+	for i, c := range cases {
+		if c.Name == "" {
+			cases[i].Name = c.Expr
+		}
+	}
+	return cases, nil
+}
+
+// Run evaluates the expression of the case, and returns an error if the result is different from the expected.
+func (c *ConformanceCase) Run() error {
+	ret, err, parsed := c.eval()
+	if c.Error == "" {
+		if err != nil {
+			return fmt.Errorf("Conformance(`%s`) expected %s, but got error: %w", c.Expr, formatConformanceValue(c.Expected), err)
+		}
+		if !sameConformanceValue(c.Expected, ret) {
+			return fmt.Errorf("Conformance(`%s`) expected %s, but got %s", c.Expr, formatConformanceValue(c.Expected), formatConformanceValue(ret))
+		}
+		return nil
+	}
+	if err == nil {
+		return fmt.Errorf("Conformance(`%s`) expected %s error, but got %s", c.Expr, c.Error, formatConformanceValue(ret))
+	}
+	var ok bool
+	switch c.Error {
+	case "any":
+		ok = true
+	case "parse":
+		ok = !parsed
+	case "eval":
+		ok = parsed
+	case "arguments":
+		var mismatch *NumOfArgumentsMismatchError
+		ok = errors.As(err, &mismatch)
+	case "divide_by_zero":
+		ok = IsDivideByZero(err)
+	case "variable_not_found":
+		ok = IsVariableNotFound(err)
+	default:
+		return fmt.Errorf("Conformance(`%s`) unknown error kind `%s`", c.Expr, c.Error)
+	}
+	if !ok {
+		return fmt.Errorf("Conformance(`%s`) expected %s error, but got: %w", c.Expr, c.Error, err)
+	}
+	if c.Message != "" && c.Message != err.Error() {
+		return fmt.Errorf("Conformance(`%s`) expected error message %q, but got %q", c.Expr, c.Message, err.Error())
+	}
+	return nil
+}
+
+// eval returns the result of the case, parsed is false if the expression is not parsed.
+func (c *ConformanceCase) eval() (ret interface{}, err error, parsed bool) {
+	var opts []Option
+	if c.Coercion != nil && !*c.Coercion {
+		opts = append(opts, WithoutCoercion())
+	}
+	e, err := New(c.Expr, opts...)
+	if err != nil {
+		return nil, err, false
+	}
+	e.Strict(c.Strict)
+	ret, err = e.Eval(c.Vars)
+	return ret, err, true
+}
+
+func sameConformanceValue(expected, actual interface{}) bool {
+	if n1, ok := isRealNumber(expected); ok {
+		n2, ok := isRealNumber(actual)
+		if !ok {
+			return false
+		}
+		return n1 == n2 || (math.IsNaN(n1) && math.IsNaN(n2))
+	}
+	if l1, ok := expected.([]interface{}); ok {
+		l2, ok := isList(actual)
+		if !ok || len(l1) != len(l2) {
+			return false
+		}
+		for i := range l1 {
+			if !sameConformanceValue(l1[i], l2[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	if m1, ok := expected.(map[string]interface{}); ok {
+		m2, ok := actual.(map[string]interface{})
+		if !ok || len(m1) != len(m2) {
+			return false
+		}
+		for k, v := range m1 {
+			if !sameConformanceValue(v, m2[k]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(expected, actual)
+}
+
+func formatConformanceValue(v interface{}) string {
+	if v == nil {
+		return "nil"
+	}
+	return fmt.Sprintf("v[%v]::%T", v, v)
+}
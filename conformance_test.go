@@ -0,0 +1,78 @@
+package evaluator_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mashiike/evaluator"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConformance(t *testing.T) {
+	files, err := filepath.Glob("testdata/conformance/*.yaml")
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			f, err := os.Open(file)
+			require.NoError(t, err)
+			defer f.Close()
+			cases, err := evaluator.LoadConformanceCases(f)
+			require.NoError(t, err)
+			require.NotEmpty(t, cases)
+			for _, c := range cases {
+				c := c
+				t.Run(c.Name, func(t *testing.T) {
+					require.NoError(t, c.Run())
+				})
+			}
+		})
+	}
+}
+
+func TestConformanceMismatch(t *testing.T) {
+	cases := []struct {
+		corpus      string
+		expectedErr string
+	}{
+		{
+			corpus:      "- {expr: 'var1 + 1', vars: {var1: 1}, expected: 3}",
+			expectedErr: "Conformance(`var1 + 1`) expected v[3]::int, but got v[2]::float64",
+		},
+		{
+			corpus:      "- {expr: 'var1 / 0', vars: {var1: 1}, expected: 3}",
+			expectedErr: "Conformance(`var1 / 0`) expected v[3]::int, but got error: Eval(`var1 / 0`) divide by 0",
+		},
+		{
+			corpus:      "- {expr: 'var1', error: any}",
+			expectedErr: "Conformance(`var1`) expected any error, but got nil",
+		},
+		{
+			corpus:      "- {expr: 'var1 / 0', vars: {var1: 1}, error: parse}",
+			expectedErr: "Conformance(`var1 / 0`) expected parse error, but got: Eval(`var1 / 0`) divide by 0",
+		},
+		{
+			corpus:      "- {expr: 'var1 / 0', vars: {var1: 1}, error: divide_by_zero, message: hoge}",
+			expectedErr: "Conformance(`var1 / 0`) expected error message \"hoge\", but got \"Eval(`var1 / 0`) divide by 0\"",
+		},
+		{
+			corpus:      "- {expr: 'var1 / 0', error: hoge}",
+			expectedErr: "Conformance(`var1 / 0`) unknown error kind `hoge`",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.corpus, func(t *testing.T) {
+			loaded, err := evaluator.LoadConformanceCases(strings.NewReader(c.corpus))
+			require.NoError(t, err)
+			require.Len(t, loaded, 1)
+			require.EqualError(t, loaded[0].Run(), c.expectedErr)
+		})
+	}
+}
+
+func TestLoadConformanceCasesUnknownField(t *testing.T) {
+	_, err := evaluator.LoadConformanceCases(strings.NewReader(`[{"expr": "var1", "lenient": true}]`))
+	require.Error(t, err)
+}
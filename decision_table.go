@@ -0,0 +1,659 @@
+package evaluator
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// HitPolicy decides which rules of DecisionTable are returned when several rules match.
+type HitPolicy string
+
+// The hit policies of DecisionTable
+const (
+	// HitPolicyUnique expects at most one rule matches, the rules must not overlap. It is the default.
+	HitPolicyUnique HitPolicy = "unique"
+	// HitPolicyFirst returns the first matched rule in the order of the rules.
+	HitPolicyFirst HitPolicy = "first"
+	// HitPolicyCollect returns all matched rules in the order of the rules.
+	HitPolicyCollect HitPolicy = "collect"
+)
+
+// DecisionTableDefinition is the source of DecisionTable.
+//
+// Each rule is a row of the cells of the inputs followed by the cells of the outputs.
+// The input cell is a condition of the input expression:
+//
+//	-, or empty       matches any value
+//	< 10, >= 10, != 0 compares the input with the value, `=` and `==` are equality
+//	"a", "b"          matches one of the values
+//	10                matches the value
+//	other expression  is evaluated as the condition itself, like `has_prefix(region, "us")`
+//
+// The output cell is an expression, `-` or empty is nil.
+type DecisionTableDefinition struct {
+	HitPolicy HitPolicy `json:"hit_policy"`
+	// AllowGaps disables the validation that every combination of the inputs is matched by a rule.
+	AllowGaps bool       `json:"allow_gaps"`
+	Inputs    []string   `json:"inputs"`
+	Outputs   []string   `json:"outputs"`
+	Rules     [][]string `json:"rules"`
+}
+
+// DecisionResult is an output of the matched rule of DecisionTable.
+type DecisionResult struct {
+	// Rule is the index of the matched rule.
+	Rule int
+	// Outputs are the evaluated outputs by the names.
+	Outputs map[string]interface{}
+}
+
+// DecisionTableError is the validation errors of DecisionTable, like the overlaps and the gaps of the rules.
+type DecisionTableError struct {
+	Issues []string
+}
+
+func (e *DecisionTableError) Error() string {
+	return "invalid decision table: " + strings.Join(e.Issues, "; ")
+}
+
+// DecisionTable is the table of the rules which map the conditions of the inputs to the outputs.
+type DecisionTable struct {
+	hitPolicy HitPolicy
+	inputs    []string
+	outputs   []string
+	rules     []*decisionRule
+}
+
+type decisionRule struct {
+	conditions []Evaluator
+	cells      []cellSet
+	outputs    []Evaluator
+}
+
+// NewDecisionTable compiles the cells of the definition with the options of New, and validates the rules.
+// The overlaps of the rules are invalid for HitPolicyUnique, and the gaps are invalid unless AllowGaps.
+// The cells which are neither constants nor comparisons with constants are not validated.
+func NewDecisionTable(def DecisionTableDefinition, opts ...Option) (*DecisionTable, error) {
+	t := &DecisionTable{
+		hitPolicy: def.HitPolicy,
+		outputs:   def.Outputs,
+		rules:     make([]*decisionRule, 0, len(def.Rules)),
+	}
+	switch t.hitPolicy {
+	case "":
+		t.hitPolicy = HitPolicyUnique
+	case HitPolicyUnique, HitPolicyFirst, HitPolicyCollect:
+	default:
+		return nil, fmt.Errorf("unknown hit policy `%s`", def.HitPolicy)
+	}
+	if len(def.Outputs) == 0 {
+		return nil, fmt.Errorf("decision table has no outputs")
+	}
+	for i, input := range def.Inputs {
+		e, err := New(input, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("inputs[%d] %w", i, err)
+		}
+		t.inputs = append(t.inputs, parenthesize(unwrap(e)))
+	}
+	numOfCells := len(def.Inputs) + len(def.Outputs)
+	for i, cells := range def.Rules {
+		if len(cells) != numOfCells {
+			return nil, fmt.Errorf("rules[%d] has %d cells, but expected %d cells", i, len(cells), numOfCells)
+		}
+		rule := &decisionRule{}
+		for j, cell := range cells[:len(def.Inputs)] {
+			cond, set, err := compileInputCell(t.inputs[j], cell, opts...)
+			if err != nil {
+				return nil, fmt.Errorf("rules[%d] inputs[%d] `%s` %w", i, j, cell, err)
+			}
+			if cond != nil {
+				rule.conditions = append(rule.conditions, cond)
+			}
+			rule.cells = append(rule.cells, set)
+		}
+		for j, cell := range cells[len(def.Inputs):] {
+			cell = strings.TrimSpace(cell)
+			if cell == "" || cell == "-" {
+				rule.outputs = append(rule.outputs, nilEvaluator{})
+				continue
+			}
+			e, err := New(cell, opts...)
+			if err != nil {
+				return nil, fmt.Errorf("rules[%d] outputs[%d] %w", i, j, err)
+			}
+			rule.outputs = append(rule.outputs, e)
+		}
+		t.rules = append(t.rules, rule)
+	}
+	var issues []string
+	if t.hitPolicy == HitPolicyUnique {
+		issues = append(issues, t.overlaps()...)
+	}
+	if !def.AllowGaps {
+		issues = append(issues, t.gaps()...)
+	}
+	if len(issues) > 0 {
+		return nil, &DecisionTableError{Issues: issues}
+	}
+	return t, nil
+}
+
+// LoadDecisionTable decodes DecisionTableDefinition from JSON to create a DecisionTable.
+func LoadDecisionTable(r io.Reader, opts ...Option) (*DecisionTable, error) {
+	var def DecisionTableDefinition
+	if err := json.NewDecoder(r).Decode(&def); err != nil {
+		return nil, fmt.Errorf("decode decision table: %w", err)
+	}
+	return NewDecisionTable(def, opts...)
+}
+
+// ParseDecisionTableCSV reads the rules from CSV, the header is the inputs followed by the outputs prefixed by `out:`, like:
+//
+//	cpu_usage,region,out:severity
+//	>= 90,-,"""critical"""
+//	< 90,"""us"",""eu""","""warning"""
+//
+// The hit policy and AllowGaps are not set.
+func ParseDecisionTableCSV(r io.Reader) (DecisionTableDefinition, error) {
+	var def DecisionTableDefinition
+	reader := csv.NewReader(r)
+	records, err := reader.ReadAll()
+	if err != nil {
+		return def, err
+	}
+	if len(records) == 0 {
+		return def, fmt.Errorf("decision table has no header")
+	}
+	for _, name := range records[0] {
+		name = strings.TrimSpace(name)
+		if strings.HasPrefix(name, "out:") {
+			def.Outputs = append(def.Outputs, strings.TrimSpace(strings.TrimPrefix(name, "out:")))
+			continue
+		}
+		if len(def.Outputs) > 0 {
+			return def, fmt.Errorf("input `%s` must be before the outputs", name)
+		}
+		def.Inputs = append(def.Inputs, name)
+	}
+	def.Rules = records[1:]
+	return def, nil
+}
+
+// Strict sets the conditions and the outputs to run in strict mode.
+func (t *DecisionTable) Strict(v bool) {
+	for _, rule := range t.rules {
+		for _, cond := range rule.conditions {
+			cond.Strict(v)
+		}
+		for _, output := range rule.outputs {
+			output.Strict(v)
+		}
+	}
+}
+
+// Evaluate returns the outputs of the matched rules by the hit policy, it is empty if no rule matches.
+func (t *DecisionTable) Evaluate(vars Variables) ([]DecisionResult, error) {
+	var results []DecisionResult
+	for i, rule := range t.rules {
+		matched, err := rule.match(vars)
+		if err != nil {
+			return nil, fmt.Errorf("rules[%d] %w", i, err)
+		}
+		if !matched {
+			continue
+		}
+		if t.hitPolicy == HitPolicyUnique && len(results) > 0 {
+			return nil, fmt.Errorf("rules[%d] and rules[%d] matched, but the hit policy is unique", results[0].Rule, i)
+		}
+		result := DecisionResult{
+			Rule:    i,
+			Outputs: make(map[string]interface{}, len(t.outputs)),
+		}
+		for j, output := range rule.outputs {
+			v, err := output.Eval(vars)
+			if err != nil {
+				return nil, fmt.Errorf("rules[%d] outputs[%d] %w", i, j, err)
+			}
+			result.Outputs[t.outputs[j]] = v
+		}
+		results = append(results, result)
+		if t.hitPolicy == HitPolicyFirst {
+			break
+		}
+	}
+	return results, nil
+}
+
+func (r *decisionRule) match(vars Variables) (bool, error) {
+	for _, cond := range r.conditions {
+		v, err := cond.Eval(vars)
+		if err != nil {
+			return false, err
+		}
+		b, ok := isBool(v)
+		if !ok {
+			return false, fmt.Errorf("Eval(`%s`) v[%v]::%T is not bool", cond, v, v)
+		}
+		if !b {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+var cellOperators = map[token.Token]string{
+	token.LSS:    "<",
+	token.LEQ:    "<=",
+	token.GTR:    ">",
+	token.GEQ:    ">=",
+	token.EQL:    "==",
+	token.ASSIGN: "==",
+	token.NEQ:    "!=",
+}
+
+// compileInputCell returns the condition of the cell, nil for any value, and the set of the values for the validation.
+func compileInputCell(input string, cell string, opts ...Option) (Evaluator, cellSet, error) {
+	cell = strings.TrimSpace(cell)
+	if cell == "" || cell == "-" {
+		return nil, cellSet{kind: cellAny}, nil
+	}
+	tokens := scanTokens(cell)
+	if len(tokens) == 0 {
+		return nil, cellSet{}, fmt.Errorf("cell is empty")
+	}
+	if op, ok := cellOperators[tokens[0].tok]; ok {
+		value, err := New(cell[tokens[0].offset+len(tokens[0].tok.String()):], opts...)
+		if err != nil {
+			return nil, cellSet{}, err
+		}
+		// the options like WithCostBudget wrap the value, which is validated as the literal
+		value = unwrap(value)
+		cond, err := New(fmt.Sprintf("%s %s %s", input, op, parenthesize(value)), opts...)
+		if err != nil {
+			return nil, cellSet{}, err
+		}
+		return cond, newComparisonCellSet(op, value), nil
+	}
+	parts := splitTopLevel(cell, tokens)
+	values := make([]Evaluator, 0, len(parts))
+	for _, part := range parts {
+		value, err := New(part, opts...)
+		if err != nil {
+			return nil, cellSet{}, err
+		}
+		if len(parts) == 1 && !isLiteralEvaluator(unwrap(value)) {
+			return value, cellSet{kind: cellUnknown}, nil
+		}
+		values = append(values, unwrap(value))
+	}
+	conds := make([]string, 0, len(values))
+	for _, value := range values {
+		conds = append(conds, fmt.Sprintf("%s == %s", input, parenthesize(value)))
+	}
+	cond, err := New(strings.Join(conds, " || "), opts...)
+	if err != nil {
+		return nil, cellSet{}, err
+	}
+	return cond, newValuesCellSet(values), nil
+}
+
+// splitTopLevel splits the cell by the commas which are not enclosed in the brackets.
+func splitTopLevel(cell string, tokens []scannedToken) []string {
+	var parts []string
+	depth, last := 0, 0
+	for _, tok := range tokens {
+		switch tok.tok {
+		case token.LPAREN, token.LBRACK, token.LBRACE:
+			depth++
+		case token.RPAREN, token.RBRACK, token.RBRACE:
+			depth--
+		case token.COMMA:
+			if depth == 0 {
+				parts = append(parts, cell[last:tok.offset])
+				last = tok.offset + 1
+			}
+		}
+	}
+	return append(parts, cell[last:])
+}
+
+type cellKind int
+
+const (
+	cellAny cellKind = iota
+	cellNumbers
+	cellStrings
+	cellUnknown
+)
+
+// cellSet is the set of the values matched by the cell, the numbers are the intervals,
+// and the strings are the values or the complement of the values if negated.
+type cellSet struct {
+	kind      cellKind
+	intervals []interval
+	strs      map[string]bool
+	negated   bool
+}
+
+type interval struct {
+	lo, hi         float64
+	loOpen, hiOpen bool
+}
+
+func (iv interval) contains(v float64) bool {
+	if v < iv.lo || (v == iv.lo && iv.loOpen) {
+		return false
+	}
+	if v > iv.hi || (v == iv.hi && iv.hiOpen) {
+		return false
+	}
+	return true
+}
+
+func (iv interval) intersects(other interval) bool {
+	lo, loOpen := iv.lo, iv.loOpen
+	if other.lo > lo || (other.lo == lo && other.loOpen) {
+		lo, loOpen = other.lo, other.loOpen
+	}
+	hi, hiOpen := iv.hi, iv.hiOpen
+	if other.hi < hi || (other.hi == hi && other.hiOpen) {
+		hi, hiOpen = other.hi, other.hiOpen
+	}
+	return lo < hi || (lo == hi && !loOpen && !hiOpen)
+}
+
+func newComparisonCellSet(op string, value Evaluator) cellSet {
+	switch value := value.(type) {
+	case *realNumericLiteralEvaluator:
+		v := value.value
+		inf := math.Inf(1)
+		switch op {
+		case "<":
+			return cellSet{kind: cellNumbers, intervals: []interval{{lo: -inf, hi: v, loOpen: true, hiOpen: true}}}
+		case "<=":
+			return cellSet{kind: cellNumbers, intervals: []interval{{lo: -inf, hi: v, loOpen: true}}}
+		case ">":
+			return cellSet{kind: cellNumbers, intervals: []interval{{lo: v, hi: inf, loOpen: true, hiOpen: true}}}
+		case ">=":
+			return cellSet{kind: cellNumbers, intervals: []interval{{lo: v, hi: inf, hiOpen: true}}}
+		case "==":
+			return cellSet{kind: cellNumbers, intervals: []interval{{lo: v, hi: v}}}
+		case "!=":
+			return cellSet{kind: cellNumbers, intervals: []interval{
+				{lo: -inf, hi: v, loOpen: true, hiOpen: true},
+				{lo: v, hi: inf, loOpen: true, hiOpen: true},
+			}}
+		}
+	case *stringLiteralEvaluator:
+		switch op {
+		case "==":
+			return cellSet{kind: cellStrings, strs: map[string]bool{value.str: true}}
+		case "!=":
+			return cellSet{kind: cellStrings, strs: map[string]bool{value.str: true}, negated: true}
+		}
+	}
+	return cellSet{kind: cellUnknown}
+}
+
+func newValuesCellSet(values []Evaluator) cellSet {
+	set := cellSet{kind: cellUnknown}
+	for _, value := range values {
+		switch value := value.(type) {
+		case *realNumericLiteralEvaluator:
+			if set.kind != cellUnknown && set.kind != cellNumbers {
+				return cellSet{kind: cellUnknown}
+			}
+			set.kind = cellNumbers
+			set.intervals = append(set.intervals, interval{lo: value.value, hi: value.value})
+		case *stringLiteralEvaluator:
+			if set.kind != cellUnknown && set.kind != cellStrings {
+				return cellSet{kind: cellUnknown}
+			}
+			if set.strs == nil {
+				set.strs = make(map[string]bool)
+			}
+			set.kind = cellStrings
+			set.strs[value.str] = true
+		default:
+			return cellSet{kind: cellUnknown}
+		}
+	}
+	return set
+}
+
+func (s cellSet) intersects(other cellSet) bool {
+	if s.kind == cellAny || other.kind == cellAny {
+		return true
+	}
+	if s.kind != other.kind {
+		return false
+	}
+	if s.kind == cellNumbers {
+		for _, iv := range s.intervals {
+			for _, o := range other.intervals {
+				if iv.intersects(o) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	if s.negated && other.negated {
+		return true
+	}
+	if s.negated {
+		s, other = other, s
+	}
+	for str := range s.strs {
+		if other.strs[str] != other.negated {
+			return true
+		}
+	}
+	return false
+}
+
+// cellValue is a representative value of an input for the validation of the gaps.
+type cellValue struct {
+	kind  cellKind
+	num   float64
+	str   string
+	other bool
+}
+
+func (v cellValue) String() string {
+	switch {
+	case v.kind == cellNumbers:
+		return strconv.FormatFloat(v.num, 'g', -1, 64)
+	case v.other:
+		return "other string"
+	default:
+		return strconv.Quote(v.str)
+	}
+}
+
+func (s cellSet) contains(v cellValue) bool {
+	switch {
+	case s.kind == cellAny || v.kind == cellAny:
+		return true
+	case s.kind != v.kind:
+		return false
+	case s.kind == cellNumbers:
+		for _, iv := range s.intervals {
+			if iv.contains(v.num) {
+				return true
+			}
+		}
+		return false
+	case v.other:
+		return s.negated
+	default:
+		return s.strs[v.str] != s.negated
+	}
+}
+
+func (t *DecisionTable) overlaps() []string {
+	var issues []string
+	for i := range t.rules {
+		for j := i + 1; j < len(t.rules); j++ {
+			if t.rules[i].overlaps(t.rules[j]) {
+				issues = append(issues, fmt.Sprintf("rules[%d] and rules[%d] overlap", i, j))
+			}
+		}
+	}
+	return issues
+}
+
+// overlaps reports whether the rules certainly match the same inputs.
+func (r *decisionRule) overlaps(other *decisionRule) bool {
+	for k := range r.cells {
+		if r.cells[k].kind == cellUnknown || other.cells[k].kind == cellUnknown {
+			return false
+		}
+		if !r.cells[k].intersects(other.cells[k]) {
+			return false
+		}
+	}
+	return true
+}
+
+// maxGapCandidates limits the combinations of the representative values to validate the gaps.
+const maxGapCandidates = 10000
+
+// maxGapIssues limits the reported gaps.
+const maxGapIssues = 3
+
+func (t *DecisionTable) gaps() []string {
+	for _, rule := range t.rules {
+		for _, cell := range rule.cells {
+			if cell.kind == cellUnknown {
+				return nil
+			}
+		}
+	}
+	candidates := make([][]cellValue, len(t.inputs))
+	total := 1
+	for k := range t.inputs {
+		candidates[k] = t.representatives(k)
+		total *= len(candidates[k])
+		if total > maxGapCandidates {
+			return nil
+		}
+	}
+	var issues []string
+	point := make([]cellValue, len(t.inputs))
+	var visit func(k int) bool
+	visit = func(k int) bool {
+		if k == len(t.inputs) {
+			if !t.covers(point) {
+				issues = append(issues, "no rule matches "+t.formatPoint(point))
+			}
+			return len(issues) < maxGapIssues
+		}
+		for _, v := range candidates[k] {
+			point[k] = v
+			if !visit(k + 1) {
+				return false
+			}
+		}
+		return true
+	}
+	visit(0)
+	return issues
+}
+
+// representatives returns the values which cover all regions split by the cells of the input.
+func (t *DecisionTable) representatives(k int) []cellValue {
+	var bounds []float64
+	strs := make(map[string]bool)
+	hasNumbers, hasStrings := false, false
+	for _, rule := range t.rules {
+		cell := rule.cells[k]
+		switch cell.kind {
+		case cellNumbers:
+			hasNumbers = true
+			for _, iv := range cell.intervals {
+				for _, b := range []float64{iv.lo, iv.hi} {
+					if !math.IsInf(b, 0) {
+						bounds = append(bounds, b)
+					}
+				}
+			}
+		case cellStrings:
+			hasStrings = true
+			for str := range cell.strs {
+				strs[str] = true
+			}
+		}
+	}
+	var values []cellValue
+	if hasNumbers {
+		sort.Float64s(bounds)
+		if len(bounds) == 0 {
+			values = append(values, cellValue{kind: cellNumbers})
+		}
+		for i, b := range bounds {
+			if i > 0 && b == bounds[i-1] {
+				continue
+			}
+			if i == 0 {
+				values = append(values, cellValue{kind: cellNumbers, num: b - 1})
+			} else {
+				values = append(values, cellValue{kind: cellNumbers, num: (bounds[i-1] + b) / 2})
+			}
+			values = append(values, cellValue{kind: cellNumbers, num: b})
+		}
+		if len(bounds) > 0 {
+			values = append(values, cellValue{kind: cellNumbers, num: bounds[len(bounds)-1] + 1})
+		}
+	}
+	if hasStrings {
+		names := make([]string, 0, len(strs))
+		for str := range strs {
+			names = append(names, str)
+		}
+		sort.Strings(names)
+		for _, str := range names {
+			values = append(values, cellValue{kind: cellStrings, str: str})
+		}
+		values = append(values, cellValue{kind: cellStrings, other: true})
+	}
+	if len(values) == 0 {
+		values = append(values, cellValue{kind: cellAny})
+	}
+	return values
+}
+
+func (t *DecisionTable) covers(point []cellValue) bool {
+	for _, rule := range t.rules {
+		matched := true
+		for k, cell := range rule.cells {
+			if !cell.contains(point[k]) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *DecisionTable) formatPoint(point []cellValue) string {
+	parts := make([]string, 0, len(point))
+	for k, v := range point {
+		if v.kind == cellAny {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s = %s", t.inputs[k], v))
+	}
+	return strings.Join(parts, ", ")
+}
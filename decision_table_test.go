@@ -0,0 +1,242 @@
+package evaluator_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mashiike/evaluator"
+	"github.com/stretchr/testify/require"
+)
+
+const testDecisionTableCSV = `cpu_usage,region,out:severity,out:score
+>= 90,-,"""critical""",cpu_usage * 2
+< 90,"""us"",""eu""","""warning""",1
+< 90,"!= ""us""","""ok""",0
+`
+
+func TestDecisionTable(t *testing.T) {
+	def, err := evaluator.ParseDecisionTableCSV(strings.NewReader(testDecisionTableCSV))
+	require.NoError(t, err)
+	require.Equal(t, []string{"cpu_usage", "region"}, def.Inputs)
+	require.Equal(t, []string{"severity", "score"}, def.Outputs)
+
+	def.HitPolicy = evaluator.HitPolicyFirst
+	table, err := evaluator.NewDecisionTable(def)
+	require.NoError(t, err)
+
+	cases := []struct {
+		name     string
+		policy   evaluator.HitPolicy
+		vars     evaluator.Variables
+		expected []evaluator.DecisionResult
+	}{
+		{
+			name:   "first critical",
+			policy: evaluator.HitPolicyFirst,
+			vars:   evaluator.Variables{"cpu_usage": 95, "region": "us"},
+			expected: []evaluator.DecisionResult{
+				{Rule: 0, Outputs: map[string]interface{}{"severity": "critical", "score": 190.0}},
+			},
+		},
+		{
+			name:   "first warning",
+			policy: evaluator.HitPolicyFirst,
+			vars:   evaluator.Variables{"cpu_usage": 50, "region": "eu"},
+			expected: []evaluator.DecisionResult{
+				{Rule: 1, Outputs: map[string]interface{}{"severity": "warning", "score": 1.0}},
+			},
+		},
+		{
+			name:   "collect",
+			policy: evaluator.HitPolicyCollect,
+			vars:   evaluator.Variables{"cpu_usage": 50, "region": "eu"},
+			expected: []evaluator.DecisionResult{
+				{Rule: 1, Outputs: map[string]interface{}{"severity": "warning", "score": 1.0}},
+				{Rule: 2, Outputs: map[string]interface{}{"severity": "ok", "score": 0.0}},
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			def.HitPolicy = c.policy
+			table, err := evaluator.NewDecisionTable(def)
+			require.NoError(t, err)
+			actual, err := table.Evaluate(c.vars)
+			require.NoError(t, err)
+			require.Equal(t, c.expected, actual)
+		})
+	}
+
+	table.Strict(true)
+	_, err = table.Evaluate(evaluator.Variables{"cpu_usage": 50})
+	require.True(t, evaluator.IsVariableNotFound(err))
+}
+
+func TestDecisionTableUnique(t *testing.T) {
+	table, err := evaluator.NewDecisionTable(evaluator.DecisionTableDefinition{
+		Inputs:  []string{"age", "member"},
+		Outputs: []string{"discount"},
+		Rules: [][]string{
+			{"< 18", "-", "0.5"},
+			{">= 18", "`gold`", "0.2"},
+			{">= 18", "`silver`, `bronze`", "0.1"},
+			{">= 18", "member != `gold` && member != `silver` && member != `bronze`", "0"},
+		},
+	})
+	require.NoError(t, err)
+	actual, err := table.Evaluate(evaluator.Variables{"age": 30, "member": "silver"})
+	require.NoError(t, err)
+	require.Equal(t, []evaluator.DecisionResult{{Rule: 2, Outputs: map[string]interface{}{"discount": 0.1}}}, actual)
+
+	actual, err = table.Evaluate(evaluator.Variables{"age": 30, "member": "gold"})
+	require.NoError(t, err)
+	require.Equal(t, []evaluator.DecisionResult{{Rule: 1, Outputs: map[string]interface{}{"discount": 0.2}}}, actual)
+}
+
+func TestDecisionTableInvalid(t *testing.T) {
+	cases := []struct {
+		name     string
+		def      evaluator.DecisionTableDefinition
+		expected string
+	}{
+		{
+			name: "overlap",
+			def: evaluator.DecisionTableDefinition{
+				AllowGaps: true,
+				Inputs:    []string{"x", "y"},
+				Outputs:   []string{"out"},
+				Rules: [][]string{
+					{"<= 10", "`a`", "1"},
+					{">= 10", "`a`, `b`", "2"},
+					{"> 10", "!= `a`", "3"},
+					{"< 10", "!= `a`", "4"},
+					{"10", "`b`", "5"},
+				},
+			},
+			expected: "invalid decision table: rules[0] and rules[1] overlap; rules[1] and rules[2] overlap; rules[1] and rules[4] overlap",
+		},
+		{
+			name: "gap",
+			def: evaluator.DecisionTableDefinition{
+				HitPolicy: evaluator.HitPolicyFirst,
+				Inputs:    []string{"x", "y"},
+				Outputs:   []string{"out"},
+				Rules: [][]string{
+					{"< 10", "-", "1"},
+					{"> 10", "`a`", "2"},
+				},
+			},
+			expected: "invalid decision table: no rule matches x = 10, y = \"a\"; no rule matches x = 10, y = other string; no rule matches x = 11, y = other string",
+		},
+		{
+			name: "unknown hit policy",
+			def: evaluator.DecisionTableDefinition{
+				HitPolicy: "any",
+				Outputs:   []string{"out"},
+			},
+			expected: "unknown hit policy `any`",
+		},
+		{
+			name: "wrong number of cells",
+			def: evaluator.DecisionTableDefinition{
+				Inputs:  []string{"x"},
+				Outputs: []string{"out"},
+				Rules:   [][]string{{"1"}},
+			},
+			expected: "rules[0] has 1 cells, but expected 2 cells",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := evaluator.NewDecisionTable(c.def)
+			require.EqualError(t, err, c.expected)
+		})
+	}
+
+	def := cases[1].def
+	def.AllowGaps = true
+	table, err := evaluator.NewDecisionTable(def)
+	require.NoError(t, err)
+	actual, err := table.Evaluate(evaluator.Variables{"x": 10, "y": "a"})
+	require.NoError(t, err)
+	require.Empty(t, actual)
+
+	def = cases[0].def
+	def.HitPolicy = evaluator.HitPolicyUnique
+	def.Rules = def.Rules[:1]
+	def.Rules = append(def.Rules, []string{"x > 5", "-", "2"}, []string{"-", "-", "3"})
+	_, err = evaluator.NewDecisionTable(def)
+	var tableErr *evaluator.DecisionTableError
+	require.True(t, errors.As(err, &tableErr))
+	require.Equal(t, []string{"rules[0] and rules[2] overlap"}, tableErr.Issues)
+}
+
+func TestDecisionTableWithOptions(t *testing.T) {
+	s := evaluator.BuiltinFuncSet()
+	s.Deny("upper")
+	cases := []struct {
+		name     string
+		rule     []string
+		expected string
+	}{
+		{
+			name:     "input",
+			rule:     []string{"upper(`us`)", "1"},
+			expected: "rules[0] inputs[0] `upper(`us`)` upper() func is not allowed",
+		},
+		{
+			name:     "comparison",
+			rule:     []string{">= upper(`us`)", "1"},
+			expected: "rules[0] inputs[0] `>= upper(`us`)` upper() func is not allowed",
+		},
+		{
+			name:     "output",
+			rule:     []string{"-", "upper(region)"},
+			expected: "rules[0] outputs[0] upper() func is not allowed",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := evaluator.NewDecisionTable(evaluator.DecisionTableDefinition{
+				Inputs:  []string{"region"},
+				Outputs: []string{"score"},
+				Rules:   [][]string{c.rule},
+			}, evaluator.WithFuncSet(s))
+			var notAllowed *evaluator.FunctionNotAllowedError
+			require.True(t, errors.As(err, &notAllowed))
+			require.EqualError(t, err, c.expected)
+		})
+	}
+
+	table, err := evaluator.NewDecisionTable(evaluator.DecisionTableDefinition{
+		Inputs:  []string{"cpu_usage"},
+		Outputs: []string{"severity"},
+		Rules:   [][]string{{">= 90", "`critical`"}, {"< 90", "`ok`"}},
+	}, evaluator.WithCostBudget(100))
+	require.NoError(t, err, "the cells wrapped by the budget are validated")
+	actual, err := table.Evaluate(evaluator.Variables{"cpu_usage": 95})
+	require.NoError(t, err)
+	require.Equal(t, []evaluator.DecisionResult{{Rule: 0, Outputs: map[string]interface{}{"severity": "critical"}}}, actual)
+
+	_, err = evaluator.NewDecisionTable(evaluator.DecisionTableDefinition{
+		Inputs:  []string{"upper(region)"},
+		Outputs: []string{"score"},
+		Rules:   [][]string{{"-", "1"}},
+	}, evaluator.WithFuncSet(s))
+	require.EqualError(t, err, "inputs[0] upper() func is not allowed")
+}
+
+func TestLoadDecisionTable(t *testing.T) {
+	table, err := evaluator.LoadDecisionTable(strings.NewReader(`{
+		"hit_policy": "first",
+		"allow_gaps": true,
+		"inputs": ["rate(used, total)"],
+		"outputs": ["level"],
+		"rules": [[">= 0.9", "3"], [">= 0.5", "2"]]
+	}`))
+	require.NoError(t, err)
+	actual, err := table.Evaluate(evaluator.Variables{"used": 6, "total": 10})
+	require.NoError(t, err)
+	require.Equal(t, []evaluator.DecisionResult{{Rule: 1, Outputs: map[string]interface{}{"level": 2.0}}}, actual)
+}
@@ -0,0 +1,183 @@
+package evaluator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Environment is a set of the derived variables, which are named expressions referring to the variables and the other derived variables.
+// For example, `error_rate` defined as `rate(errors, requests)` can be referred by the other expressions like `error_rate > 0.1`.
+type Environment struct {
+	derived map[string]*derivedVariable
+	opts    []Option
+}
+
+type derivedVariable struct {
+	evaluator    Evaluator
+	dependencies []string
+}
+
+// CycleError is an error that occurs when the derived variables depend on each other.
+type CycleError struct {
+	// Path is the names of the cycle, the first and the last are the same.
+	Path []string
+}
+
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("cyclic dependency %s", strings.Join(e.Path, " -> "))
+}
+
+// NewEnvironment creates an empty Environment, Define parses the expressions with the options of New.
+func NewEnvironment(opts ...Option) *Environment {
+	return &Environment{
+		derived: make(map[string]*derivedVariable),
+		opts:    opts,
+	}
+}
+
+// Define parses the expression, and registers it as the derived variable of the name.
+// The derived variable can be redefined, and it is an error if the definition makes a cycle.
+func (env *Environment) Define(name string, expr string) error {
+	e, err := New(expr, env.opts...)
+	if err != nil {
+		return fmt.Errorf("Define(`%s`) %w", name, err)
+	}
+	return env.DefineEvaluator(name, e)
+}
+
+// DefineEvaluator registers the evaluator as the derived variable of the name.
+func (env *Environment) DefineEvaluator(name string, e Evaluator) error {
+	v := &derivedVariable{
+		evaluator:    e,
+		dependencies: ReferencedVariables(e),
+	}
+	if path := env.findPath(v.dependencies, name, []string{name}, make(map[string]bool)); path != nil {
+		return fmt.Errorf("Define(`%s`) %w", name, &CycleError{Path: path})
+	}
+	env.derived[name] = v
+	return nil
+}
+
+// findPath returns the path from the names to the target through the dependencies of the derived variables.
+func (env *Environment) findPath(names []string, target string, path []string, visited map[string]bool) []string {
+	for _, name := range names {
+		if name == target {
+			return append(path, name)
+		}
+		v, ok := env.derived[name]
+		if !ok || visited[name] {
+			continue
+		}
+		visited[name] = true
+		if found := env.findPath(v.dependencies, target, append(path[:len(path):len(path)], name), visited); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// Names returns the sorted names of the derived variables.
+func (env *Environment) Names() []string {
+	names := make([]string, 0, len(env.derived))
+	for name := range env.derived {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Dependencies returns the sorted names of the variables referenced by the derived variable, directly or indirectly.
+// The names of the derived variables are included.
+func (env *Environment) Dependencies(name string) []string {
+	seen := make(map[string]bool)
+	var visit func(string)
+	visit = func(name string) {
+		v, ok := env.derived[name]
+		if !ok {
+			return
+		}
+		for _, dep := range v.dependencies {
+			if !seen[dep] {
+				seen[dep] = true
+				visit(dep)
+			}
+		}
+	}
+	visit(name)
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Strict sets the expressions of the derived variables to run in strict mode.
+func (env *Environment) Strict(v bool) {
+	for _, derived := range env.derived {
+		derived.evaluator.Strict(v)
+	}
+}
+
+// Scope returns the variables in which the derived variables are resolved lazily when they are referred.
+// The value of each derived variable is evaluated at most once for the returned Variables.
+// The given variables take precedence over the derived variables of the same names.
+func (env *Environment) Scope(vars Variables) Variables {
+	scope := make(Variables, len(vars)+len(env.derived))
+	for name := range env.derived {
+		scope[name] = env.lazyValue(name, scope)
+	}
+	for k, v := range vars {
+		scope[k] = v
+	}
+	return scope
+}
+
+// Eval evaluates the expression with the derived variables.
+func (env *Environment) Eval(e Evaluator, vars Variables) (interface{}, error) {
+	return e.Eval(env.Scope(vars))
+}
+
+func (env *Environment) lazyValue(name string, scope Variables) *lazyValue {
+	return &lazyValue{
+		name:      name,
+		evaluator: env.derived[name].evaluator,
+		scope:     scope,
+	}
+}
+
+// lazyValue is the value of a derived variable in Variables, which is evaluated when it is referred first.
+type lazyValue struct {
+	name      string
+	evaluator Evaluator
+	scope     Variables
+
+	once  sync.Once
+	value interface{}
+	err   error
+}
+
+// resolve evaluates the derived variable at the first reference.
+// If the referrer has the cost meter, the evaluation is charged to it instead of the budget of the derived variable.
+func (v *lazyValue) resolve(m *costMeter) (interface{}, error) {
+	v.once.Do(func() {
+		e, scope := v.evaluator, v.scope
+		if m != nil {
+			if b, ok := e.(*budgetEvaluator); ok {
+				e = b.x
+			}
+			scope = childScope(v.scope, 1)
+			scope[budgetVariableName] = m
+			v.err = m.charge(staticCost(e))
+		}
+		if v.err == nil {
+			v.value, v.err = e.Eval(scope)
+		}
+		if v.err != nil {
+			v.err = fmt.Errorf("Eval(`%s`) %w", v.name, v.err)
+		}
+	})
+	return v.value, v.err
+}
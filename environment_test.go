@@ -0,0 +1,90 @@
+package evaluator_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mashiike/evaluator"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvironment(t *testing.T) {
+	env := evaluator.NewEnvironment()
+	require.NoError(t, env.Define("error_rate", "rate(errors, requests)"))
+	require.NoError(t, env.Define("availability", "1 - error_rate"))
+	require.NoError(t, env.Define("slo_violated", "availability < slo"))
+	require.Equal(t, []string{"availability", "error_rate", "slo_violated"}, env.Names())
+	require.Equal(t, []string{"availability", "error_rate", "errors", "requests", "slo"}, env.Dependencies("slo_violated"))
+
+	cases := []struct {
+		expr     string
+		vars     evaluator.Variables
+		expected interface{}
+	}{
+		{
+			expr:     "error_rate > 0.1",
+			vars:     evaluator.Variables{"errors": 20, "requests": 100},
+			expected: true,
+		},
+		{
+			expr:     "slo_violated",
+			vars:     evaluator.Variables{"errors": 1, "requests": 100, "slo": 0.999},
+			expected: true,
+		},
+		{
+			expr:     "if(slo_violated, availability, 1)",
+			vars:     evaluator.Variables{"errors": 1, "requests": 1000, "slo": 0.99},
+			expected: 1.0,
+		},
+		{
+			expr:     "availability",
+			vars:     evaluator.Variables{"errors": 1, "requests": 100, "error_rate": 0.5},
+			expected: 0.5,
+		},
+		{
+			expr:     "any(rates, r -> r > error_rate)",
+			vars:     evaluator.Variables{"errors": 1, "requests": 100, "rates": []interface{}{0.001, 0.02}},
+			expected: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.expr, func(t *testing.T) {
+			e, err := evaluator.New(c.expr)
+			require.NoError(t, err)
+			actual, err := env.Eval(e, c.vars)
+			require.NoError(t, err)
+			require.EqualValues(t, c.expected, actual)
+		})
+	}
+
+	e, err := evaluator.New("availability > 0.9")
+	require.NoError(t, err)
+	_, err = env.Eval(e, evaluator.Variables{"errors": 1, "requests": 0})
+	require.EqualError(t, err, "Eval(`availability > 0.9`) Eval(`availability`) Eval(`1 - error_rate`) v1[1]::float64 and v2[<nil>]::<nil> can not `-` comparatable")
+
+	env.Strict(true)
+	e.Strict(true)
+	_, err = env.Eval(e, evaluator.Variables{"errors": 1})
+	require.True(t, evaluator.IsVariableNotFound(err))
+}
+
+func TestEnvironmentCycle(t *testing.T) {
+	env := evaluator.NewEnvironment()
+	require.NoError(t, env.Define("a", "b + 1"))
+	require.NoError(t, env.Define("b", "c * 2"))
+	err := env.Define("c", "a - 1")
+	require.EqualError(t, err, "Define(`c`) cyclic dependency c -> a -> b -> c")
+	var cycleErr *evaluator.CycleError
+	require.True(t, errors.As(err, &cycleErr))
+	require.Equal(t, []string{"c", "a", "b", "c"}, cycleErr.Path)
+
+	require.EqualError(t, env.Define("d", "d + 1"), "Define(`d`) cyclic dependency d -> d")
+	require.NoError(t, env.Define("c", "x - 1"))
+	require.EqualError(t, env.Define("b", "a"), "Define(`b`) cyclic dependency b -> a -> b")
+
+	e, err := evaluator.New("a")
+	require.NoError(t, err)
+	actual, err := env.Eval(e, evaluator.Variables{"x": 3})
+	require.NoError(t, err)
+	require.EqualValues(t, 5, actual)
+}
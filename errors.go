@@ -7,29 +7,55 @@ import (
 
 //reserved error
 var (
-	ErrDivideByZero     = errors.New("divide by 0")
-	ErrVariableNotFound = errors.New("variable not found")
+	ErrDivideByZero           = errors.New("divide by 0")
+	ErrVariableNotFound       = errors.New("variable not found")
+	ErrStateNotGiven          = errors.New("state not given, use StatefulEvaluator")
+	ErrNoSQLEquivalent        = errors.New("has no SQL equivalent")
+	ErrNoQueryEquivalent      = errors.New("has no query equivalent")
+	ErrNoGoEquivalent         = errors.New("has no Go equivalent")
+	ErrNoJavaScriptEquivalent = errors.New("has no JavaScript equivalent")
+	ErrBudgetExceeded         = errors.New("cost budget exceeded")
 )
 
 //NumOfArgumentsMismatchError is an error that occurs when the number of arguments of the called function is different.
 type NumOfArgumentsMismatchError struct {
 	FunctionName string
 	Expected     int
-	Given        int
+	// ExpectedMax is the maximum number of the arguments, -1 for the variadic functions.
+	// It is zero or Expected if the function takes the fixed number of the arguments.
+	ExpectedMax int
+	Given       int
 }
 
 func (e *NumOfArgumentsMismatchError) Error() string {
-	return fmt.Sprintf("%s() func is expected %d arg, but given %d args", e.FunctionName, e.Expected, e.Given)
+	switch {
+	case e.ExpectedMax == 0 || e.ExpectedMax == e.Expected:
+		return fmt.Sprintf("%s() func is expected %d arg, but given %d args", e.FunctionName, e.Expected, e.Given)
+	case e.ExpectedMax < 0:
+		return fmt.Sprintf("%s() func is expected %d or more args, but given %d args", e.FunctionName, e.Expected, e.Given)
+	default:
+		return fmt.Sprintf("%s() func is expected %d to %d args, but given %d args", e.FunctionName, e.Expected, e.ExpectedMax, e.Given)
+	}
 }
 
-func newNumOfArgumentsMismatchError(functionName string, expected, given int) *NumOfArgumentsMismatchError {
+func newNumOfArgumentsMismatchError(functionName string, expected, expectedMax, given int) *NumOfArgumentsMismatchError {
 	return &NumOfArgumentsMismatchError{
 		FunctionName: functionName,
 		Expected:     expected,
+		ExpectedMax:  expectedMax,
 		Given:        given,
 	}
 }
 
+//FunctionNotAllowedError is an error that occurs when the called function is not in the FuncSet given by WithFuncSet.
+type FunctionNotAllowedError struct {
+	FunctionName string
+}
+
+func (e *FunctionNotAllowedError) Error() string {
+	return fmt.Sprintf("%s() func is not allowed", e.FunctionName)
+}
+
 //IsDivideByZero check error DivideByZero
 func IsDivideByZero(err error) bool {
 	return equalError(err, ErrDivideByZero)
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"go/ast"
 	"go/parser"
+	"go/scanner"
 	"go/token"
 	"strconv"
 	"strings"
@@ -35,34 +36,141 @@ type Comparator interface {
 	fmt.Stringer
 }
 
+// DetailedComparator is a Comparator which also reports the leaf conditions which decided the result.
+// The Comparators of this package implement it, CompareDetailed accepts any Comparator.
+type DetailedComparator interface {
+	Comparator
+
+	// CompareDetailed performs an comparison, and reports the leaf conditions which decided the result.
+	//  For `&&`, the conditions evaluated false are reported if the result is false.
+	//  For `||`, the conditions evaluated true are reported if the result is true.
+	//  Otherwise all conditions are reported.
+	CompareDetailed(Variables) (bool, []Condition, error)
+}
+
 // Variables are a group of variables given to the evaluator
 type Variables map[string]interface{}
 
-// New parses the expression to create an evaluator
-func New(expr string) (Evaluator, error) {
+// parentVariableName is the reserved name of the parent scope in Variables, it can not be referenced by the expressions.
+const parentVariableName = "\x00parent"
+
+// childScope creates the Variables layered over vars without copying them, the names not in the child are looked up in vars.
+func childScope(vars Variables, size int) Variables {
+	scope := make(Variables, size+1)
+	scope[parentVariableName] = vars
+	return scope
+}
+
+// Lookup returns the value of the name in the Variables, or in the parent scopes if it is not found.
+// The evaluators give the child scopes layered over the Variables to the sub-expressions,
+// so the Comparators and the Evaluators implemented outside of the package should resolve the names by Lookup.
+func (vars Variables) Lookup(name string) (interface{}, bool) {
+	for vars != nil {
+		if v, ok := vars[name]; ok {
+			return v, true
+		}
+		vars, _ = vars[parentVariableName].(Variables)
+	}
+	return nil, false
+}
+
+// New parses the expression to create an evaluator, the options limit the resources used by the expression.
+func New(expr string, opts ...Option) (e Evaluator, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e, err = nil, fmt.Errorf("New(`%s`) %w", expr, newPanicError(r))
+		}
+	}()
+	cfg := &config{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.maxLength > 0 && len(expr) > cfg.maxLength {
+		return nil, &LimitExceededError{Limit: "length", Max: cfg.maxLength, Actual: len(expr)}
+	}
 	expr = prepare(expr)
 	astExpr, err := parser.ParseExpr(expr)
 	if err != nil {
 		return nil, err
 	}
-	return parseExpr(expr, astExpr)
+	e, err = parseExpr(cfg, expr, astExpr)
+	if err != nil || *cfg == (config{}) {
+		return e, err
+	}
+	return cfg.apply(e)
 }
 
 func prepare(expr string) string {
-	//replace if( => __if(
-	return strings.ReplaceAll(expr, "if(", "__if(")
+	//replace x -> body => __lambda(x, body)
+	for {
+		rewritten, ok := rewriteLambda(expr, scanTokens(expr))
+		if !ok {
+			break
+		}
+		expr = rewritten
+	}
+	//replace if( => __if(, map( => __map(
+	var builder strings.Builder
+	tokens := scanTokens(expr)
+	last := 0
+	for i, tok := range tokens {
+		name, ok := keywordFuncNames[tok.tok]
+		if !ok || i+1 >= len(tokens) || tokens[i+1].tok != token.LPAREN {
+			continue
+		}
+		builder.WriteString(expr[last:tok.offset])
+		builder.WriteString(name)
+		last = tok.offset + len(tok.tok.String())
+	}
+	builder.WriteString(expr[last:])
+	return builder.String()
+}
+
+// keywordFuncNames are function names that conflict with the Go keywords.
+var keywordFuncNames = map[token.Token]string{
+	token.IF:  "__if",
+	token.MAP: "__map",
+}
+
+type scannedToken struct {
+	offset int
+	tok    token.Token
+	lit    string
+}
+
+func scanTokens(expr string) []scannedToken {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(expr))
+	var s scanner.Scanner
+	s.Init(file, []byte(expr), nil, 0)
+	tokens := make([]scannedToken, 0)
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok == token.SEMICOLON && lit == "\n" {
+			continue
+		}
+		tokens = append(tokens, scannedToken{
+			offset: file.Offset(pos),
+			tok:    tok,
+			lit:    lit,
+		})
+	}
+	return tokens
 }
 
-func parseExpr(str string, expr ast.Expr) (Evaluator, error) {
+func parseExpr(cfg *config, str string, expr ast.Expr) (Evaluator, error) {
 	switch expr := expr.(type) {
 	case *ast.Ident:
-		return parseIdent(str, expr)
+		return parseIdent(cfg, str, expr)
 	case *ast.BinaryExpr:
-		return parseBinaryExpr(str, expr)
+		return parseBinaryExpr(cfg, str, expr)
 	case *ast.BasicLit:
-		return parseBasicLit(str, expr)
+		return parseBasicLit(cfg, str, expr)
 	case *ast.ParenExpr:
-		x, err := parseExpr(str, expr.X)
+		x, err := parseExpr(cfg, str, expr.X)
 		if err != nil {
 			return nil, err
 		}
@@ -70,15 +178,17 @@ func parseExpr(str string, expr ast.Expr) (Evaluator, error) {
 			x: x,
 		}, nil
 	case *ast.CallExpr:
-		return parseCallExpr(str, expr)
+		return parseCallExpr(cfg, str, expr)
 	case *ast.UnaryExpr:
-		return parseUnaryExpr(str, expr)
+		return parseUnaryExpr(cfg, str, expr)
+	case *ast.SelectorExpr:
+		return parseSelectorExpr(cfg, str, expr)
 	default:
 		return nil, fmt.Errorf("can not parse `%s` ast type `%T` not implemented", str, expr)
 	}
 }
 
-func parseIdent(str string, expr *ast.Ident) (Evaluator, error) {
+func parseIdent(cfg *config, str string, expr *ast.Ident) (Evaluator, error) {
 	if expr.Name == "nil" {
 		return nilEvaluator{}, nil
 	}
@@ -114,7 +224,10 @@ func newLockupVariableEvaluator(name string) *lockupVariableEvaluator {
 }
 
 func (e *lockupVariableEvaluator) Eval(vars Variables) (interface{}, error) {
-	if v, ok := vars[string(e.name)]; ok {
+	if v, ok := vars.Lookup(string(e.name)); ok {
+		if lazy, ok := v.(*lazyValue); ok {
+			return lazy.resolve(meterOf(vars))
+		}
 		return v, nil
 	}
 	if e.strict {
@@ -143,14 +256,14 @@ func extractStrPos(str string, pos, end token.Pos) string {
 	return str[pos-1 : end-1]
 }
 
-func parseBinaryExpr(str string, expr *ast.BinaryExpr) (Evaluator, error) {
+func parseBinaryExpr(cfg *config, str string, expr *ast.BinaryExpr) (Evaluator, error) {
 	xStr := getSubExpr(str, expr.X)
-	xEvaluator, err := parseExpr(str, expr.X)
+	xEvaluator, err := parseExpr(cfg, str, expr.X)
 	if err != nil {
 		return nil, fmt.Errorf("parse BinaryExpr.X `%s` %w", xStr, err)
 	}
 	yStr := getSubExpr(str, expr.Y)
-	yEvaluator, err := parseExpr(str, expr.Y)
+	yEvaluator, err := parseExpr(cfg, str, expr.Y)
 	if err != nil {
 		return nil, fmt.Errorf("parse BinaryExpr.Y `%s` %w", yStr, err)
 	}
@@ -197,7 +310,7 @@ func parseBinaryExpr(str string, expr *ast.BinaryExpr) (Evaluator, error) {
 				if ok {
 					return &realNumericLiteralEvaluator{
 						value: value,
-						str:   fmt.Sprintf("%f", value),
+						str:   strconv.FormatFloat(value, 'g', -1, 64),
 					}, nil
 				}
 			}
@@ -257,7 +370,7 @@ func (e *comparativeEvaluator) String() string {
 	return fmt.Sprintf("%s %s %s", e.x, e.op, e.y)
 }
 
-func parseBasicLit(str string, expr *ast.BasicLit) (Evaluator, error) {
+func parseBasicLit(cfg *config, str string, expr *ast.BasicLit) (Evaluator, error) {
 
 	switch expr.Kind {
 	case token.INT, token.FLOAT:
@@ -266,10 +379,13 @@ func parseBasicLit(str string, expr *ast.BasicLit) (Evaluator, error) {
 			return nil, err
 		}
 		return newRealNumericLiteralEvaluator(v, strings.TrimSpace(getSubExpr(str, expr))), nil
-	case token.STRING:
-		return newStringLiteralEvaluator(strings.Trim(expr.Value, "`\"")), nil
-	case token.CHAR:
-		return newStringLiteralEvaluator(strings.Trim(expr.Value, "'")), nil
+	case token.STRING, token.CHAR:
+		// double-quoted and raw strings are unquoted as Go string literals, a single-quoted rune literal becomes a string of the rune.
+		v, err := strconv.Unquote(expr.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid literal %s: %w", expr.Value, err)
+		}
+		return newStringLiteralEvaluator(v), nil
 	default:
 		return nil, fmt.Errorf("unknown literal `%s`", expr.Kind)
 	}
@@ -322,7 +438,10 @@ func (e *stringLiteralEvaluator) AsComparator() (Comparator, bool) {
 }
 
 func (e *stringLiteralEvaluator) String() string {
-	return e.str
+	if strings.ContainsRune(e.str, '\\') && strconv.CanBackquote(e.str) {
+		return "`" + e.str + "`"
+	}
+	return strconv.Quote(e.str)
 }
 
 type logicalEvaluator struct {
@@ -366,7 +485,7 @@ func (e *logicalEvaluator) AsComparator() (Comparator, bool) {
 }
 
 func (e *logicalEvaluator) String() string {
-	return fmt.Sprintf("(%s) %s (%s)", e.x, e.op, e.y)
+	return fmt.Sprintf("%s %s %s", parenthesize(e.x), e.op, parenthesize(e.y))
 }
 
 type computableEvaluator struct {
@@ -385,10 +504,21 @@ func (e *computableEvaluator) Eval(vars Variables) (interface{}, error) {
 	if err != nil {
 		return nil, fmt.Errorf("Eval(`%s`) %w", e, err)
 	}
+	m := meterOf(vars)
+	if m != nil {
+		if err := m.charge(valueCost(v1) + valueCost(v2)); err != nil {
+			return nil, fmt.Errorf("Eval(`%s`) %w", e, err)
+		}
+	}
 	ret, err := e.f(v1, v2)
 	if err != nil {
 		return nil, fmt.Errorf("Eval(`%s`) %w", e, err)
 	}
+	if m != nil {
+		if err := m.charge(valueCost(ret)); err != nil {
+			return nil, fmt.Errorf("Eval(`%s`) %w", e, err)
+		}
+	}
 	return ret, nil
 }
 
@@ -428,16 +558,7 @@ func (e *parenEvaluator) String() string {
 	return fmt.Sprintf("(%s)", e.x)
 }
 
-func parseCallExpr(str string, expr *ast.CallExpr) (Evaluator, error) {
-	argEvaluators := make([]Evaluator, 0, len(expr.Args))
-	for i, arg := range expr.Args {
-		argStr := getSubExpr(str, arg)
-		argEvaluator, err := parseExpr(str, arg)
-		if err != nil {
-			return nil, fmt.Errorf("parse CallExpr.Args[%d] `%s` %w", i, argStr, err)
-		}
-		argEvaluators = append(argEvaluators, argEvaluator)
-	}
+func parseCallExpr(cfg *config, str string, expr *ast.CallExpr) (Evaluator, error) {
 	var funcName string
 	funStr := getSubExpr(str, expr.Fun)
 	switch fun := expr.Fun.(type) {
@@ -446,14 +567,58 @@ func parseCallExpr(str string, expr *ast.CallExpr) (Evaluator, error) {
 	default:
 		return nil, fmt.Errorf("parse CallExpr.Fun `%s` unexpected type %T", funStr, fun)
 	}
-	f, err := getCallFunc(funcName, argEvaluators)
+	if funcName == lambdaFuncName {
+		return nil, errors.New("lambda can only be used as an argument of higher-order functions")
+	}
+	custom, err := cfg.checkFunc(funcName)
 	if err != nil {
 		return nil, err
 	}
+	builtin, isBuiltin := calledBuiltinFunc(funcName)
+	if isBuiltin && builtin.higherOrder != nil {
+		return parseHigherOrderCallExpr(cfg, str, builtin, expr)
+	}
+	argEvaluators := make([]Evaluator, 0, len(expr.Args))
+	for i, arg := range expr.Args {
+		argStr := getSubExpr(str, arg)
+		argEvaluator, err := parseExpr(cfg, str, arg)
+		if err != nil {
+			return nil, fmt.Errorf("parse CallExpr.Args[%d] `%s` %w", i, argStr, err)
+		}
+		argEvaluators = append(argEvaluators, argEvaluator)
+	}
+	if custom != nil {
+		if custom.numOfArgs >= 0 && len(argEvaluators) != custom.numOfArgs {
+			return nil, newNumOfArgumentsMismatchError(funcName, custom.numOfArgs, custom.numOfArgs, len(argEvaluators))
+		}
+		return &callEvaluator{
+			args:     argEvaluators,
+			f:        callFunc(custom.f),
+			funcName: funcName,
+		}, nil
+	}
+	if !isBuiltin {
+		return nil, fmt.Errorf("%s() func is not found", funcName)
+	}
+	if err := builtin.checkNumOfArgs(len(argEvaluators)); err != nil {
+		return nil, err
+	}
+	if builtin.stateful != nil {
+		return &statefulCallEvaluator{
+			args:     argEvaluators,
+			f:        builtin.stateful,
+			funcName: builtin.name,
+		}, nil
+	}
+	f := builtin.call
+	if cfg.noCoercion && builtin.exactCall != nil {
+		f = builtin.exactCall
+	}
 	return &callEvaluator{
 		args:     argEvaluators,
 		f:        f,
-		funcName: funcName,
+		funcName: builtin.name,
+		size:     builtin.resultSize,
 	}, nil
 }
 
@@ -461,6 +626,8 @@ type callEvaluator struct {
 	args     []Evaluator
 	f        callFunc
 	funcName string
+	// size estimates the size of the result, see builtinFunc.resultSize.
+	size func(args ...interface{}) int
 }
 
 func (e *callEvaluator) Eval(vars Variables) (interface{}, error) {
@@ -472,7 +639,24 @@ func (e *callEvaluator) Eval(vars Variables) (interface{}, error) {
 		}
 		args = append(args, arg)
 	}
-	return e.f(args...)
+	m := meterOf(vars)
+	if m != nil {
+		cost := callCost(e.funcName, args)
+		if e.size != nil {
+			cost += e.size(args...)
+		}
+		if err := m.charge(cost); err != nil {
+			return nil, fmt.Errorf("Eval(`%s`) %w", e, err)
+		}
+	}
+	ret, err := e.f(args...)
+	if err != nil || m == nil || e.size != nil {
+		return ret, err
+	}
+	if err := m.charge(valueCost(ret)); err != nil {
+		return nil, fmt.Errorf("Eval(`%s`) %w", e, err)
+	}
+	return ret, nil
 }
 
 func (e *callEvaluator) Strict(v bool) {
@@ -499,14 +683,20 @@ func (e *callEvaluator) String() string {
 	return builder.String()
 }
 
-func parseUnaryExpr(str string, expr *ast.UnaryExpr) (Evaluator, error) {
+func parseUnaryExpr(cfg *config, str string, expr *ast.UnaryExpr) (Evaluator, error) {
 	xStr := getSubExpr(str, expr.X)
-	xEvaluator, err := parseExpr(str, expr.X)
+	xEvaluator, err := parseExpr(cfg, str, expr.X)
 	if err != nil {
 		return nil, fmt.Errorf("parse BinaryExpr.X `%s` %w", xStr, err)
 	}
-	op := strings.TrimSpace(extractStrPos(str, expr.OpPos, expr.End()))
+	op := expr.Op.String()
+	if xLiteral, ok := xEvaluator.(*realNumericLiteralEvaluator); ok && expr.Op == token.SUB {
+		return newRealNumericLiteralEvaluator(-xLiteral.value, op+xLiteral.str), nil
+	}
 	if f, ok := getUnaryFunc(expr.Op); ok {
+		if expr.Op == token.NOT && cfg.noCoercion {
+			f = exactNotUnaryFunc
+		}
 		return &unaryEvaluator{
 			x:  xEvaluator,
 			f:  f,
@@ -535,7 +725,7 @@ func (e *unaryEvaluator) Eval(vars Variables) (interface{}, error) {
 }
 
 func (e *unaryEvaluator) Strict(v bool) {
-	e.x.Strict(true)
+	e.x.Strict(v)
 }
 
 func (e *unaryEvaluator) AsComparator() (Comparator, bool) {
@@ -543,5 +733,65 @@ func (e *unaryEvaluator) AsComparator() (Comparator, bool) {
 }
 
 func (e *unaryEvaluator) String() string {
-	return fmt.Sprintf("%s(%s)", e.op, e.x)
+	return e.op + parenthesize(e.x)
+}
+
+// parenthesize returns the string of the evaluator enclosed in parentheses unless it is atomic.
+func parenthesize(e Evaluator) string {
+	switch e.(type) {
+	case nilEvaluator, *lockupVariableEvaluator, *stringLiteralEvaluator, *parenEvaluator,
+		*callEvaluator, *statefulCallEvaluator, *higherOrderEvaluator, *selectorEvaluator, labelEvaluator:
+		return e.String()
+	case *realNumericLiteralEvaluator:
+		if !strings.HasPrefix(e.String(), "-") {
+			return e.String()
+		}
+	}
+	return fmt.Sprintf("(%s)", e)
+}
+
+func parseSelectorExpr(cfg *config, str string, expr *ast.SelectorExpr) (Evaluator, error) {
+	xStr := getSubExpr(str, expr.X)
+	xEvaluator, err := parseExpr(cfg, str, expr.X)
+	if err != nil {
+		return nil, fmt.Errorf("parse SelectorExpr.X `%s` %w", xStr, err)
+	}
+	return &selectorEvaluator{
+		x:    xEvaluator,
+		name: expr.Sel.Name,
+	}, nil
+}
+
+// selectorEvaluator refers to a field of map or struct value, like `x.name`
+type selectorEvaluator struct {
+	strict bool
+	x      Evaluator
+	name   string
+}
+
+func (e *selectorEvaluator) Eval(vars Variables) (interface{}, error) {
+	v, err := e.x.Eval(vars)
+	if err != nil {
+		return nil, fmt.Errorf("Eval(`%s`) %w", e, err)
+	}
+	if field, ok := lookupField(v, e.name); ok {
+		return field, nil
+	}
+	if e.strict {
+		return nil, fmt.Errorf("%s %w", e, ErrVariableNotFound)
+	}
+	return nil, nil
+}
+
+func (e *selectorEvaluator) Strict(v bool) {
+	e.strict = v
+	e.x.Strict(v)
+}
+
+func (e *selectorEvaluator) AsComparator() (Comparator, bool) {
+	return nil, false
+}
+
+func (e *selectorEvaluator) String() string {
+	return fmt.Sprintf("%s.%s", e.x, e.name)
 }
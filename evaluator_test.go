@@ -1,6 +1,9 @@
 package evaluator_test
 
 import (
+	"errors"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/mashiike/evaluator"
@@ -171,6 +174,330 @@ func TestEvaluatorSuccess(t *testing.T) {
 				3.14,
 			},
 		},
+		{
+			expr: "sum(var1) + count(var1)",
+			variables: []evaluator.Variables{
+				{"var1": []float64{1.0, 2.0, 3.0}},
+				{"var1": []interface{}{1, nil, 2.5}},
+			},
+			expected: []interface{}{
+				9.0,
+				5.5,
+			},
+		},
+		{
+			expr: "avg(var1, var2, var3)",
+			variables: []evaluator.Variables{
+				{"var1": 1, "var2": 2, "var3": 6},
+				{"var1": 1, "var3": 3},
+				{"var1": []int{1, 2}, "var2": 3, "var3": nil},
+				{},
+			},
+			expected: []interface{}{
+				3.0,
+				2.0,
+				2.0,
+				nil,
+			},
+		},
+		{
+			expr: "max(var1) - min(var1)",
+			variables: []evaluator.Variables{
+				{"var1": []float64{3.0, -1.0, 2.0}},
+			},
+			expected: []interface{}{
+				4.0,
+			},
+		},
+		{
+			expr: "stddev(var1)",
+			variables: []evaluator.Variables{
+				{"var1": []float64{2, 4, 4, 4, 5, 5, 7, 9}},
+			},
+			expected: []interface{}{
+				2.0,
+			},
+		},
+		{
+			expr: "median(var1)",
+			variables: []evaluator.Variables{
+				{"var1": []float64{5, 1, 3}},
+				{"var1": []float64{4, 1, 3, 2}},
+			},
+			expected: []interface{}{
+				3.0,
+				2.5,
+			},
+		},
+		{
+			expr: "percentile(var1, 90)",
+			variables: []evaluator.Variables{
+				{"var1": []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}},
+				{"var1": []float64{}},
+			},
+			expected: []interface{}{
+				10.0,
+				nil,
+			},
+		},
+		{
+			expr: "any(disks, d -> d.used / d.size > 0.9)",
+			variables: []evaluator.Variables{
+				{"disks": []map[string]interface{}{{"used": 10, "size": 100}, {"used": 95, "size": 100}}},
+				{"disks": []map[string]interface{}{{"used": 10, "size": 100}}},
+				{"disks": []interface{}{}},
+			},
+			expected: []interface{}{
+				true,
+				false,
+				false,
+			},
+		},
+		{
+			expr: "all(values, v -> v >= threshold) && none(values, v -> v > 100)",
+			variables: []evaluator.Variables{
+				{"values": []float64{1, 2, 3}, "threshold": 0},
+				{"values": []float64{-1, 2, 3}, "threshold": 0},
+				{"values": []float64{1, 200}, "threshold": 0},
+			},
+			expected: []interface{}{
+				true,
+				false,
+				false,
+			},
+		},
+		{
+			expr: "sum(map(filter(values, v -> v > 1), v -> v * 2))",
+			variables: []evaluator.Variables{
+				{"values": []float64{1, 2, 3}},
+			},
+			expected: []interface{}{
+				10.0,
+			},
+		},
+		{
+			expr: "sum(map(values, v -> v * 2)) + v + sum(map(values, x -> sum(map(values, v -> v * x))))",
+			variables: []evaluator.Variables{
+				{"values": []float64{1, 2}, "v": 10},
+			},
+			expected: []interface{}{
+				25.0,
+			},
+		},
+		{
+			expr: "count_if(values, v -> if(v > 1, 1, 0) > 0) + reduce(values, 0, (acc, v) -> acc + v)",
+			variables: []evaluator.Variables{
+				{"values": []float64{1, 2, 3}},
+			},
+			expected: []interface{}{
+				8.0,
+			},
+		},
+		{
+			expr: "any(groups, g -> all(g.values, v -> v > g.min)) || string_contains(`if(x -> y)`, `x -> y`)",
+			variables: []evaluator.Variables{
+				{"groups": []interface{}{
+					map[string]interface{}{"min": 5, "values": []float64{1, 6}},
+					map[string]interface{}{"min": 0, "values": []float64{1, 6}},
+				}},
+			},
+			expected: []interface{}{
+				true,
+			},
+		},
+		{
+			expr: "abs(var1) + round(var2) + floor(var2) + ceil(var2) + trunc(var1)",
+			variables: []evaluator.Variables{
+				{"var1": -1.5, "var2": 2.5},
+			},
+			expected: []interface{}{
+				1.5 + 3 + 2 + 3 - 1,
+			},
+		},
+		{
+			expr: "round_to(sqrt(var1), 2)",
+			variables: []evaluator.Variables{
+				{"var1": 2},
+				{"var1": nil},
+			},
+			expected: []interface{}{
+				1.41,
+				nil,
+			},
+		},
+		{
+			expr: "pow(2, var1) + log2(8) + log10(100) + log(exp(1))",
+			variables: []evaluator.Variables{
+				{"var1": 3},
+			},
+			expected: []interface{}{
+				8.0 + 3 + 2 + 1,
+			},
+		},
+		{
+			expr: "clamp(var1, 0, 10) * sign(var2)",
+			variables: []evaluator.Variables{
+				{"var1": 15, "var2": -3},
+				{"var1": -5, "var2": 3},
+				{"var1": 5, "var2": 3},
+			},
+			expected: []interface{}{
+				-10.0,
+				0.0,
+				5.0,
+			},
+		},
+		{
+			expr: "max(var1, var2, 3) - min(var1, var2)",
+			variables: []evaluator.Variables{
+				{"var1": 1, "var2": 2},
+				{"var1": 5},
+			},
+			expected: []interface{}{
+				2.0,
+				0.0,
+			},
+		},
+		{
+			expr: "is_nan(sqrt(var1)) || is_inf(log(var1))",
+			variables: []evaluator.Variables{
+				{"var1": -1},
+				{"var1": 0},
+				{"var1": 1},
+				{},
+			},
+			expected: []interface{}{
+				true,
+				true,
+				false,
+				false,
+			},
+		},
+		{
+			expr: "upper(var1) + `-` + lower(trim(var2))",
+			variables: []evaluator.Variables{
+				{"var1": "abc", "var2": "  DEF "},
+			},
+			expected: []interface{}{
+				"ABC-def",
+			},
+		},
+		{
+			expr: "has_prefix(var1, `ERROR`) && has_suffix(var1, `timeout`)",
+			variables: []evaluator.Variables{
+				{"var1": "ERROR: connection timeout"},
+				{"var1": "WARN: connection timeout"},
+			},
+			expected: []interface{}{
+				true,
+				false,
+			},
+		},
+		{
+			expr: "join(split(replace(var1, `-`, `_`), `,`), `|`)",
+			variables: []evaluator.Variables{
+				{"var1": "a-b,c,d"},
+				{"var1": nil},
+			},
+			expected: []interface{}{
+				"a_b|c|d",
+				nil,
+			},
+		},
+		{
+			expr: "substr(var1, 1, 3) + as_string(len(var1))",
+			variables: []evaluator.Variables{
+				{"var1": "hello"},
+				{"var1": "日本語です"},
+				{"var1": "a"},
+			},
+			expected: []interface{}{
+				"ell5",
+				"本語で5",
+				"1",
+			},
+		},
+		{
+			expr: "format(`%s=%.1f`, var1, var2)",
+			variables: []evaluator.Variables{
+				{"var1": "latency", "var2": 0.25},
+			},
+			expected: []interface{}{
+				"latency=0.2",
+			},
+		},
+		{
+			expr: "format(`%d items, %05.1f%%, %x, %*d, %v, %[1]b`, var1, var2, var3, 3, 7, var2)",
+			variables: []evaluator.Variables{
+				{"var1": 3, "var2": 2.5, "var3": 255},
+			},
+			expected: []interface{}{
+				"3 items, 002.5%, ff,   7, 2.5, 11",
+			},
+		},
+		{
+			expr: "coalesce(regexp_extract(var1, `status=(\\d+)`, 1), `none`) + regexp_replace(var1, `\\d`, `#`)",
+			variables: []evaluator.Variables{
+				{"var1": "status=500"},
+				{"var1": "ok"},
+			},
+			expected: []interface{}{
+				"500status=###",
+				"noneok",
+			},
+		}, {
+			expr: `var1 + "a\"b\n" + 'x' + '\''`,
+			variables: []evaluator.Variables{
+				{"var1": "\""},
+			},
+			expected: []interface{}{
+				"\"a\"b\nx'",
+			},
+		},
+		{
+			expr: "`raw\\n` == var1",
+			variables: []evaluator.Variables{
+				{"var1": `raw\n`},
+				{"var1": "raw\n"},
+			},
+			expected: []interface{}{
+				true,
+				false,
+			},
+		},
+		{
+			expr: "var1 == 65",
+			variables: []evaluator.Variables{
+				{"var1": 'A'},
+				{"var1": int32(66)},
+			},
+			expected: []interface{}{
+				true,
+				false,
+			},
+		},
+		{
+			expr: "equal_fold(var1, `STRASSE`) || normalize(var1) == normalize(var2)",
+			variables: []evaluator.Variables{
+				{"var1": "strasse", "var2": ""},
+				{"var1": "caf\u00e9", "var2": "cafe\u0301"},
+				{"var1": "cafe", "var2": "café"},
+			},
+			expected: []interface{}{
+				true,
+				true,
+				false,
+			},
+		},
+		{
+			expr: "upper(var1) + as_string(len(var1))",
+			variables: []evaluator.Variables{
+				{"var1": []rune("äbc")},
+			},
+			expected: []interface{}{
+				"ÄBC3",
+			},
+		},
 	}
 	for _, c := range cases {
 		t.Run(c.expr, func(t *testing.T) {
@@ -186,6 +513,26 @@ func TestEvaluatorSuccess(t *testing.T) {
 	}
 }
 
+func TestEvaluatorLambdaScope(t *testing.T) {
+	e, err := evaluator.New("reduce(values, v, (acc, v) -> acc + v) + v", evaluator.WithCostBudget(100))
+	require.NoError(t, err)
+	vars := evaluator.Variables{"values": []float64{1, 2}, "v": 10}
+	actual, err := e.Eval(vars)
+	require.NoError(t, err)
+	require.EqualValues(t, 23, actual)
+	require.Equal(t, evaluator.Variables{"values": []float64{1, 2}, "v": 10}, vars)
+
+	// threshold is looked up through the scopes of the lambda, the budget and the state
+	s, err := evaluator.NewStateful("count_if(values, v -> v > threshold) + coalesce(delta(threshold), 0)", evaluator.WithCostBudget(100))
+	require.NoError(t, err)
+	actual, err = s.Eval(evaluator.Variables{"values": []float64{1, 2, 3}, "threshold": 1})
+	require.NoError(t, err)
+	require.EqualValues(t, 2, actual)
+	actual, err = s.Eval(evaluator.Variables{"values": []float64{1, 2, 3}, "threshold": 2})
+	require.NoError(t, err)
+	require.EqualValues(t, 2, actual)
+}
+
 func TestEvaluatorVariableInvalid(t *testing.T) {
 
 	cases := []struct {
@@ -244,6 +591,88 @@ func TestEvaluatorVariableInvalid(t *testing.T) {
 				"Eval(`var1 / var2`) divide by 0",
 			},
 		},
+		{
+			expr: "sum(var1)",
+			variables: []evaluator.Variables{
+				{"var1": []interface{}{1, "hoge"}},
+			},
+			expected: []string{
+				"sum(v[hoge]::string) can not eval",
+			},
+		},
+		{
+			expr: "any(values, v -> v.name)",
+			variables: []evaluator.Variables{
+				{"values": []interface{}{map[string]interface{}{"name": "hoge"}}},
+				{"values": []interface{}{map[string]interface{}{}}},
+				{"values": "hoge"},
+			},
+			expected: []string{
+				"Eval(`any(values, v -> v.name)`) `v -> v.name` returns v[hoge]::string, is not bool",
+				"Eval(`any(values, v -> v.name)`) v.name variable not found",
+				"Eval(`any(values, v -> v.name)`) Args[0] v[hoge]::string is not list",
+			},
+		},
+		{
+			expr: "clamp(var1, var2, 0) + abs(var3)",
+			variables: []evaluator.Variables{
+				{"var1": 1, "var2": 10, "var3": 1},
+				{"var1": 1, "var2": 0, "var3": "hoge"},
+			},
+			expected: []string{
+				"Eval(`clamp(var1, var2, 0) + abs(var3)`) clamp(v1[1]::int,v2[10]::int,v3[0]::float64) lower bound is greater than upper bound",
+				"Eval(`clamp(var1, var2, 0) + abs(var3)`) abs(v[hoge]::string) can not eval",
+			},
+		},
+		{
+			expr: "substr(var1, sqrt(-1))",
+			variables: []evaluator.Variables{
+				{"var1": "abc"},
+			},
+			expected: []string{
+				"substr(v1[abc]::string,v2[NaN]::float64) can not eval",
+			},
+		},
+		{
+			expr: "substr(var1, 0, sqrt(-1))",
+			variables: []evaluator.Variables{
+				{"var1": "abc"},
+			},
+			expected: []string{
+				"substr(v1[abc]::string,v2[0]::float64,v3[NaN]::float64) can not eval",
+			},
+		},
+		{
+			expr: "regexp_extract(var1, `(b)`, sqrt(-1))",
+			variables: []evaluator.Variables{
+				{"var1": "abc"},
+			},
+			expected: []string{
+				"regexp_extract(v1[abc]::string,v2[(b)]::string,v3[NaN]::float64) can not eval",
+			},
+		},
+		{
+			expr: "regexp_extract(var1, `(b)`, var2)",
+			variables: []evaluator.Variables{
+				{"var1": "abc", "var2": 1e19},
+				{"var1": "abc", "var2": -1e19},
+			},
+			expected: []string{
+				"regexp_extract(v1[abc]::string,v2[(b)]::string) group 1e+19 is out of range",
+				"regexp_extract(v1[abc]::string,v2[(b)]::string) group -1e+19 is out of range",
+			},
+		},
+		{
+			expr: "format(`%d`, var1)",
+			variables: []evaluator.Variables{
+				{"var1": 1.5},
+				{"var1": 1e19},
+			},
+			expected: []string{
+				"format(v[%d]::string) %d is expected integer, but given v[1.5]::float64",
+				"format(v[%d]::string) %d is expected integer, but given v[1e+19]::float64",
+			},
+		},
 	}
 	for _, c := range cases {
 		t.Run(c.expr, func(t *testing.T) {
@@ -316,3 +745,119 @@ func TestEvaluatorAsComparator(t *testing.T) {
 		})
 	}
 }
+
+func TestFunctions(t *testing.T) {
+	signatures := evaluator.Functions()
+	require.Contains(t, signatures, "rate(number, number)")
+	require.True(t, sort.StringsAreSorted(signatures), "must sorted by the name")
+	for _, signature := range signatures {
+		name := signature[:strings.Index(signature, "(")]
+		t.Run(name, func(t *testing.T) {
+			_, err := evaluator.New(name + "()")
+			if err != nil {
+				var mismatch *evaluator.NumOfArgumentsMismatchError
+				require.True(t, errors.As(err, &mismatch), "must be a known function: %s", err)
+			}
+		})
+	}
+}
+
+func TestNumOfArgumentsMismatchError(t *testing.T) {
+	cases := []struct {
+		expr     string
+		expected evaluator.NumOfArgumentsMismatchError
+		message  string
+	}{
+		{
+			expr:     "abs(1, 2)",
+			expected: evaluator.NumOfArgumentsMismatchError{FunctionName: "abs", Expected: 1, ExpectedMax: 1, Given: 2},
+			message:  "abs() func is expected 1 arg, but given 2 args",
+		},
+		{
+			expr:     "regexp_extract(`abc`, `b`, 1, 2)",
+			expected: evaluator.NumOfArgumentsMismatchError{FunctionName: "regexp_extract", Expected: 2, ExpectedMax: 3, Given: 4},
+			message:  "regexp_extract() func is expected 2 to 3 args, but given 4 args",
+		},
+		{
+			expr:     "format()",
+			expected: evaluator.NumOfArgumentsMismatchError{FunctionName: "format", Expected: 1, ExpectedMax: -1, Given: 0},
+			message:  "format() func is expected 1 or more args, but given 0 args",
+		},
+		{
+			expr:     "any(values)",
+			expected: evaluator.NumOfArgumentsMismatchError{FunctionName: "any", Expected: 2, ExpectedMax: 2, Given: 1},
+			message:  "any() func is expected 2 arg, but given 1 args",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.expr, func(t *testing.T) {
+			_, err := evaluator.New(c.expr)
+			var mismatch *evaluator.NumOfArgumentsMismatchError
+			require.True(t, errors.As(err, &mismatch))
+			require.Equal(t, c.expected, *mismatch)
+			require.EqualError(t, err, c.message)
+		})
+	}
+
+	err := &evaluator.NumOfArgumentsMismatchError{FunctionName: "custom", Expected: 1, Given: 2}
+	require.EqualError(t, err, "custom() func is expected 1 arg, but given 2 args", "ExpectedMax is not required for the fixed number")
+}
+
+func TestEvaluatorWithoutCoercion(t *testing.T) {
+	cases := []struct {
+		expr     string
+		vars     evaluator.Variables
+		expected interface{}
+		err      string
+	}{
+		{
+			expr:     "!var1",
+			vars:     evaluator.Variables{"var1": false},
+			expected: true,
+		},
+		{
+			expr: "!var1",
+			vars: evaluator.Variables{"var1": "false"},
+			err:  "Eval(`!var1`) v[false]::string can not `!` operation",
+		},
+		{
+			expr: "if(var1, 1, 2)",
+			vars: evaluator.Variables{"var1": 0},
+			err:  "if(v1[0]::int,v2[1]::float64,v3[2]::float64) can not eval",
+		},
+		{
+			expr: "any(values, v -> !v)",
+			vars: evaluator.Variables{"values": []interface{}{true, 1}},
+			err:  "Eval(`any(values, v -> !v)`) Eval(`!v`) v[1]::int can not `!` operation",
+		},
+		{
+			expr: "join(values, `,`)",
+			vars: evaluator.Variables{"values": []interface{}{"a", 1}},
+			err:  "join(v1[[a 1]]::[]interface {},v2[,]::string) element v[1]::int can not convert to string",
+		},
+		{
+			expr:     "join(values, `,`) + as_string(var1)",
+			vars:     evaluator.Variables{"values": []interface{}{"a", nil, "b"}, "var1": 1},
+			expected: "a,b1",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.expr, func(t *testing.T) {
+			e, err := evaluator.New(c.expr, evaluator.WithoutCoercion())
+			require.NoError(t, err)
+			ret, err := e.Eval(c.vars)
+			if c.err != "" {
+				require.EqualError(t, err, c.err)
+				return
+			}
+			require.NoError(t, err)
+			require.EqualValues(t, c.expected, ret)
+
+			e, err = evaluator.New(c.expr)
+			require.NoError(t, err)
+			ret, err = e.Eval(c.vars)
+			require.NoError(t, err, "the coercion is applied by default")
+			require.EqualValues(t, c.expected, ret)
+		})
+	}
+}
@@ -3,6 +3,8 @@ package evaluator_test
 import (
 	"fmt"
 	"log"
+	"os"
+	"strings"
 
 	"github.com/mashiike/evaluator"
 )
@@ -36,3 +38,122 @@ func ExampleComparator_Compare() {
 	// Output:
 	// true
 }
+
+func ExampleExplain() {
+
+	e, err := evaluator.New("rate(var1, var2) <= 0.95")
+	if err != nil {
+		log.Fatal(err)
+	}
+	explanation := evaluator.Explain(e, evaluator.Variables{"var1": 97, "var2": 100})
+	fmt.Print(explanation)
+
+	// Output:
+	// rate(var1, var2)=0.97 <= 0.95 → false
+	//   rate(var1=97, var2=100) → 0.97
+	//     var1 → 97
+	//     var2 → 100
+}
+
+func ExampleCompareDetailed() {
+
+	e, err := evaluator.New("disk_free >= 10 && cpu_usage < 90")
+	if err != nil {
+		log.Fatal(err)
+	}
+	c, ok := e.AsComparator()
+	if !ok {
+		log.Fatal("not comparative expr")
+	}
+	ans, conditions, err := evaluator.CompareDetailed(c, evaluator.Variables{"disk_free": 5, "cpu_usage": 30})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(ans)
+	for _, condition := range conditions {
+		fmt.Printf("%s was violated: %s\n", condition.Expr, condition)
+	}
+
+	// Output:
+	// false
+	// disk_free >= 10 was violated: disk_free=5 >= 10
+}
+
+func ExampleEvalStream() {
+
+	e, err := evaluator.New("(var1 + 0.5) * var2")
+	if err != nil {
+		log.Fatal(err)
+	}
+	input := "name,var1,var2\nfoo,0.5,3\nbar,1.5,2\n"
+	err = evaluator.EvalStream(e, strings.NewReader(input), evaluator.StreamFormatCSV, os.Stdout)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Output:
+	// name,var1,var2,result
+	// foo,0.5,3,3
+	// bar,1.5,2,4
+}
+
+func ExampleRuleSet_Match() {
+
+	rs, err := evaluator.NewRuleSet([]evaluator.Rule{
+		{Name: "warning", Expr: "rate(used, total) > 0.8", Priority: 1, Enabled: true},
+		{Name: "critical", Expr: "rate(used, total) > 0.95", Priority: 10, Enabled: true},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	matched, err := rs.Match(evaluator.Variables{"used": 97, "total": 100}, evaluator.MatchFirst)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, rule := range matched {
+		fmt.Println(rule.Name)
+	}
+
+	// Output:
+	// critical
+}
+
+func ExampleEnvironment() {
+
+	env := evaluator.NewEnvironment()
+	if err := env.Define("error_rate", "rate(errors, requests)"); err != nil {
+		log.Fatal(err)
+	}
+	e, err := evaluator.New("error_rate > 0.1")
+	if err != nil {
+		log.Fatal(err)
+	}
+	ans, err := env.Eval(e, evaluator.Variables{"errors": 20, "requests": 100})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(ans)
+
+	// Output:
+	// true
+}
+
+func ExampleStatefulEvaluator() {
+
+	e, err := evaluator.NewStateful("increase(counter)")
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, counter := range []int{10, 15, 3} {
+		ans, err := e.Eval(evaluator.Variables{"counter": counter})
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(ans)
+	}
+
+	// Output:
+	// <nil>
+	// 5
+	// 3
+}
@@ -0,0 +1,193 @@
+package evaluator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Explanation is a trace of the evaluation, it is a tree corresponding to the nodes of the expression.
+type Explanation struct {
+	// Expr is String() of the node.
+	Expr string
+	// Value is the evaluated value of the node.
+	Value interface{}
+	// Type is the type name of Value.
+	Type string
+	// Err is the error of the evaluation of the node.
+	Err error
+	// Evaluated is false if the node was not evaluated, for example a previous sibling node failed.
+	Evaluated bool
+	// Children are the explanations of sub expressions. The literals are included, but the lambda bodies are not.
+	Children []*Explanation
+
+	node Evaluator
+}
+
+// Explain evaluates the expression by giving a set of variables, and returns the evaluation trace of every node.
+// The result of the expression is the Value and Err of the returned Explanation.
+func Explain(e Evaluator, vars Variables) *Explanation {
+	traced, explanation := trace(e)
+	if _, err := traced.Eval(vars); err != nil && !explanation.Evaluated {
+		// the limits failed before the evaluation of the expression, or the panic was recovered
+		explanation.Err = err
+	}
+	return explanation
+}
+
+// trace rebuilds the expression tree which records the evaluation into the explanations.
+// The evaluators which only guard the evaluation are kept in the tree, but they have no explanations.
+func trace(e Evaluator) (Evaluator, *Explanation) {
+	switch w := e.(type) {
+	case *budgetEvaluator:
+		traced, explanation := trace(w.x)
+		return &budgetEvaluator{x: traced, budget: w.budget, cost: w.cost}, explanation
+	case *recoverEvaluator:
+		traced, explanation := trace(w.x)
+		return &recoverEvaluator{x: traced}, explanation
+	}
+	explanation := &Explanation{
+		Expr: e.String(),
+		node: e,
+	}
+	if n, ok := e.(node); ok {
+		children := n.children()
+		traced := make([]Evaluator, 0, len(children))
+		for _, child := range children {
+			if _, ok := child.(*lambdaEvaluator); ok {
+				traced = append(traced, child)
+				continue
+			}
+			tracedChild, childExplanation := trace(child)
+			traced = append(traced, tracedChild)
+			explanation.Children = append(explanation.Children, childExplanation)
+		}
+		if len(children) > 0 {
+			e = n.withChildren(traced)
+		}
+	}
+	return &traceEvaluator{
+		Evaluator:   e,
+		explanation: explanation,
+	}, explanation
+}
+
+type traceEvaluator struct {
+	Evaluator
+	explanation *Explanation
+}
+
+func (e *traceEvaluator) Eval(vars Variables) (interface{}, error) {
+	v, err := e.Evaluator.Eval(vars)
+	e.explanation.Evaluated = true
+	e.explanation.Value = v
+	e.explanation.Type = fmt.Sprintf("%T", v)
+	e.explanation.Err = err
+	return v, err
+}
+
+// String renders the explanation as the indented annotated expressions, like `rate(var1, var2)=0.97 <= 0.95 → false`.
+// Each line shows a node whose sub expressions are annotated by their values, and the children of the node follow with the indent.
+func (x *Explanation) String() string {
+	var builder strings.Builder
+	x.render(&builder, 0)
+	return builder.String()
+}
+
+func (x *Explanation) render(builder *strings.Builder, depth int) {
+	builder.WriteString(strings.Repeat("  ", depth))
+	builder.WriteString(x.Annotated())
+	builder.WriteString(" → ")
+	builder.WriteString(x.result())
+	builder.WriteRune('\n')
+	for _, child := range x.Children {
+		if isLiteralEvaluator(child.node) {
+			continue
+		}
+		child.render(builder, depth+1)
+	}
+}
+
+// Annotated returns the expression whose direct sub expressions are annotated by the evaluated values.
+func (x *Explanation) Annotated() string {
+	n, ok := x.node.(node)
+	if !ok || len(x.Children) == 0 {
+		return x.Expr
+	}
+	children := n.children()
+	labels := make([]Evaluator, 0, len(children))
+	i := 0
+	for _, child := range children {
+		if _, ok := child.(*lambdaEvaluator); ok {
+			labels = append(labels, child)
+			continue
+		}
+		labels = append(labels, x.Children[i].label())
+		i++
+	}
+	return n.withChildren(labels).String()
+}
+
+func (x *Explanation) label() Evaluator {
+	expr := x.Expr
+	switch x.node.(type) {
+	case *comparativeEvaluator, *logicalEvaluator, *computableEvaluator:
+		expr = "(" + expr + ")"
+	}
+	if isLiteralEvaluator(x.node) || !x.Evaluated || x.Err != nil {
+		return labelEvaluator(expr)
+	}
+	return labelEvaluator(fmt.Sprintf("%s=%s", expr, formatValue(x.Value)))
+}
+
+func (x *Explanation) result() string {
+	if !x.Evaluated {
+		return "(not evaluated)"
+	}
+	if x.Err == nil {
+		return formatValue(x.Value)
+	}
+	for _, child := range x.Children {
+		if child.Err != nil {
+			return "error"
+		}
+	}
+	return "error: " + x.Err.Error()
+}
+
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return strconv.Quote(v)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+func isLiteralEvaluator(e Evaluator) bool {
+	switch e.(type) {
+	case nilEvaluator, *realNumericLiteralEvaluator, *stringLiteralEvaluator:
+		return true
+	default:
+		return false
+	}
+}
+
+// labelEvaluator is a placeholder to render the annotated expression.
+type labelEvaluator string
+
+func (e labelEvaluator) Eval(Variables) (interface{}, error) {
+	return nil, fmt.Errorf("Eval(`%s`) label can not eval", string(e))
+}
+
+func (e labelEvaluator) Strict(bool) {}
+
+func (e labelEvaluator) AsComparator() (Comparator, bool) {
+	return nil, false
+}
+
+func (e labelEvaluator) String() string {
+	return string(e)
+}
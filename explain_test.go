@@ -0,0 +1,77 @@
+package evaluator_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mashiike/evaluator"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplain(t *testing.T) {
+	e, err := evaluator.New("var1 / var2 + 1 > 2 && any(values, v -> v > 1)")
+	require.NoError(t, err, "must parse success")
+
+	explanation := evaluator.Explain(e, evaluator.Variables{"var1": 4, "var2": 2, "values": []float64{1, 2}})
+	require.NoError(t, explanation.Err)
+	require.Equal(t, true, explanation.Value)
+	require.Equal(t, "bool", explanation.Type)
+	require.Len(t, explanation.Children, 2)
+	require.Equal(t, "var1 / var2 + 1 > 2", explanation.Children[0].Expr)
+	require.Equal(t, "any(values, v -> v > 1)", explanation.Children[1].Expr)
+	require.Len(t, explanation.Children[1].Children, 1, "lambda body is not traced")
+	require.Equal(t, "(var1 / var2 + 1)=3 > 2", explanation.Children[0].Annotated())
+
+	e.Strict(true)
+	explanation = evaluator.Explain(e, evaluator.Variables{"var1": 4, "values": []float64{1, 2}})
+	require.Error(t, explanation.Err)
+	require.True(t, evaluator.IsVariableNotFound(explanation.Err))
+	require.Equal(t, []string{
+		"(var1 / var2 + 1 > 2) && any(values, v -> v > 1) → error",
+		"  (var1 / var2 + 1) > 2 → error",
+		"    (var1 / var2) + 1 → error",
+		"      var1=4 / var2 → error",
+		"        var1 → 4",
+		"        var2 → error: var2 variable not found",
+		"  any(values, v -> v > 1) → (not evaluated)",
+		"    values → (not evaluated)",
+		"",
+	}, strings.Split(explanation.String(), "\n"))
+}
+
+func TestExplainWithCostBudget(t *testing.T) {
+	e, err := evaluator.New("x > 1 && y < 2", evaluator.WithCostBudget(100))
+	require.NoError(t, err)
+	explanation := evaluator.Explain(e, evaluator.Variables{"x": 2, "y": 1})
+	require.NoError(t, explanation.Err)
+	require.Equal(t, []string{
+		"(x > 1)=true && (y < 2)=true → true",
+		"  x=2 > 1 → true",
+		"    x → 2",
+		"  y=1 < 2 → true",
+		"    y → 1",
+		"",
+	}, strings.Split(explanation.String(), "\n"))
+
+	e, err = evaluator.New("x > 1 && y < 2", evaluator.WithCostBudget(3))
+	require.NoError(t, err)
+	explanation = evaluator.Explain(e, evaluator.Variables{"x": 2, "y": 1})
+	require.True(t, errors.Is(explanation.Err, evaluator.ErrBudgetExceeded))
+}
+
+func TestExplainWithRecover(t *testing.T) {
+	e, err := evaluator.New("x > 1")
+	require.NoError(t, err)
+	explanation := evaluator.Explain(evaluator.Recover(e), evaluator.Variables{"x": 2})
+	require.NoError(t, explanation.Err)
+	require.Equal(t, []string{
+		"x=2 > 1 → true",
+		"  x → 2",
+		"",
+	}, strings.Split(explanation.String(), "\n"))
+
+	explanation = evaluator.Explain(evaluator.Recover(panicEvaluator{}), nil)
+	var panicErr *evaluator.PanicError
+	require.True(t, errors.As(explanation.Err, &panicErr))
+}
@@ -2,49 +2,194 @@ package evaluator
 
 import (
 	"fmt"
+	"go/token"
+	"math"
 	"regexp"
 	"strings"
+	"sync"
 )
 
 type callFunc func(...interface{}) (interface{}, error)
 
-func getCallFunc(funcName string, argEvaluators []Evaluator) (callFunc, error) {
-	switch funcName {
-	case "rate": //rate(number, number)
-		if len(argEvaluators) != 2 {
-			return nil, newNumOfArgumentsMismatchError(funcName, 2, len(argEvaluators))
-		}
-		return rateCallFunc, nil
-	case "coalesce": //coalesce(any, any, ...)
-		return coalesceCallFunc, nil
-	case "as_numeric": // as_numeric(any)
-		if len(argEvaluators) != 1 {
-			return nil, newNumOfArgumentsMismatchError(funcName, 1, len(argEvaluators))
-		}
-		return asNumericCallFunc, nil
-	case "as_string": // as_string(any)
-		if len(argEvaluators) != 1 {
-			return nil, newNumOfArgumentsMismatchError(funcName, 1, len(argEvaluators))
-		}
-		return asStringCallFunc, nil
-	case "__if": // if(bool, any, any)
-		if len(argEvaluators) != 3 {
-			return nil, newNumOfArgumentsMismatchError(funcName, 3, len(argEvaluators))
-		}
-		return ifCallFunc, nil
-	case "string_contains": // string_contains(string,string)
-		if len(argEvaluators) != 2 {
-			return nil, newNumOfArgumentsMismatchError(funcName, 2, len(argEvaluators))
-		}
-		return stringContainsCallFunc, nil
-	case "regexp_match": // regexp_match(string,string)
-		if len(argEvaluators) != 2 {
-			return nil, newNumOfArgumentsMismatchError(funcName, 2, len(argEvaluators))
-		}
-		return regexMatchCallFunc, nil
-	default:
-		return nil, fmt.Errorf("%s() func is not found", funcName)
+// builtinFunc is a built-in function. builtinFuncs is the only list of the built-in functions,
+// the parser, Check, EvalBatch, FuncSet, the limits and the translators refer to the properties of it.
+type builtinFunc struct {
+	name string
+	// params is the signature of the parameters, like `number, number`.
+	params string
+	// minArgs and maxArgs are the range of the number of the arguments, maxArgs is -1 for any number of them.
+	minArgs int
+	maxArgs int
+	// result is the kind of the result inferred by Check.
+	result valueKind
+	// one of call, higherOrder and stateful is the implementation.
+	call        callFunc
+	higherOrder *higherOrderFuncSpec
+	stateful    statefulFunc
+	// exactCall is call without the coercion of the arguments, which is used instead of call by WithoutCoercion.
+	exactCall callFunc
+	// resultSize estimates the size of the result of call, which is charged to the cost budget before the call
+	// since the result can be much larger than the arguments.
+	resultSize func(args ...interface{}) int
+	// regexp reports whether the second argument is the pattern of the regular expression.
+	regexp bool
+	// numeric reports whether the function takes numbers and returns a number, which is vectorized by EvalBatch.
+	numeric bool
+	// sql are the functions of the same semantics in the SQL dialects.
+	sql map[SQLDialect]string
+	// javaScript reports whether the runtime of ToJavaScript implements the function.
+	javaScript bool
+	// goMath is the function of math package called by GenerateGo.
+	goMath string
+}
+
+// builtinFuncs are the built-in functions sorted by the name.
+var builtinFuncs = []builtinFunc{
+	{name: "abs", params: "number", minArgs: 1, maxArgs: 1, result: kindNumber, call: newUnaryMathCallFunc("abs", math.Abs),
+		numeric: true, sql: sqlFunc("ABS"), javaScript: true, goMath: "math.Abs"},
+	{name: "all", params: "list, x -> bool", minArgs: 2, maxArgs: 2, result: kindBool,
+		higherOrder: &higherOrderFuncSpec{f: allHigherOrderFunc, numOfParams: 1}},
+	{name: "any", params: "list, x -> bool", minArgs: 2, maxArgs: 2, result: kindBool,
+		higherOrder: &higherOrderFuncSpec{f: anyHigherOrderFunc, numOfParams: 1}},
+	{name: "as_numeric", params: "any", minArgs: 1, maxArgs: 1, result: kindNumber, call: asNumericCallFunc, javaScript: true},
+	{name: "as_string", params: "any", minArgs: 1, maxArgs: 1, result: kindString, call: asStringCallFunc, javaScript: true},
+	{name: "avg", params: "list or number, ...", minArgs: 1, maxArgs: -1, result: kindNumber, call: avgCallFunc},
+	{name: "ceil", params: "number", minArgs: 1, maxArgs: 1, result: kindNumber, call: newUnaryMathCallFunc("ceil", math.Ceil),
+		numeric: true, sql: sqlFunc("CEIL"), javaScript: true, goMath: "math.Ceil"},
+	{name: "clamp", params: "number, number, number", minArgs: 3, maxArgs: 3, result: kindNumber, call: clampCallFunc, numeric: true},
+	{name: "coalesce", params: "any, any, ...", minArgs: 0, maxArgs: -1, result: kindAny, call: coalesceCallFunc,
+		sql: sqlFunc("COALESCE"), javaScript: true},
+	{name: "count", params: "list or number, ...", minArgs: 1, maxArgs: -1, result: kindNumber, call: countCallFunc},
+	{name: "count_if", params: "list, x -> bool", minArgs: 2, maxArgs: 2, result: kindNumber,
+		higherOrder: &higherOrderFuncSpec{f: countIfHigherOrderFunc, numOfParams: 1}},
+	{name: "delta", params: "number", minArgs: 1, maxArgs: 1, result: kindNumber, stateful: deltaStatefulFunc},
+	{name: "equal_fold", params: "string, string", minArgs: 2, maxArgs: 2, result: kindBool,
+		call: newBinaryStringCallFunc("equal_fold", func(s1, s2 string) interface{} { return strings.EqualFold(s1, s2) })},
+	{name: "ewma", params: "number, number", minArgs: 2, maxArgs: 2, result: kindNumber, stateful: ewmaStatefulFunc},
+	{name: "exp", params: "number", minArgs: 1, maxArgs: 1, result: kindNumber, call: newUnaryMathCallFunc("exp", math.Exp),
+		numeric: true, sql: sqlFunc("EXP"), javaScript: true, goMath: "math.Exp"},
+	{name: "filter", params: "list, x -> bool", minArgs: 2, maxArgs: 2, result: kindList,
+		higherOrder: &higherOrderFuncSpec{f: filterHigherOrderFunc, numOfParams: 1}},
+	{name: "floor", params: "number", minArgs: 1, maxArgs: 1, result: kindNumber, call: newUnaryMathCallFunc("floor", math.Floor),
+		numeric: true, sql: sqlFunc("FLOOR"), javaScript: true, goMath: "math.Floor"},
+	{name: "format", params: "string, any, ...", minArgs: 1, maxArgs: -1, result: kindString, call: formatCallFunc, resultSize: formatResultSize},
+	{name: "has_prefix", params: "string, string", minArgs: 2, maxArgs: 2, result: kindBool,
+		call: newBinaryStringCallFunc("has_prefix", func(s1, s2 string) interface{} { return strings.HasPrefix(s1, s2) }), javaScript: true},
+	{name: "has_suffix", params: "string, string", minArgs: 2, maxArgs: 2, result: kindBool,
+		call: newBinaryStringCallFunc("has_suffix", func(s1, s2 string) interface{} { return strings.HasSuffix(s1, s2) }), javaScript: true},
+	{name: "if", params: "bool, any, any", minArgs: 3, maxArgs: 3, result: kindAny, call: newIfCallFunc(asBool),
+		exactCall: newIfCallFunc(isBool), javaScript: true},
+	{name: "increase", params: "number", minArgs: 1, maxArgs: 1, result: kindNumber, stateful: increaseStatefulFunc},
+	{name: "is_inf", params: "number", minArgs: 1, maxArgs: 1, result: kindBool, call: isInfCallFunc},
+	{name: "is_nan", params: "number", minArgs: 1, maxArgs: 1, result: kindBool, call: isNaNCallFunc},
+	{name: "join", params: "list, string", minArgs: 2, maxArgs: 2, result: kindString, call: newJoinCallFunc(asString),
+		exactCall: newJoinCallFunc(isString), resultSize: joinResultSize},
+	{name: "len", params: "string or list", minArgs: 1, maxArgs: 1, result: kindNumber, call: lenCallFunc, sql: sqlFunc("LENGTH")},
+	{name: "log", params: "number", minArgs: 1, maxArgs: 1, result: kindNumber, call: newUnaryMathCallFunc("log", math.Log),
+		numeric: true, sql: sqlFunc("LN"), javaScript: true, goMath: "math.Log"},
+	{name: "log10", params: "number", minArgs: 1, maxArgs: 1, result: kindNumber, call: newUnaryMathCallFunc("log10", math.Log10),
+		numeric: true, sql: map[SQLDialect]string{SQLDialectPostgres: "LOG", SQLDialectSQLite: "LOG10"}, javaScript: true, goMath: "math.Log10"},
+	{name: "log2", params: "number", minArgs: 1, maxArgs: 1, result: kindNumber, call: newUnaryMathCallFunc("log2", math.Log2),
+		numeric: true, sql: map[SQLDialect]string{SQLDialectSQLite: "LOG2"}, javaScript: true, goMath: "math.Log2"},
+	{name: "lower", params: "string", minArgs: 1, maxArgs: 1, result: kindString, call: newUnaryStringCallFunc("lower", strings.ToLower),
+		sql: sqlFunc("LOWER"), javaScript: true},
+	{name: "map", params: "list, x -> any", minArgs: 2, maxArgs: 2, result: kindList,
+		higherOrder: &higherOrderFuncSpec{f: mapHigherOrderFunc, numOfParams: 1}},
+	{name: "max", params: "list or number, ...", minArgs: 1, maxArgs: -1, result: kindNumber, call: maxCallFunc},
+	{name: "median", params: "list or number, ...", minArgs: 1, maxArgs: -1, result: kindNumber, call: medianCallFunc},
+	{name: "min", params: "list or number, ...", minArgs: 1, maxArgs: -1, result: kindNumber, call: minCallFunc},
+	{name: "moving_avg", params: "number, number", minArgs: 2, maxArgs: 2, result: kindNumber, stateful: movingAvgStatefulFunc},
+	{name: "none", params: "list, x -> bool", minArgs: 2, maxArgs: 2, result: kindBool,
+		higherOrder: &higherOrderFuncSpec{f: noneHigherOrderFunc, numOfParams: 1}},
+	{name: "normalize", params: "string[, string]", minArgs: 1, maxArgs: 2, result: kindString, call: normalizeCallFunc},
+	{name: "percentile", params: "list, number", minArgs: 2, maxArgs: 2, result: kindNumber, call: percentileCallFunc},
+	{name: "pow", params: "number, number", minArgs: 2, maxArgs: 2, result: kindNumber, call: newBinaryMathCallFunc("pow", math.Pow),
+		numeric: true, sql: map[SQLDialect]string{SQLDialectPostgres: "POWER", SQLDialectSQLite: "POW"}, javaScript: true, goMath: "math.Pow"},
+	{name: "prev", params: "any", minArgs: 1, maxArgs: 1, result: kindAny, stateful: prevStatefulFunc},
+	{name: "rate", params: "number, number", minArgs: 2, maxArgs: 2, result: kindNumber, call: rateCallFunc, numeric: true, javaScript: true},
+	{name: "reduce", params: "list, any, (acc, x) -> any", minArgs: 3, maxArgs: 3, result: kindAny,
+		higherOrder: &higherOrderFuncSpec{f: reduceHigherOrderFunc, numOfParams: 2}},
+	{name: "regexp_extract", params: "string, string[, number]", minArgs: 2, maxArgs: 3, result: kindString, call: regexpExtractCallFunc, regexp: true},
+	{name: "regexp_match", params: "string, string", minArgs: 2, maxArgs: 2, result: kindBool, call: regexMatchCallFunc, regexp: true, javaScript: true},
+	{name: "regexp_replace", params: "string, string, string", minArgs: 3, maxArgs: 3, result: kindString, call: regexpReplaceCallFunc,
+		resultSize: regexpReplaceResultSize, regexp: true},
+	{name: "replace", params: "string, string, string", minArgs: 3, maxArgs: 3, result: kindString, call: replaceCallFunc,
+		resultSize: replaceResultSize, sql: sqlFunc("REPLACE")},
+	{name: "round", params: "number", minArgs: 1, maxArgs: 1, result: kindNumber, call: newUnaryMathCallFunc("round", math.Round),
+		numeric: true, javaScript: true, goMath: "math.Round"},
+	{name: "round_to", params: "number, number", minArgs: 2, maxArgs: 2, result: kindNumber, call: newBinaryMathCallFunc("round_to", roundTo), numeric: true},
+	{name: "sign", params: "number", minArgs: 1, maxArgs: 1, result: kindNumber, call: newUnaryMathCallFunc("sign", sign),
+		numeric: true, sql: map[SQLDialect]string{SQLDialectPostgres: "SIGN"}, javaScript: true},
+	{name: "split", params: "string, string", minArgs: 2, maxArgs: 2, result: kindList, call: newBinaryStringCallFunc("split", splitString)},
+	{name: "sqrt", params: "number", minArgs: 1, maxArgs: 1, result: kindNumber, call: newUnaryMathCallFunc("sqrt", math.Sqrt),
+		numeric: true, sql: sqlFunc("SQRT"), javaScript: true, goMath: "math.Sqrt"},
+	{name: "stddev", params: "list or number, ...", minArgs: 1, maxArgs: -1, result: kindNumber, call: stddevCallFunc},
+	{name: "string_contains", params: "string, string", minArgs: 2, maxArgs: 2, result: kindBool, call: stringContainsCallFunc, javaScript: true},
+	{name: "substr", params: "string, number[, number]", minArgs: 2, maxArgs: 3, result: kindString, call: substrCallFunc},
+	{name: "sum", params: "list or number, ...", minArgs: 1, maxArgs: -1, result: kindNumber, call: sumCallFunc},
+	{name: "trim", params: "string[, string]", minArgs: 1, maxArgs: 2, result: kindString, call: trimCallFunc, sql: sqlFunc("TRIM")},
+	{name: "trunc", params: "number", minArgs: 1, maxArgs: 1, result: kindNumber, call: newUnaryMathCallFunc("trunc", math.Trunc),
+		numeric: true, sql: sqlFunc("TRUNC"), javaScript: true, goMath: "math.Trunc"},
+	{name: "upper", params: "string", minArgs: 1, maxArgs: 1, result: kindString, call: newUnaryStringCallFunc("upper", strings.ToUpper),
+		sql: sqlFunc("UPPER"), javaScript: true},
+}
+
+// sqlFunc is the SQL function of the same name in all dialects.
+func sqlFunc(name string) map[SQLDialect]string {
+	return map[SQLDialect]string{SQLDialectPostgres: name, SQLDialectSQLite: name}
+}
+
+var builtinFuncsByName = func() map[string]*builtinFunc {
+	m := make(map[string]*builtinFunc, len(builtinFuncs))
+	for i := range builtinFuncs {
+		m[builtinFuncs[i].name] = &builtinFuncs[i]
+	}
+	return m
+}()
+
+// getBuiltinFunc returns the built-in function of the name, like `if`.
+func getBuiltinFunc(name string) (*builtinFunc, bool) {
+	f, ok := builtinFuncsByName[name]
+	return f, ok
+}
+
+// calledBuiltinFunc returns the built-in function called by the name in the prepared expression,
+// the functions whose names are the Go keywords are called with the prefix, like `__if`.
+func calledBuiltinFunc(funcName string) (*builtinFunc, bool) {
+	name := strings.TrimPrefix(funcName, "__")
+	f, ok := getBuiltinFunc(name)
+	if !ok || (name != funcName) != token.Lookup(name).IsKeyword() {
+		return nil, false
+	}
+	return f, true
+}
+
+func (f *builtinFunc) signature() string {
+	return f.name + "(" + f.params + ")"
+}
+
+func (f *builtinFunc) checkNumOfArgs(n int) error {
+	if n < f.minArgs || (f.maxArgs >= 0 && n > f.maxArgs) {
+		return newNumOfArgumentsMismatchError(f.name, f.minArgs, f.maxArgs, n)
+	}
+	return nil
+}
+
+// resultKindOf returns the result kind of the function, the custom functions return any.
+func resultKindOf(funcName string) valueKind {
+	if f, ok := getBuiltinFunc(funcName); ok {
+		return f.result
+	}
+	return kindAny
+}
+
+// Functions returns the signatures of the built-in functions sorted by the name, like `rate(number, number)`.
+func Functions() []string {
+	ret := make([]string, 0, len(builtinFuncs))
+	for i := range builtinFuncs {
+		ret = append(ret, builtinFuncs[i].signature())
 	}
+	return ret
 }
 
 func rateCallFunc(args ...interface{}) (interface{}, error) {
@@ -81,15 +226,18 @@ func asNumericCallFunc(args ...interface{}) (interface{}, error) {
 	return nil, nil
 }
 
-func ifCallFunc(args ...interface{}) (interface{}, error) {
-	cond, ok := asBool(args[0])
-	if !ok {
-		return nil, fmt.Errorf("if(v1[%v]::%T,v2[%v]::%T,v3[%v]::%T) can not eval", args[0], args[0], args[1], args[1], args[2], args[2])
-	}
-	if cond {
-		return args[1], nil
+// newIfCallFunc returns if(cond, then, else), the condition is converted to bool by the conversion.
+func newIfCallFunc(conv func(interface{}) (bool, bool)) callFunc {
+	return func(args ...interface{}) (interface{}, error) {
+		cond, ok := conv(args[0])
+		if !ok {
+			return nil, fmt.Errorf("if(v1[%v]::%T,v2[%v]::%T,v3[%v]::%T) can not eval", args[0], args[0], args[1], args[1], args[2], args[2])
+		}
+		if cond {
+			return args[1], nil
+		}
+		return args[2], nil
 	}
-	return args[2], nil
 }
 
 func stringContainsCallFunc(args ...interface{}) (interface{}, error) {
@@ -105,33 +253,42 @@ type regexpCacheEntry struct {
 	reg     *regexp.Regexp
 }
 
-var regexpCache = make([]regexpCacheEntry, 0, 10)
+var (
+	regexpCacheMu sync.Mutex
+	regexpCache   = make([]regexpCacheEntry, 0, 10)
+)
+
+// compileRegexp compiles the pattern with the cache shared by regexp functions.
+func compileRegexp(pattern string) (*regexp.Regexp, error) {
+	regexpCacheMu.Lock()
+	defer regexpCacheMu.Unlock()
+	for _, entry := range regexpCache {
+		if entry.pattern == pattern {
+			return entry.reg, nil
+		}
+	}
+	reg, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	regexpCache = append(regexpCache, regexpCacheEntry{
+		pattern: pattern,
+		reg:     reg,
+	})
+	if len(regexpCache) > 100 {
+		regexpCache = regexpCache[1:]
+	}
+	return reg, nil
+}
 
 func regexMatchCallFunc(args ...interface{}) (interface{}, error) {
 	s1, s2, ok := isBothStrings(args[0], args[1])
 	if !ok {
 		return nil, fmt.Errorf("regex_match(v1[%v]::%T,v2[%v]::%T) can not eval", args[0], args[0], args[1], args[1])
 	}
-	var reg *regexp.Regexp
-	for _, entry := range regexpCache {
-		if entry.pattern == s2 {
-			reg = entry.reg
-			break
-		}
-	}
-	if reg == nil {
-		var err error
-		reg, err = regexp.Compile(s2)
-		if err != nil {
-			return nil, fmt.Errorf("regex_match(v1[%v]::%T,v2[%v]::%T) pattern can not compile: %w", args[0], args[0], args[1], args[1], err)
-		}
-		regexpCache = append(regexpCache, regexpCacheEntry{
-			pattern: s2,
-			reg:     reg,
-		})
-		if len(regexpCache) > 100 {
-			regexpCache = regexpCache[1:]
-		}
+	reg, err := compileRegexp(s2)
+	if err != nil {
+		return nil, fmt.Errorf("regex_match(v1[%v]::%T,v2[%v]::%T) pattern can not compile: %w", args[0], args[0], args[1], args[1], err)
 	}
 	return reg.Match([]byte(s1)), nil
 }
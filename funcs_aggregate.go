@@ -0,0 +1,168 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// aggregate functions accept list values and/or scalar values.
+// nil values are skipped as same as coalesce(), and if no value remains, the result is nil (count() is 0).
+
+func collectRealNumbers(funcName string, args []interface{}) ([]float64, error) {
+	values := make([]float64, 0, len(args))
+	appendValue := func(v interface{}) error {
+		if v == nil {
+			return nil
+		}
+		n, ok := isRealNumber(v)
+		if !ok {
+			return fmt.Errorf("%s(v[%v]::%T) can not eval", funcName, v, v)
+		}
+		values = append(values, n)
+		return nil
+	}
+	for _, arg := range args {
+		if list, ok := isList(arg); ok {
+			for _, v := range list {
+				if err := appendValue(v); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		if err := appendValue(arg); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+func sumCallFunc(args ...interface{}) (interface{}, error) {
+	values, err := collectRealNumbers("sum", args)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+	return sumRealNumbers(values), nil
+}
+
+func sumRealNumbers(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum
+}
+
+func avgCallFunc(args ...interface{}) (interface{}, error) {
+	values, err := collectRealNumbers("avg", args)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+	return sumRealNumbers(values) / float64(len(values)), nil
+}
+
+func minCallFunc(args ...interface{}) (interface{}, error) {
+	values, err := collectRealNumbers("min", args)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+	ret := values[0]
+	for _, v := range values[1:] {
+		ret = math.Min(ret, v)
+	}
+	return ret, nil
+}
+
+func maxCallFunc(args ...interface{}) (interface{}, error) {
+	values, err := collectRealNumbers("max", args)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+	ret := values[0]
+	for _, v := range values[1:] {
+		ret = math.Max(ret, v)
+	}
+	return ret, nil
+}
+
+func countCallFunc(args ...interface{}) (interface{}, error) {
+	values, err := collectRealNumbers("count", args)
+	if err != nil {
+		return nil, err
+	}
+	return float64(len(values)), nil
+}
+
+// stddevCallFunc returns the population standard deviation.
+func stddevCallFunc(args ...interface{}) (interface{}, error) {
+	values, err := collectRealNumbers("stddev", args)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+	mean := sumRealNumbers(values) / float64(len(values))
+	var variance float64
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(variance / float64(len(values))), nil
+}
+
+func medianCallFunc(args ...interface{}) (interface{}, error) {
+	values, err := collectRealNumbers("median", args)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+	return percentileRealNumbers(values, 50), nil
+}
+
+// percentileCallFunc is percentile(list, p), p is in the range of 0 to 100.
+func percentileCallFunc(args ...interface{}) (interface{}, error) {
+	p, ok := isRealNumber(args[1])
+	if !ok {
+		return nil, fmt.Errorf("percentile(v1[%v]::%T,v2[%v]::%T) can not eval", args[0], args[0], args[1], args[1])
+	}
+	if p < 0 || p > 100 || math.IsNaN(p) {
+		return nil, fmt.Errorf("percentile(v1[%v]::%T,v2[%v]::%T) percentile must be between 0 and 100", args[0], args[0], args[1], args[1])
+	}
+	values, err := collectRealNumbers("percentile", args[:1])
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+	return percentileRealNumbers(values, p), nil
+}
+
+// percentileRealNumbers calculates with linear interpolation between closest ranks.
+func percentileRealNumbers(values []float64, p float64) float64 {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	if lower == upper {
+		return sorted[lower]
+	}
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
@@ -0,0 +1,95 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+)
+
+// math functions return nil if any argument is nil.
+// The results follow the math package, for example sqrt(-1) is NaN and log(0) is -Inf.
+// min() and max() are shared with the aggregate functions, so they accept scalar values as well as list values.
+
+func newUnaryMathCallFunc(funcName string, f func(float64) float64) callFunc {
+	return func(args ...interface{}) (interface{}, error) {
+		if args[0] == nil {
+			return nil, nil
+		}
+		n, ok := isRealNumber(args[0])
+		if !ok {
+			return nil, fmt.Errorf("%s(v[%v]::%T) can not eval", funcName, args[0], args[0])
+		}
+		return f(n), nil
+	}
+}
+
+func newBinaryMathCallFunc(funcName string, f func(float64, float64) float64) callFunc {
+	return func(args ...interface{}) (interface{}, error) {
+		if args[0] == nil || args[1] == nil {
+			return nil, nil
+		}
+		n1, n2, ok := isBothRealNumbers(args[0], args[1])
+		if !ok {
+			return nil, fmt.Errorf("%s(v1[%v]::%T,v2[%v]::%T) can not eval", funcName, args[0], args[0], args[1], args[1])
+		}
+		return f(n1, n2), nil
+	}
+}
+
+func sign(n float64) float64 {
+	switch {
+	case n > 0:
+		return 1
+	case n < 0:
+		return -1
+	default:
+		return n // 0, -0 or NaN
+	}
+}
+
+// roundTo rounds half away from zero to the given number of decimal places.
+// n is returned unchanged if 10^places overflows or underflows, since it can not be scaled.
+func roundTo(n, places float64) float64 {
+	p := math.Pow(10, math.Trunc(places))
+	if p == 0 || math.IsInf(n*p, 0) {
+		return n
+	}
+	return math.Round(n*p) / p
+}
+
+func clampCallFunc(args ...interface{}) (interface{}, error) {
+	if args[0] == nil || args[1] == nil || args[2] == nil {
+		return nil, nil
+	}
+	n, ok1 := isRealNumber(args[0])
+	lower, upper, ok2 := isBothRealNumbers(args[1], args[2])
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("clamp(v1[%v]::%T,v2[%v]::%T,v3[%v]::%T) can not eval", args[0], args[0], args[1], args[1], args[2], args[2])
+	}
+	if lower > upper {
+		return nil, fmt.Errorf("clamp(v1[%v]::%T,v2[%v]::%T,v3[%v]::%T) lower bound is greater than upper bound", args[0], args[0], args[1], args[1], args[2], args[2])
+	}
+	return math.Min(math.Max(n, lower), upper), nil
+}
+
+// isNaNCallFunc and isInfCallFunc return false for nil.
+func isNaNCallFunc(args ...interface{}) (interface{}, error) {
+	if args[0] == nil {
+		return false, nil
+	}
+	n, ok := isRealNumber(args[0])
+	if !ok {
+		return nil, fmt.Errorf("is_nan(v[%v]::%T) can not eval", args[0], args[0])
+	}
+	return math.IsNaN(n), nil
+}
+
+func isInfCallFunc(args ...interface{}) (interface{}, error) {
+	if args[0] == nil {
+		return false, nil
+	}
+	n, ok := isRealNumber(args[0])
+	if !ok {
+		return nil, fmt.Errorf("is_inf(v[%v]::%T) can not eval", args[0], args[0])
+	}
+	return math.IsInf(n, 0), nil
+}
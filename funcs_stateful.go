@@ -0,0 +1,241 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// siteState is the history of a call site of the stateful function, it is encoded to JSON by the snapshot.
+type siteState struct {
+	Prev    interface{}
+	HasPrev bool
+	Values  []float64
+}
+
+// siteStateJSON is the JSON of siteState, in which NaN and ±Inf are encoded by encodeNonFinite.
+type siteStateJSON struct {
+	Prev    interface{}   `json:"prev,omitempty"`
+	HasPrev bool          `json:"has_prev,omitempty"`
+	Values  []interface{} `json:"values,omitempty"`
+}
+
+// nonFiniteKey is the reserved key of the JSON object which encodes NaN or ±Inf, like {"\u0000float": "+Inf"},
+// since JSON can not represent them as numbers.
+const nonFiniteKey = "\x00float"
+
+func (state *siteState) MarshalJSON() ([]byte, error) {
+	var values []interface{}
+	for _, v := range state.Values {
+		values = append(values, encodeNonFinite(v))
+	}
+	return json.Marshal(siteStateJSON{
+		Prev:    encodeNonFinite(state.Prev),
+		HasPrev: state.HasPrev,
+		Values:  values,
+	})
+}
+
+func (state *siteState) UnmarshalJSON(data []byte) error {
+	var decoded siteStateJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	state.Prev, state.HasPrev, state.Values = decodeNonFinite(decoded.Prev), decoded.HasPrev, nil
+	for _, v := range decoded.Values {
+		n, ok := decodeNonFinite(v).(float64)
+		if !ok {
+			return fmt.Errorf("value %v of the history is not number", v)
+		}
+		state.Values = append(state.Values, n)
+	}
+	return nil
+}
+
+// encodeNonFinite replaces NaN and ±Inf in the value and the elements of the list with the JSON objects of nonFiniteKey.
+func encodeNonFinite(v interface{}) interface{} {
+	switch n := v.(type) {
+	case float64:
+		if math.IsNaN(n) || math.IsInf(n, 0) {
+			return map[string]string{nonFiniteKey: strconv.FormatFloat(n, 'g', -1, 64)}
+		}
+		return n
+	case float32:
+		return encodeNonFinite(float64(n))
+	}
+	if list, ok := isList(v); ok {
+		encoded := make([]interface{}, len(list))
+		for i, elem := range list {
+			encoded[i] = encodeNonFinite(elem)
+		}
+		return encoded
+	}
+	return v
+}
+
+// decodeNonFinite restores NaN and ±Inf encoded by encodeNonFinite from the decoded JSON.
+func decodeNonFinite(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if s, ok := v[nonFiniteKey].(string); ok && len(v) == 1 {
+			if n, err := strconv.ParseFloat(s, 64); err == nil {
+				return n
+			}
+		}
+		return v
+	case []interface{}:
+		for i, elem := range v {
+			v[i] = decodeNonFinite(elem)
+		}
+		return v
+	}
+	return v
+}
+
+type statefulFunc func(state *siteState, args ...interface{}) (interface{}, error)
+
+// prevStatefulFunc returns the value of the previous evaluation, nil for the first evaluation.
+func prevStatefulFunc(state *siteState, args ...interface{}) (interface{}, error) {
+	prev := state.Prev
+	state.Prev, state.HasPrev = args[0], true
+	return prev, nil
+}
+
+// deltaStatefulFunc returns the difference from the previous value.
+func deltaStatefulFunc(state *siteState, args ...interface{}) (interface{}, error) {
+	if args[0] == nil {
+		return nil, nil
+	}
+	n, ok := isRealNumber(args[0])
+	if !ok {
+		return nil, fmt.Errorf("delta(v1[%v]::%T) can not eval", args[0], args[0])
+	}
+	prev, hasPrev := state.prevNumber()
+	state.Prev, state.HasPrev = n, true
+	if !hasPrev {
+		return nil, nil
+	}
+	return n - prev, nil
+}
+
+// increaseStatefulFunc returns the increase of the counter from the previous value,
+// the counter is regarded as reset if it decreases, and the increase is the value itself.
+func increaseStatefulFunc(state *siteState, args ...interface{}) (interface{}, error) {
+	if args[0] == nil {
+		return nil, nil
+	}
+	n, ok := isRealNumber(args[0])
+	if !ok {
+		return nil, fmt.Errorf("increase(v1[%v]::%T) can not eval", args[0], args[0])
+	}
+	prev, hasPrev := state.prevNumber()
+	state.Prev, state.HasPrev = n, true
+	if !hasPrev {
+		return nil, nil
+	}
+	if n < prev {
+		return n, nil
+	}
+	return n - prev, nil
+}
+
+// maxMovingAvgSize is the maximum size of the window of moving_avg, the values in the window are kept in the history.
+const maxMovingAvgSize = 1 << 16
+
+// movingAvgStatefulFunc returns the average of the last n values including the current value.
+func movingAvgStatefulFunc(state *siteState, args ...interface{}) (interface{}, error) {
+	if args[0] == nil {
+		return nil, nil
+	}
+	n, ok1 := isRealNumber(args[0])
+	size, ok2 := isInteger(args[1])
+	if !ok1 || !ok2 || size < 1 {
+		return nil, fmt.Errorf("moving_avg(v1[%v]::%T,v2[%v]::%T) can not eval", args[0], args[0], args[1], args[1])
+	}
+	if size > maxMovingAvgSize {
+		return nil, fmt.Errorf("moving_avg(v1[%v]::%T,v2[%v]::%T) size exceeds %d", args[0], args[0], args[1], args[1], maxMovingAvgSize)
+	}
+	state.Values = append(state.Values, n)
+	if float64(len(state.Values)) > size {
+		state.Values = append(state.Values[:0], state.Values[len(state.Values)-int(size):]...)
+	}
+	sum := 0.0
+	for _, v := range state.Values {
+		sum += v
+	}
+	return sum / float64(len(state.Values)), nil
+}
+
+// ewmaStatefulFunc returns the exponentially weighted moving average, alpha is the weight of the current value.
+func ewmaStatefulFunc(state *siteState, args ...interface{}) (interface{}, error) {
+	if args[0] == nil {
+		return nil, nil
+	}
+	n, ok1 := isRealNumber(args[0])
+	alpha, ok2 := isRealNumber(args[1])
+	if !ok1 || !ok2 || !(alpha > 0 && alpha <= 1) {
+		return nil, fmt.Errorf("ewma(v1[%v]::%T,v2[%v]::%T) can not eval", args[0], args[0], args[1], args[1])
+	}
+	if prev, hasPrev := state.prevNumber(); hasPrev {
+		n = alpha*n + (1-alpha)*prev
+	}
+	state.Prev, state.HasPrev = n, true
+	return n, nil
+}
+
+func (state *siteState) prevNumber() (float64, bool) {
+	if !state.HasPrev {
+		return 0, false
+	}
+	return isRealNumber(state.Prev)
+}
+
+// statefulCallEvaluator is the call of the stateful function, the history is kept in the state of StatefulEvaluator by the site.
+type statefulCallEvaluator struct {
+	args     []Evaluator
+	f        statefulFunc
+	funcName string
+	site     string
+}
+
+func (e *statefulCallEvaluator) Eval(vars Variables) (interface{}, error) {
+	v, _ := vars.Lookup(stateVariableName)
+	sites, ok := v.(statefulSites)
+	if !ok {
+		return nil, fmt.Errorf("Eval(`%s`) %w", e, ErrStateNotGiven)
+	}
+	args := make([]interface{}, 0, len(e.args))
+	for i, a := range e.args {
+		arg, err := a.Eval(vars)
+		if err != nil {
+			return nil, fmt.Errorf("Eval(`%s`) Args[%d] %w", e, i, err)
+		}
+		args = append(args, arg)
+	}
+	state, ok := sites[e.site]
+	if !ok {
+		state = &siteState{}
+		sites[e.site] = state
+	}
+	return e.f(state, args...)
+}
+
+func (e *statefulCallEvaluator) Strict(v bool) {
+	for _, arg := range e.args {
+		arg.Strict(v)
+	}
+}
+
+func (e *statefulCallEvaluator) AsComparator() (Comparator, bool) {
+	return nil, false
+}
+
+func (e *statefulCallEvaluator) String() string {
+	args := make([]string, 0, len(e.args))
+	for _, arg := range e.args {
+		args = append(args, arg.String())
+	}
+	return e.funcName + "(" + strings.Join(args, ", ") + ")"
+}
@@ -0,0 +1,405 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// string functions return nil if the string argument is nil, as same as math functions.
+// Positions and lengths are counted in runes, not in bytes.
+
+func newUnaryStringCallFunc(funcName string, f func(string) string) callFunc {
+	return func(args ...interface{}) (interface{}, error) {
+		if args[0] == nil {
+			return nil, nil
+		}
+		s, ok := isString(args[0])
+		if !ok {
+			return nil, fmt.Errorf("%s(v[%v]::%T) can not eval", funcName, args[0], args[0])
+		}
+		return f(s), nil
+	}
+}
+
+func newBinaryStringCallFunc(funcName string, f func(string, string) interface{}) callFunc {
+	return func(args ...interface{}) (interface{}, error) {
+		if args[0] == nil || args[1] == nil {
+			return nil, nil
+		}
+		s1, s2, ok := isBothStrings(args[0], args[1])
+		if !ok {
+			return nil, fmt.Errorf("%s(v1[%v]::%T,v2[%v]::%T) can not eval", funcName, args[0], args[0], args[1], args[1])
+		}
+		return f(s1, s2), nil
+	}
+}
+
+func splitString(s, sep string) interface{} {
+	parts := strings.Split(s, sep)
+	list := make([]interface{}, 0, len(parts))
+	for _, part := range parts {
+		list = append(list, part)
+	}
+	return list
+}
+
+// newJoinCallFunc returns join(list, sep), the elements are converted to strings by the conversion.
+func newJoinCallFunc(conv func(interface{}) (string, bool)) callFunc {
+	return func(args ...interface{}) (interface{}, error) {
+		if args[0] == nil {
+			return nil, nil
+		}
+		list, ok1 := isList(args[0])
+		sep, ok2 := isString(args[1])
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("join(v1[%v]::%T,v2[%v]::%T) can not eval", args[0], args[0], args[1], args[1])
+		}
+		parts := make([]string, 0, len(list))
+		for _, v := range list {
+			if v == nil {
+				continue
+			}
+			s, ok := conv(v)
+			if !ok {
+				return nil, fmt.Errorf("join(v1[%v]::%T,v2[%v]::%T) element v[%v]::%T can not convert to string", args[0], args[0], args[1], args[1], v, v)
+			}
+			parts = append(parts, s)
+		}
+		return strings.Join(parts, sep), nil
+	}
+}
+
+// joinResultSize is the size of the result of join, the elements which are not strings are converted to estimate it.
+func joinResultSize(args ...interface{}) int {
+	list, ok1 := isList(args[0])
+	sep, ok2 := isString(args[1])
+	if !ok1 || !ok2 {
+		return 0
+	}
+	size := 0
+	for i, v := range list {
+		if s, ok := asString(v); ok && v != nil {
+			size += len(s)
+		}
+		if i > 0 {
+			size += len(sep)
+		}
+	}
+	return size
+}
+
+// replaceResultSize is the size of the result of replace.
+func replaceResultSize(args ...interface{}) int {
+	s, ok1 := isString(args[0])
+	oldStr, newStr, ok2 := isBothStrings(args[1], args[2])
+	if !ok1 || !ok2 {
+		return 0
+	}
+	return len(s) + strings.Count(s, oldStr)*(len(newStr)-len(oldStr))
+}
+
+func replaceCallFunc(args ...interface{}) (interface{}, error) {
+	if args[0] == nil {
+		return nil, nil
+	}
+	s, ok1 := isString(args[0])
+	oldStr, newStr, ok2 := isBothStrings(args[1], args[2])
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("replace(v1[%v]::%T,v2[%v]::%T,v3[%v]::%T) can not eval", args[0], args[0], args[1], args[1], args[2], args[2])
+	}
+	return strings.ReplaceAll(s, oldStr, newStr), nil
+}
+
+func trimCallFunc(args ...interface{}) (interface{}, error) {
+	if args[0] == nil {
+		return nil, nil
+	}
+	s, ok := isString(args[0])
+	if !ok {
+		return nil, fmt.Errorf("trim(v[%v]::%T) can not eval", args[0], args[0])
+	}
+	if len(args) == 1 {
+		return strings.TrimSpace(s), nil
+	}
+	cutset, ok := isString(args[1])
+	if !ok {
+		return nil, fmt.Errorf("trim(v1[%v]::%T,v2[%v]::%T) can not eval", args[0], args[0], args[1], args[1])
+	}
+	return strings.Trim(s, cutset), nil
+}
+
+// substrCallFunc is substr(string, start[, length]), start is 0-origin.
+// The start and the length must be integers, the range out of the string is ignored.
+func substrCallFunc(args ...interface{}) (interface{}, error) {
+	if args[0] == nil {
+		return nil, nil
+	}
+	s, ok1 := isString(args[0])
+	start, ok2 := isInteger(args[1])
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("substr(v1[%v]::%T,v2[%v]::%T) can not eval", args[0], args[0], args[1], args[1])
+	}
+	runes := []rune(s)
+	begin := clampIndex(start, len(runes))
+	end := len(runes)
+	if len(args) == 3 {
+		length, ok := isInteger(args[2])
+		if !ok || length < 0 {
+			return nil, fmt.Errorf("substr(v1[%v]::%T,v2[%v]::%T,v3[%v]::%T) can not eval", args[0], args[0], args[1], args[1], args[2], args[2])
+		}
+		end = clampIndex(float64(begin)+length, len(runes))
+	}
+	return string(runes[begin:end]), nil
+}
+
+func clampIndex(n float64, length int) int {
+	if n < 0 {
+		return 0
+	}
+	if n > float64(length) {
+		return length
+	}
+	return int(n)
+}
+
+func lenCallFunc(args ...interface{}) (interface{}, error) {
+	if args[0] == nil {
+		return nil, nil
+	}
+	if s, ok := isString(args[0]); ok {
+		return float64(utf8.RuneCountInString(s)), nil
+	}
+	if list, ok := isList(args[0]); ok {
+		return float64(len(list)), nil
+	}
+	return nil, fmt.Errorf("len(v[%v]::%T) can not eval", args[0], args[0])
+}
+
+// formatCallFunc is format(format, args...) with the verbs of fmt.Sprintf.
+// The numbers are float64, so the numbers given to the integer verbs like %d and to the width and the precision `*` are converted to int64,
+// and the numbers which are not integers are rejected by %d, %o, %O, %c and %U.
+func formatCallFunc(args ...interface{}) (interface{}, error) {
+	format, ok := isString(args[0])
+	if !ok {
+		return nil, fmt.Errorf("format(v[%v]::%T) can not eval", args[0], args[0])
+	}
+	values := make([]interface{}, len(args)-1)
+	copy(values, args[1:])
+	verbs, _ := formatVerbs(format)
+	for i, verb := range verbs {
+		if i >= len(values) || !strings.ContainsRune("bcdoOxXU*", verb) {
+			continue
+		}
+		n, ok := values[i].(float64)
+		if !ok {
+			continue
+		}
+		if integer, ok := isInteger(n); ok && integer >= math.MinInt64 && integer < math.MaxInt64 {
+			values[i] = int64(integer)
+		} else if strings.ContainsRune("cdoOU", verb) {
+			return nil, fmt.Errorf("format(v[%v]::%T) %%%c is expected integer, but given v[%v]::%T", args[0], args[0], verb, n, n)
+		}
+	}
+	return fmt.Sprintf(format, values...), nil
+}
+
+// formatVerbs returns the verbs of the format by the indexes of the arguments, the verb of the arguments of the width and the precision is `*`.
+// It also returns the sum of the widths and the precisions written in the format.
+func formatVerbs(format string) (map[int]rune, int) {
+	verbs := make(map[int]rune)
+	argNum, widths := 0, 0
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		// skip the flags, the width, the precision and the argument indexes
+		width := 0
+		for i++; i < len(format); i++ {
+			c := format[i]
+			if '0' <= c && c <= '9' {
+				width = width*10 + int(c-'0')
+				if width > maxFormatWidth {
+					width = maxFormatWidth
+				}
+				continue
+			}
+			widths, width = widths+width, 0
+			if c == '[' {
+				end := strings.IndexByte(format[i:], ']')
+				if end < 0 {
+					return verbs, widths
+				}
+				if n, err := strconv.Atoi(format[i+1 : i+end]); err == nil {
+					argNum = n - 1
+				}
+				i += end
+				continue
+			}
+			if c == '*' {
+				verbs[argNum] = '*'
+				argNum++
+				continue
+			}
+			if !strings.ContainsRune("+-# .", rune(c)) {
+				break
+			}
+		}
+		widths += width
+		if i >= len(format) {
+			break
+		}
+		verb, size := utf8.DecodeRuneInString(format[i:])
+		i += size - 1
+		if verb == '%' {
+			continue
+		}
+		verbs[argNum] = verb
+		argNum++
+	}
+	return verbs, widths
+}
+
+// maxFormatWidth is the maximum width and precision of fmt, the larger ones are reported as the errors in the result.
+const maxFormatWidth = 1e6
+
+// formatResultSize estimates the size of the result of format by the format, the widths, the precisions and the arguments.
+func formatResultSize(args ...interface{}) int {
+	format, ok := isString(args[0])
+	if !ok {
+		return 0
+	}
+	verbs, widths := formatVerbs(format)
+	size := len(format) + widths
+	for i, arg := range args[1:] {
+		if n, ok := isRealNumber(arg); ok && verbs[i] == '*' {
+			if w := math.Abs(n); w < maxFormatWidth {
+				size += int(w)
+			} else {
+				size += maxFormatWidth
+			}
+			continue
+		}
+		size += formatArgSize(arg)
+	}
+	return size
+}
+
+// formatArgSize estimates the size of the argument formatted by the verbs like %v, the numbers and the others are formatted in a few bytes.
+func formatArgSize(v interface{}) int {
+	if s, ok := isString(v); ok {
+		return len(s)
+	}
+	if list, ok := isList(v); ok {
+		size := 2
+		for _, elem := range list {
+			size += formatArgSize(elem) + 1
+		}
+		return size
+	}
+	return 24
+}
+
+// regexpExtractCallFunc is regexp_extract(string, pattern[, group]), returns nil if not matched.
+func regexpExtractCallFunc(args ...interface{}) (interface{}, error) {
+	if args[0] == nil {
+		return nil, nil
+	}
+	s, pattern, ok := isBothStrings(args[0], args[1])
+	if !ok {
+		return nil, fmt.Errorf("regexp_extract(v1[%v]::%T,v2[%v]::%T) can not eval", args[0], args[0], args[1], args[1])
+	}
+	group := 0.0
+	if len(args) == 3 {
+		group, ok = isInteger(args[2])
+		if !ok {
+			return nil, fmt.Errorf("regexp_extract(v1[%v]::%T,v2[%v]::%T,v3[%v]::%T) can not eval", args[0], args[0], args[1], args[1], args[2], args[2])
+		}
+	}
+	reg, err := compileRegexp(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("regexp_extract(v1[%v]::%T,v2[%v]::%T) pattern can not compile: %w", args[0], args[0], args[1], args[1], err)
+	}
+	if group < 0 || group > float64(reg.NumSubexp()) {
+		return nil, fmt.Errorf("regexp_extract(v1[%v]::%T,v2[%v]::%T) group %v is out of range", args[0], args[0], args[1], args[1], group)
+	}
+	match := reg.FindStringSubmatch(s)
+	if match == nil {
+		return nil, nil
+	}
+	return match[int(group)], nil
+}
+
+func regexpReplaceCallFunc(args ...interface{}) (interface{}, error) {
+	if args[0] == nil {
+		return nil, nil
+	}
+	s, ok1 := isString(args[0])
+	pattern, repl, ok2 := isBothStrings(args[1], args[2])
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("regexp_replace(v1[%v]::%T,v2[%v]::%T,v3[%v]::%T) can not eval", args[0], args[0], args[1], args[1], args[2], args[2])
+	}
+	reg, err := compileRegexp(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("regexp_replace(v1[%v]::%T,v2[%v]::%T,v3[%v]::%T) pattern can not compile: %w", args[0], args[0], args[1], args[1], args[2], args[2], err)
+	}
+	return reg.ReplaceAllString(s, repl), nil
+}
+
+// regexpReplaceResultSize estimates the size of the result of regexp_replace by the matches,
+// each match is replaced by the replacement whose references like $1 are expanded to the match at most.
+func regexpReplaceResultSize(args ...interface{}) int {
+	s, ok1 := isString(args[0])
+	pattern, repl, ok2 := isBothStrings(args[1], args[2])
+	if !ok1 || !ok2 {
+		return 0
+	}
+	reg, err := compileRegexp(pattern)
+	if err != nil {
+		return 0
+	}
+	size := len(s)
+	refs := strings.Count(repl, "$")
+	for _, loc := range reg.FindAllStringIndex(s, -1) {
+		size += len(repl) + (refs-1)*(loc[1]-loc[0])
+	}
+	if size < 0 {
+		return 0
+	}
+	return size
+}
+
+var normalizationForms = map[string]norm.Form{
+	"NFC":  norm.NFC,
+	"NFD":  norm.NFD,
+	"NFKC": norm.NFKC,
+	"NFKD": norm.NFKD,
+}
+
+// normalizeCallFunc is normalize(string[, form]), the form is one of NFC (default), NFD, NFKC and NFKD.
+// Comparing normalized strings makes the equality insensitive to the Unicode normalization.
+func normalizeCallFunc(args ...interface{}) (interface{}, error) {
+	if args[0] == nil {
+		return nil, nil
+	}
+	s, ok := isString(args[0])
+	if !ok {
+		return nil, fmt.Errorf("normalize(v[%v]::%T) can not eval", args[0], args[0])
+	}
+	form := norm.NFC
+	if len(args) == 2 {
+		name, ok := isString(args[1])
+		if !ok {
+			return nil, fmt.Errorf("normalize(v1[%v]::%T,v2[%v]::%T) can not eval", args[0], args[0], args[1], args[1])
+		}
+		form, ok = normalizationForms[strings.ToUpper(name)]
+		if !ok {
+			return nil, fmt.Errorf("normalize(v1[%v]::%T,v2[%v]::%T) unknown normalization form", args[0], args[0], args[1], args[1])
+		}
+	}
+	return form.String(s), nil
+}
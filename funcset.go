@@ -0,0 +1,167 @@
+package evaluator
+
+import (
+	"fmt"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// Func is a custom function of FuncSet, which is called with the evaluated arguments.
+type Func func(args ...interface{}) (interface{}, error)
+
+type customFunc struct {
+	f         Func
+	numOfArgs int
+}
+
+// FuncSet is a set of the functions which the expressions can call, it is given to New by WithFuncSet.
+// The set consists of the allowed built-in functions and the custom functions.
+// The FuncSet must not be changed while New is called with it.
+type FuncSet struct {
+	builtins map[string]bool
+	custom   map[string]customFunc
+}
+
+// NewFuncSet creates a FuncSet of the built-in functions of the names, use BuiltinFuncSet for all of them.
+func NewFuncSet(names ...string) (*FuncSet, error) {
+	s := &FuncSet{
+		builtins: make(map[string]bool),
+		custom:   make(map[string]customFunc),
+	}
+	if err := s.Allow(names...); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// BuiltinFuncSet creates a FuncSet of all built-in functions.
+func BuiltinFuncSet() *FuncSet {
+	s, err := NewFuncSet(builtinFuncNames()...)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func builtinFuncNames() []string {
+	names := make([]string, 0, len(builtinFuncs))
+	for i := range builtinFuncs {
+		names = append(names, builtinFuncs[i].name)
+	}
+	return names
+}
+
+func isBuiltinFunc(name string) bool {
+	_, ok := getBuiltinFunc(name)
+	return ok
+}
+
+// Allow adds the built-in functions of the names.
+func (s *FuncSet) Allow(names ...string) error {
+	for _, name := range names {
+		if !isBuiltinFunc(name) {
+			return fmt.Errorf("Allow(`%s`) %s() func is not found", name, name)
+		}
+	}
+	for _, name := range names {
+		s.builtins[name] = true
+	}
+	return nil
+}
+
+// Deny removes the built-in functions or the custom functions of the names.
+func (s *FuncSet) Deny(names ...string) {
+	for _, name := range names {
+		delete(s.builtins, name)
+		delete(s.custom, name)
+	}
+}
+
+// Define adds the custom function, numOfArgs is the number of the arguments, or -1 for any number of them.
+// The name must be an identifier which is not a name of the built-in functions.
+func (s *FuncSet) Define(name string, numOfArgs int, f Func) error {
+	if !token.IsIdentifier(name) || strings.HasPrefix(name, "__") || name == "nil" {
+		return fmt.Errorf("Define(`%s`) is not valid function name", name)
+	}
+	if isBuiltinFunc(name) {
+		return fmt.Errorf("Define(`%s`) is built-in function", name)
+	}
+	s.custom[name] = customFunc{
+		f:         f,
+		numOfArgs: numOfArgs,
+	}
+	return nil
+}
+
+// Has returns whether the function of the name can be called.
+func (s *FuncSet) Has(name string) bool {
+	if s.builtins[name] {
+		return true
+	}
+	_, ok := s.custom[name]
+	return ok
+}
+
+// Names returns the sorted names of the functions.
+func (s *FuncSet) Names() []string {
+	names := make([]string, 0, len(s.builtins)+len(s.custom))
+	for name := range s.builtins {
+		names = append(names, name)
+	}
+	for name := range s.custom {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WithFuncSet limits the functions which the expression can call to the FuncSet.
+// The call of the function not in the FuncSet is FunctionNotAllowedError.
+func WithFuncSet(s *FuncSet) Option {
+	return func(cfg *config) {
+		cfg.funcs = s
+	}
+}
+
+// checkFunc returns the custom function of the name if it is, and an error if the function is not allowed.
+// All built-in functions are allowed if FuncSet is not given.
+func (cfg *config) checkFunc(funcName string) (*customFunc, error) {
+	if cfg.funcs == nil {
+		return nil, nil
+	}
+	name := strings.TrimPrefix(funcName, "__")
+	if f, ok := cfg.funcs.custom[name]; ok && name == funcName {
+		return &f, nil
+	}
+	if !cfg.funcs.builtins[name] {
+		return nil, &FunctionNotAllowedError{FunctionName: name}
+	}
+	return nil, nil
+}
+
+// UsedFunctions returns the sorted names of the functions called by the expression.
+func UsedFunctions(e Evaluator) []string {
+	names := make(map[string]struct{})
+	var visit func(Evaluator)
+	visit = func(e Evaluator) {
+		switch e := e.(type) {
+		case *callEvaluator:
+			names[e.funcName] = struct{}{}
+		case *higherOrderEvaluator:
+			names[e.funcName] = struct{}{}
+		case *statefulCallEvaluator:
+			names[e.funcName] = struct{}{}
+		}
+		for _, child := range childrenOf(e) {
+			visit(child)
+		}
+	}
+	visit(e)
+	ret := make([]string, 0, len(names))
+	for name := range names {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
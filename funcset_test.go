@@ -0,0 +1,150 @@
+package evaluator_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mashiike/evaluator"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFuncSetDeny(t *testing.T) {
+	s := evaluator.BuiltinFuncSet()
+	require.Len(t, s.Names(), len(evaluator.Functions()))
+	s.Deny("regexp_match", "if", "any", "delta")
+	require.False(t, s.Has("regexp_match"))
+	require.True(t, s.Has("regexp_replace"))
+
+	cases := map[string]string{
+		"regexp_match(var1, var2)":               "regexp_match() func is not allowed",
+		"var1 && regexp_match(var1, `a`)":        "parse BinaryExpr.Y `regexp_match(var1, `a`)` regexp_match() func is not allowed",
+		"if(var1, 1, 2)":                         "if() func is not allowed",
+		"any(values, v -> v > 1)":                "any() func is not allowed",
+		"coalesce(delta(var1), 0)":               "parse CallExpr.Args[0] `delta(var1)` delta() func is not allowed",
+		"unknown(var1)":                          "unknown() func is not allowed",
+		"all(values, v -> regexp_match(v, `a`))": "parse CallExpr.Args[1] `__lambda(v, regexp_match(v, `a`))` parse lambda body `regexp_match(v, `a`)` regexp_match() func is not allowed",
+	}
+	for expr, expected := range cases {
+		t.Run(expr, func(t *testing.T) {
+			_, err := evaluator.New(expr, evaluator.WithFuncSet(s))
+			require.Error(t, err)
+			var notAllowed *evaluator.FunctionNotAllowedError
+			require.True(t, errors.As(err, &notAllowed))
+			require.EqualError(t, err, expected)
+		})
+	}
+
+	e, err := evaluator.New("regexp_replace(var1, `a`, `b`)", evaluator.WithFuncSet(s))
+	require.NoError(t, err)
+	ret, err := e.Eval(evaluator.Variables{"var1": "abc"})
+	require.NoError(t, err)
+	require.Equal(t, "bbc", ret)
+}
+
+func TestFuncSetDefine(t *testing.T) {
+	s, err := evaluator.NewFuncSet("abs", "coalesce")
+	require.NoError(t, err)
+	require.NoError(t, s.Define("double", 1, func(args ...interface{}) (interface{}, error) {
+		n, ok := args[0].(float64)
+		if !ok {
+			return nil, errors.New("double() is expected number")
+		}
+		return n * 2, nil
+	}))
+	require.NoError(t, s.Define("concat", -1, func(args ...interface{}) (interface{}, error) {
+		var builder strings.Builder
+		for _, arg := range args {
+			s, _ := arg.(string)
+			builder.WriteString(s)
+		}
+		return builder.String(), nil
+	}))
+	require.Equal(t, []string{"abs", "coalesce", "concat", "double"}, s.Names())
+
+	e, err := evaluator.New("double(abs(var1)) + double(coalesce(var2, 1))", evaluator.WithFuncSet(s))
+	require.NoError(t, err)
+	require.Equal(t, "double(abs(var1)) + double(coalesce(var2, 1))", e.String())
+	ret, err := e.Eval(evaluator.Variables{"var1": -1.5})
+	require.NoError(t, err)
+	require.Equal(t, 5.0, ret)
+	_, err = e.Eval(evaluator.Variables{"var1": "a"})
+	require.Error(t, err)
+
+	e, err = evaluator.New("concat(var1, `-`, var2)", evaluator.WithFuncSet(s))
+	require.NoError(t, err)
+	ret, err = e.Eval(evaluator.Variables{"var1": "a", "var2": "b"})
+	require.NoError(t, err)
+	require.Equal(t, "a-b", ret)
+
+	_, err = evaluator.New("double(1, 2)", evaluator.WithFuncSet(s))
+	require.EqualError(t, err, "double() func is expected 1 arg, but given 2 args")
+	_, err = evaluator.New("double(1)")
+	require.EqualError(t, err, "double() func is not found")
+	_, err = evaluator.New("rate(1, 2)", evaluator.WithFuncSet(s))
+	require.EqualError(t, err, "rate() func is not allowed")
+
+	s.Deny("double")
+	_, err = evaluator.New("double(1)", evaluator.WithFuncSet(s))
+	require.EqualError(t, err, "double() func is not allowed")
+}
+
+func TestFuncSetInvalid(t *testing.T) {
+	_, err := evaluator.NewFuncSet("abs", "unknown")
+	require.EqualError(t, err, "Allow(`unknown`) unknown() func is not found")
+
+	s, err := evaluator.NewFuncSet()
+	require.NoError(t, err)
+	f := func(args ...interface{}) (interface{}, error) { return nil, nil }
+	require.EqualError(t, s.Define("abs", 1, f), "Define(`abs`) is built-in function")
+	require.EqualError(t, s.Define("if", 1, f), "Define(`if`) is not valid function name")
+	require.EqualError(t, s.Define("__lambda", 1, f), "Define(`__lambda`) is not valid function name")
+	require.EqualError(t, s.Define("a-b", 1, f), "Define(`a-b`) is not valid function name")
+	require.Empty(t, s.Names())
+}
+
+func TestUsedFunctions(t *testing.T) {
+	cases := map[string][]string{
+		"var1 + 1": {},
+		"if(any(values, v -> abs(v) > 1), coalesce(var1, var2), coalesce(delta(var3), 0))": {"abs", "any", "coalesce", "delta", "if"},
+		"map(values, v -> regexp_match(v, `a`))":                                           {"map", "regexp_match"},
+	}
+	for expr, expected := range cases {
+		t.Run(expr, func(t *testing.T) {
+			e, err := evaluator.New(expr)
+			require.NoError(t, err)
+			require.Equal(t, expected, evaluator.UsedFunctions(e))
+		})
+	}
+}
+
+func TestFuncSetWithConstructors(t *testing.T) {
+	s := evaluator.BuiltinFuncSet()
+	s.Deny("regexp_match")
+	opts := []evaluator.Option{evaluator.WithFuncSet(s), evaluator.WithMaxNodes(10)}
+
+	_, err := evaluator.NewRuleSet([]evaluator.Rule{{Name: "a", Expr: "regexp_match(var1, `a`)"}}, opts...)
+	require.EqualError(t, err, "rule `a`: regexp_match() func is not allowed")
+	rs, err := evaluator.NewRuleSet([]evaluator.Rule{{Name: "a", Expr: "delta(var1) > 1", Enabled: true}}, opts...)
+	require.NoError(t, err)
+	require.Len(t, rs.Rules(), 1)
+
+	env := evaluator.NewEnvironment(opts...)
+	require.EqualError(t, env.Define("a", "regexp_match(var1, `a`)"), "Define(`a`) regexp_match() func is not allowed")
+	require.EqualError(t, env.Define("b", "var1 + var2 + var3 + var4 + var5 + var6"), "Define(`b`) nodes 11 exceeds the limit 10")
+
+	_, err = evaluator.ParseAlertCondition("cpu > 90", "regexp_match(state, `ok`)", evaluator.WithExprOptions(opts...))
+	require.EqualError(t, err, "regexp_match() func is not allowed")
+	_, err = evaluator.ParseAlertCondition("regexp_match(state, `ng`)", "", evaluator.WithExprOptions(opts...))
+	require.EqualError(t, err, "regexp_match() func is not allowed")
+
+	_, err = evaluator.NewStateful("regexp_match(var1, `a`)", opts...)
+	require.EqualError(t, err, "regexp_match() func is not allowed")
+	e, err := evaluator.NewStateful("coalesce(delta(var1), 0)", opts...)
+	require.NoError(t, err)
+	_, err = e.Eval(evaluator.Variables{"var1": 1})
+	require.NoError(t, err)
+	actual, err := e.Eval(evaluator.Variables{"var1": 3})
+	require.NoError(t, err)
+	require.EqualValues(t, 2, actual)
+}
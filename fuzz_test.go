@@ -0,0 +1,180 @@
+//go:build go1.18
+// +build go1.18
+
+package evaluator_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mashiike/evaluator"
+)
+
+// fuzzSeeds returns the cases of the conformance corpus as the seed corpus.
+func fuzzSeeds(f *testing.F) []evaluator.ConformanceCase {
+	var seeds []evaluator.ConformanceCase
+	files, err := filepath.Glob("testdata/conformance/*.yaml")
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, file := range files {
+		r, err := os.Open(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		cases, err := evaluator.LoadConformanceCases(r)
+		r.Close()
+		if err != nil {
+			f.Fatal(err)
+		}
+		seeds = append(seeds, cases...)
+	}
+	return seeds
+}
+
+func requireNoPanic(t *testing.T, err error) {
+	t.Helper()
+	var panicErr *evaluator.PanicError
+	if errors.As(err, &panicErr) {
+		t.Fatalf("%s\n%s", err, panicErr.Stack)
+	}
+}
+
+func FuzzNew(f *testing.F) {
+	for _, c := range fuzzSeeds(f) {
+		f.Add(c.Expr)
+	}
+	f.Fuzz(func(t *testing.T, expr string) {
+		e, err := evaluator.New(expr)
+		requireNoPanic(t, err)
+		if err != nil {
+			return
+		}
+		str := e.String()
+		reparsed, err := evaluator.New(str)
+		if err != nil {
+			t.Fatalf("New(`%s`).String() is `%s`, which can not be parsed: %s", expr, str, err)
+		}
+		if reparsed.String() != str {
+			t.Fatalf("New(`%s`).String() is `%s`, but reparsed is `%s`", expr, str, reparsed)
+		}
+	})
+}
+
+func FuzzEval(f *testing.F) {
+	for _, c := range fuzzSeeds(f) {
+		vars, err := json.Marshal(c.Vars)
+		if err != nil {
+			// the nested objects decoded from YAML can have the keys which are not string
+			vars = []byte("{}")
+		}
+		f.Add(c.Expr, string(vars))
+	}
+	// the expressions which have panicked in the evaluation
+	for _, expr := range []string{
+		"substr(`abc`, sqrt(-1))",
+		"substr(`abc`, 0, sqrt(-1))",
+		"regexp_extract(`abc`, `(b)`, sqrt(-1))",
+		"regexp_extract(`abc`, `(b)`, 1e19)",
+	} {
+		f.Add(expr, "{}")
+	}
+	f.Fuzz(func(t *testing.T, expr string, varsJSON string) {
+		e, err := evaluator.New(expr)
+		requireNoPanic(t, err)
+		if err != nil {
+			return
+		}
+		var vars evaluator.Variables
+		if err := json.Unmarshal([]byte(varsJSON), &vars); err != nil {
+			vars = evaluator.Variables{}
+		}
+		expected, expectedErr := evaluator.Recover(e).Eval(vars)
+		requireNoPanic(t, expectedErr)
+
+		reparsed, err := evaluator.New(e.String())
+		if err != nil {
+			t.Fatalf("New(`%s`).String() is `%s`, which can not be parsed: %s", expr, e, err)
+		}
+		actual, err := evaluator.Recover(reparsed).Eval(vars)
+		requireNoPanic(t, err)
+		requireSameResult(t, "reparsed `"+e.String()+"`", expected, expectedErr, actual, err)
+
+		unfoldedExpr, literals := unfoldNumbers(expr)
+		unfolded, err := evaluator.New(unfoldedExpr)
+		requireNoPanic(t, err)
+		if err != nil {
+			// the literals are required by some functions, like the alpha of ewma
+			return
+		}
+		for k, v := range vars {
+			literals[k] = v
+		}
+		actual, err = evaluator.Recover(unfolded).Eval(literals)
+		requireNoPanic(t, err)
+		requireSameResult(t, "unfolded `"+unfoldedExpr+"`", expected, expectedErr, actual, err)
+	})
+}
+
+// unfoldNumbers replaces the number literals with the variables, so that the constant folding is not applied.
+func unfoldNumbers(expr string) (string, evaluator.Variables) {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(expr))
+	var s scanner.Scanner
+	s.Init(file, []byte(expr), func(token.Position, string) {}, 0)
+	literals := make(evaluator.Variables)
+	var builder strings.Builder
+	last := 0
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok != token.INT && tok != token.FLOAT {
+			continue
+		}
+		e, err := evaluator.New(lit)
+		if err != nil {
+			continue
+		}
+		v, err := e.Eval(nil)
+		if err != nil {
+			continue
+		}
+		name := fmt.Sprintf("fuzz_literal%d", len(literals))
+		offset := file.Offset(pos)
+		builder.WriteString(expr[last:offset])
+		builder.WriteString(name)
+		last = offset + len(lit)
+		literals[name] = v
+	}
+	builder.WriteString(expr[last:])
+	return builder.String(), literals
+}
+
+func requireSameResult(t *testing.T, name string, expected interface{}, expectedErr error, actual interface{}, err error) {
+	t.Helper()
+	if (expectedErr == nil) != (err == nil) {
+		t.Fatalf("%s: the errors are different, expected %v, but got %v", name, expectedErr, err)
+	}
+	if expectedErr != nil {
+		return
+	}
+	if f1, ok := expected.(float64); ok && math.IsNaN(f1) {
+		if f2, ok := actual.(float64); ok && math.IsNaN(f2) {
+			return
+		}
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("%s: the results are different, expected %#v, but got %#v", name, expected, actual)
+	}
+}
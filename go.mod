@@ -2,10 +2,13 @@ module github.com/mashiike/evaluator
 
 go 1.17
 
-require github.com/stretchr/testify v1.7.2
+require (
+	github.com/stretchr/testify v1.7.2
+	golang.org/x/text v0.3.8
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
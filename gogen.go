@@ -0,0 +1,413 @@
+package evaluator
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// GenerateGo generates the Go source of the function equivalent to the expression, formatted by go/format.
+// The variables are the float64 parameters in the sorted order, and the result is bool or float64, like:
+//
+//	func IsHot(var1, var2 float64) bool
+//
+// If the evaluation can fail, for example by the division by zero or the nil of rate(), the function also returns an error.
+// The generated code does not depend on this package. The string values, the lists and the lambdas are ErrNoGoEquivalent.
+func GenerateGo(e Evaluator, pkg string, funcName string) ([]byte, error) {
+	e = unwrap(e)
+	if !token.IsIdentifier(pkg) {
+		return nil, fmt.Errorf("GenerateGo(`%s`) invalid package name `%s`", e, pkg)
+	}
+	if !token.IsIdentifier(funcName) {
+		return nil, fmt.Errorf("GenerateGo(`%s`) invalid function name `%s`", e, funcName)
+	}
+	if err := Check(e); err != nil {
+		return nil, fmt.Errorf("GenerateGo(`%s`) %w", e, err)
+	}
+	params := ReferencedVariables(e)
+	for _, param := range params {
+		if token.IsKeyword(param) || goReservedNames[param] || goTempName.MatchString(param) {
+			return nil, fmt.Errorf("GenerateGo(`%s`) variable `%s` conflicts with the generated code", e, param)
+		}
+	}
+	g := &goGen{
+		funcName: funcName,
+		zero:     "0",
+		imports:  make(map[string]bool),
+	}
+	if kind, _ := inferKind(e); kind == kindBool {
+		g.zero = "false"
+	}
+	ret, err := g.gen(e)
+	if err != nil {
+		return nil, err
+	}
+	if ret.nullable {
+		return nil, fmt.Errorf("GenerateGo(`%s`) the result can be nil, %w", e, ErrNoGoEquivalent)
+	}
+	resultType := "float64"
+	if ret.kind == kindBool {
+		resultType = "bool"
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by evaluator gen. DO NOT EDIT.\n\npackage %s\n\n", pkg)
+	if len(g.imports) > 0 {
+		buf.WriteString("import (\n")
+		for _, path := range []string{"errors", "math"} {
+			if g.imports[path] {
+				fmt.Fprintf(&buf, "%q\n", path)
+			}
+		}
+		buf.WriteString(")\n\n")
+	}
+	fmt.Fprintf(&buf, "// %s evaluates `%s`.\n", funcName, e)
+	fmt.Fprintf(&buf, "func %s(", funcName)
+	if len(params) > 0 {
+		fmt.Fprintf(&buf, "%s float64", strings.Join(params, ", "))
+	}
+	if g.fallible {
+		fmt.Fprintf(&buf, ") (%s, error) {\n%sreturn %s, nil\n}\n", resultType, g.body.String(), ret.expr)
+	} else {
+		fmt.Fprintf(&buf, ") %s {\n%sreturn %s\n}\n", resultType, g.body.String(), ret.expr)
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("GenerateGo(`%s`) %w", e, err)
+	}
+	return src, nil
+}
+
+// goReservedNames are the names used by the generated code, the variables of the names are not allowed.
+var goReservedNames = map[string]bool{
+	"errors": true, "math": true, "float64": true, "bool": true, "error": true, "true": true, "false": true,
+}
+
+// goTempName is the pattern of the temporary variables of the generated code.
+var goTempName = regexp.MustCompile(`^t[0-9]+[pv]?$`)
+
+type goGen struct {
+	funcName string
+	zero     string
+	body     strings.Builder
+	temps    int
+	imports  map[string]bool
+	fallible bool
+}
+
+// goValue is the generated Go expression of a node. If nullable, expr is the name of *float64 which can be nil.
+type goValue struct {
+	expr     string
+	kind     valueKind
+	nullable bool
+	atomic   bool
+}
+
+func (g *goGen) unsupported(e Evaluator) error {
+	return fmt.Errorf("GenerateGo(`%s`) %w", e, ErrNoGoEquivalent)
+}
+
+func (g *goGen) temp() string {
+	name := fmt.Sprintf("t%d", g.temps)
+	g.temps++
+	return name
+}
+
+func (g *goGen) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.body, format, args...)
+}
+
+// fail writes the statement to return the error if the condition is true.
+func (g *goGen) fail(cond string, msg string) {
+	g.fallible = true
+	g.imports["errors"] = true
+	g.printf("if %s {\nreturn %s, errors.New(%s)\n}\n", cond, g.zero, strconv.Quote(g.funcName+": "+msg))
+}
+
+// need returns the value which is not nil, the generated function fails if it is nil like the evaluation.
+func (g *goGen) need(e Evaluator, v goValue) goValue {
+	if !v.nullable {
+		return v
+	}
+	g.fail(v.expr+" == nil", fmt.Sprintf("`%s` is nil", e))
+	return goValue{expr: "*" + v.expr, kind: v.kind, atomic: true}
+}
+
+func (g *goGen) genNeed(e Evaluator, kind valueKind) (goValue, error) {
+	v, err := g.gen(e)
+	if err != nil {
+		return goValue{}, err
+	}
+	if v.kind != kind {
+		return goValue{}, g.unsupported(e)
+	}
+	return g.need(e, v), nil
+}
+
+func wrapGoExpr(v goValue) string {
+	if v.atomic {
+		return v.expr
+	}
+	return "(" + v.expr + ")"
+}
+
+func goFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "math.Inf(1)"
+	case math.IsInf(f, -1):
+		return "math.Inf(-1)"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+func (g *goGen) gen(e Evaluator) (goValue, error) {
+	switch e := e.(type) {
+	case *realNumericLiteralEvaluator:
+		s := goFloat(e.value)
+		if strings.HasPrefix(s, "math.") {
+			g.imports["math"] = true
+		}
+		return goValue{expr: s, kind: kindNumber, atomic: e.value >= 0}, nil
+	case *lockupVariableEvaluator:
+		return goValue{expr: e.name, kind: kindNumber, atomic: true}, nil
+	case *parenEvaluator:
+		v, err := g.gen(e.x)
+		if err != nil || v.nullable || v.atomic {
+			return v, err
+		}
+		return goValue{expr: "(" + v.expr + ")", kind: v.kind, atomic: true}, nil
+	case *unaryEvaluator:
+		kind := kindNumber
+		if e.op == "!" {
+			kind = kindBool
+		}
+		x, err := g.genNeed(e.x, kind)
+		if err != nil {
+			return goValue{}, err
+		}
+		if e.op == "+" {
+			return x, nil
+		}
+		return goValue{expr: e.op + wrapGoExpr(x), kind: kind}, nil
+	case *computableEvaluator:
+		x, err := g.genNeed(e.x, kindNumber)
+		if err != nil {
+			return goValue{}, err
+		}
+		y, err := g.genNeed(e.y, kindNumber)
+		if err != nil {
+			return goValue{}, err
+		}
+		if lit, ok := e.y.(*realNumericLiteralEvaluator); e.op == "/" && !(ok && lit.value != 0) {
+			if !y.atomic {
+				t := g.temp()
+				g.printf("%s := %s\n", t, y.expr)
+				y = goValue{expr: t, kind: kindNumber, atomic: true}
+			}
+			g.fail(y.expr+" == 0", "divide by 0")
+		}
+		return goValue{expr: x.expr + " " + e.op + " " + y.expr, kind: kindNumber}, nil
+	case *comparativeEvaluator:
+		x, err := g.gen(e.x)
+		if err != nil {
+			return goValue{}, err
+		}
+		x = g.need(e.x, x)
+		y, err := g.gen(e.y)
+		if err != nil {
+			return goValue{}, err
+		}
+		y = g.need(e.y, y)
+		op := e.op
+		if op == "=" {
+			op = "=="
+		}
+		if x.kind != y.kind || (x.kind == kindBool && op != "==" && op != "!=") {
+			return goValue{}, g.unsupported(e)
+		}
+		return goValue{expr: x.expr + " " + op + " " + y.expr, kind: kindBool}, nil
+	case *logicalEvaluator:
+		x, err := g.genNeed(e.x, kindBool)
+		if err != nil {
+			return goValue{}, err
+		}
+		y, err := g.genNeed(e.y, kindBool)
+		if err != nil {
+			return goValue{}, err
+		}
+		return goValue{expr: x.expr + " " + e.op + " " + y.expr, kind: kindBool}, nil
+	case *callEvaluator:
+		return g.genCall(e)
+	default:
+		return goValue{}, g.unsupported(e)
+	}
+}
+
+func (g *goGen) genCall(e *callEvaluator) (goValue, error) {
+	args := make([]goValue, 0, len(e.args))
+	for _, arg := range e.args {
+		v, err := g.gen(arg)
+		if err != nil {
+			return goValue{}, err
+		}
+		args = append(args, v)
+	}
+	switch e.funcName {
+	case "if":
+		if args[0].kind != kindBool || args[1].kind != args[2].kind {
+			return goValue{}, g.unsupported(e)
+		}
+		cond := g.need(e.args[0], args[0])
+		if !args[1].nullable && !args[2].nullable {
+			t := g.temp()
+			g.printf("%s := %s\nif %s {\n%s = %s\n}\n", t, args[2].expr, cond.expr, t, args[1].expr)
+			return goValue{expr: t, kind: args[1].kind, atomic: true}, nil
+		}
+		then, otherwise := g.pointer(args[1]), g.pointer(args[2])
+		t := g.temp()
+		g.printf("var %s *float64\nif %s {\n%s = %s\n} else {\n%s = %s\n}\n", t, cond.expr, t, then, t, otherwise)
+		return goValue{expr: t, kind: kindNumber, nullable: true, atomic: true}, nil
+	case "coalesce":
+		if len(args) == 0 {
+			return goValue{}, g.unsupported(e)
+		}
+		for _, arg := range args {
+			if arg.kind != args[0].kind {
+				return goValue{}, g.unsupported(e)
+			}
+		}
+		last := len(args) - 1
+		for i, arg := range args {
+			if !arg.nullable {
+				last = i
+				break
+			}
+		}
+		if last == 0 {
+			return args[0], nil
+		}
+		t := g.temp()
+		result := goValue{expr: t, kind: args[0].kind, atomic: true}
+		if args[last].nullable {
+			result.nullable = true
+			g.printf("var %s *float64\n", t)
+		} else {
+			g.printf("%s := %s\n", t, args[last].expr)
+		}
+		for i, arg := range args[:last+1] {
+			if !arg.nullable {
+				break
+			}
+			value := "*" + arg.expr
+			if result.nullable {
+				value = arg.expr
+			}
+			if i > 0 {
+				g.printf(" else ")
+			}
+			g.printf("if %s != nil {\n%s = %s\n}", arg.expr, t, value)
+		}
+		g.printf("\n")
+		return result, nil
+	case "as_numeric":
+		if args[0].kind != kindNumber {
+			return goValue{}, g.unsupported(e)
+		}
+		return args[0], nil
+	case "rate":
+		x := g.need(e.args[0], args[0])
+		y := g.need(e.args[1], args[1])
+		if x.kind != kindNumber || y.kind != kindNumber {
+			return goValue{}, g.unsupported(e)
+		}
+		t := g.temp()
+		g.printf("var %s *float64\nif %s != 0 {\n%sv := %s / %s\n%s = &%sv\n}\n", t, y.expr, t, wrapGoExpr(x), wrapGoExpr(y), t, t)
+		return goValue{expr: t, kind: kindNumber, nullable: true, atomic: true}, nil
+	case "is_nan", "is_inf":
+		if args[0].kind != kindNumber {
+			return goValue{}, g.unsupported(e)
+		}
+		g.imports["math"] = true
+		call := "math.IsNaN(%s)"
+		if e.funcName == "is_inf" {
+			call = "math.IsInf(%s, 0)"
+		}
+		if args[0].nullable {
+			return goValue{expr: "(" + args[0].expr + " != nil && " + fmt.Sprintf(call, "*"+args[0].expr) + ")", kind: kindBool, atomic: true}, nil
+		}
+		return goValue{expr: fmt.Sprintf(call, args[0].expr), kind: kindBool, atomic: true}, nil
+	}
+	f, ok := getBuiltinFunc(e.funcName)
+	if !ok || !f.numeric {
+		return goValue{}, g.unsupported(e)
+	}
+	for _, arg := range args {
+		if arg.kind != kindNumber {
+			return goValue{}, g.unsupported(e)
+		}
+	}
+	return g.genMath(e, args), nil
+}
+
+// pointer returns the *float64 expression of the value.
+func (g *goGen) pointer(v goValue) string {
+	if v.nullable {
+		return v.expr
+	}
+	t := g.temp()
+	g.printf("%s := %s\n", t, v.expr)
+	return "&" + t
+}
+
+// genMath generates the math function, the result is nil if any argument is nil like the evaluation.
+func (g *goGen) genMath(e *callEvaluator, args []goValue) goValue {
+	g.imports["math"] = true
+	t := g.temp()
+	nullable := make([]string, 0, len(args))
+	values := make([]string, 0, len(args))
+	for _, arg := range args {
+		if arg.nullable {
+			nullable = append(nullable, arg.expr+" != nil")
+			values = append(values, "*"+arg.expr)
+		} else {
+			values = append(values, arg.expr)
+		}
+	}
+	result := t
+	if len(nullable) > 0 {
+		result = t + "v"
+		g.printf("var %s *float64\nif %s {\n", t, strings.Join(nullable, " && "))
+	}
+	switch e.funcName {
+	case "sign":
+		g.printf("%s := %s\nif %s > 0 {\n%s = 1\n} else if %s < 0 {\n%s = -1\n}\n", result, values[0], result, result, result, result)
+	case "round_to":
+		g.printf("%sp := math.Pow(10, math.Trunc(%s))\n%s := %s\nif %sp != 0 && !math.IsInf(%s*%sp, 0) {\n%s = math.Round(%s*%sp) / %sp\n}\n",
+			t, values[1], result, values[0], t, result, t, result, result, t, t)
+	case "clamp":
+		lower, ok1 := e.args[1].(*realNumericLiteralEvaluator)
+		upper, ok2 := e.args[2].(*realNumericLiteralEvaluator)
+		if !ok1 || !ok2 || lower.value > upper.value {
+			g.fail(values[1]+" > "+values[2], fmt.Sprintf("`%s` lower bound is greater than upper bound", e))
+		}
+		g.printf("%s := math.Min(math.Max(%s, %s), %s)\n", result, values[0], values[1], values[2])
+	default:
+		f, _ := getBuiltinFunc(e.funcName)
+		g.printf("%s := %s(%s)\n", result, f.goMath, strings.Join(values, ", "))
+	}
+	if len(nullable) > 0 {
+		g.printf("%s = &%s\n}\n", t, result)
+		return goValue{expr: t, kind: kindNumber, nullable: true, atomic: true}
+	}
+	return goValue{expr: t, kind: kindNumber, atomic: true}
+}
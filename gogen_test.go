@@ -0,0 +1,76 @@
+package evaluator_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mashiike/evaluator"
+	"github.com/mashiike/evaluator/internal/gentest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateGo(t *testing.T) {
+	for _, rule := range gentest.Rules {
+		t.Run(rule.Func, func(t *testing.T) {
+			e, err := evaluator.New(rule.Expr)
+			require.NoError(t, err)
+			actual, err := evaluator.GenerateGo(e, "gentest", rule.Func)
+			require.NoError(t, err)
+			expected, err := os.ReadFile(filepath.Join("internal", "gentest", rule.File))
+			require.NoError(t, err)
+			require.Equal(t, string(expected), string(actual), "run `go generate ./internal/gentest`")
+		})
+	}
+}
+
+func TestGenerateGoFail(t *testing.T) {
+	cases := []struct {
+		expr     string
+		expected string
+	}{
+		{expr: "name == `foo`", expected: "GenerateGo(`\"foo\"`) has no Go equivalent"},
+		{expr: "sum(values) > 1", expected: "GenerateGo(`sum(values)`) has no Go equivalent"},
+		{expr: "flag && x > 1", expected: "GenerateGo(`flag`) has no Go equivalent"},
+		{expr: "rate(a, b)", expected: "GenerateGo(`rate(a, b)`) the result can be nil, has no Go equivalent"},
+		{expr: "math > 1", expected: "GenerateGo(`math > 1`) variable `math` conflicts with the generated code"},
+		{expr: "`a` < 1", expected: "GenerateGo(`\"a\" < 1`) Check(`\"a\" < 1`) string and number can not `<` comparatable"},
+	}
+	for _, c := range cases {
+		t.Run(c.expr, func(t *testing.T) {
+			e, err := evaluator.New(c.expr)
+			require.NoError(t, err)
+			_, err = evaluator.GenerateGo(e, "rules", "F")
+			require.EqualError(t, err, c.expected)
+		})
+	}
+	e, err := evaluator.New("x > 1")
+	require.NoError(t, err)
+	_, err = evaluator.GenerateGo(e, "rules", "is-hot")
+	require.EqualError(t, err, "GenerateGo(`x > 1`) invalid function name `is-hot`")
+	_, err = evaluator.GenerateGo(e, "rules", "F")
+	require.False(t, errors.Is(err, evaluator.ErrNoGoEquivalent))
+}
+
+func TestGenerateGoWithCostBudget(t *testing.T) {
+	rule := gentest.Rules[0]
+	e, err := evaluator.New(rule.Expr, evaluator.WithCostBudget(100))
+	require.NoError(t, err)
+	actual, err := evaluator.GenerateGo(e, "gentest", rule.Func)
+	require.NoError(t, err)
+	expected, err := os.ReadFile(filepath.Join("internal", "gentest", rule.File))
+	require.NoError(t, err)
+	require.Equal(t, string(expected), string(actual))
+}
+
+func TestGenerateGoWithRecover(t *testing.T) {
+	rule := gentest.Rules[0]
+	e, err := evaluator.New(rule.Expr)
+	require.NoError(t, err)
+	actual, err := evaluator.GenerateGo(evaluator.Recover(e), "gentest", rule.Func)
+	require.NoError(t, err)
+	expected, err := os.ReadFile(filepath.Join("internal", "gentest", rule.File))
+	require.NoError(t, err)
+	require.Equal(t, string(expected), string(actual))
+}
@@ -0,0 +1,23 @@
+// Code generated by evaluator gen. DO NOT EDIT.
+
+package gentest
+
+import (
+	"errors"
+	"math"
+)
+
+// IsBroken evaluates `(is_inf(x / y) || is_nan(rate(x, y))) || (floor(x) == ceil(y))`.
+func IsBroken(x, y float64) (bool, error) {
+	if y == 0 {
+		return false, errors.New("IsBroken: divide by 0")
+	}
+	var t0 *float64
+	if y != 0 {
+		t0v := x / y
+		t0 = &t0v
+	}
+	t1 := math.Floor(x)
+	t2 := math.Ceil(y)
+	return math.IsInf(x/y, 0) || (t0 != nil && math.IsNaN(*t0)) || t1 == t2, nil
+}
@@ -0,0 +1,20 @@
+// Code generated by evaluator gen. DO NOT EDIT.
+
+package gentest
+
+import (
+	"errors"
+)
+
+// IsErrorRateHigh evaluates `rate(failures, requests) > 0.1`.
+func IsErrorRateHigh(failures, requests float64) (bool, error) {
+	var t0 *float64
+	if requests != 0 {
+		t0v := failures / requests
+		t0 = &t0v
+	}
+	if t0 == nil {
+		return false, errors.New("IsErrorRateHigh: `rate(failures, requests)` is nil")
+	}
+	return *t0 > 0.1, nil
+}
@@ -0,0 +1,29 @@
+// Package gentest has the functions generated by `evaluator gen`, they are tested to be equivalent to the evaluation.
+package gentest
+
+//go:generate go run ../../cmd/evaluator gen -pkg gentest -func IsHot -o is_hot.go "(var1 + 0.5) * var2 > 3"
+//go:generate go run ../../cmd/evaluator gen -pkg gentest -func IsErrorRateHigh -o is_error_rate_high.go "rate(failures, requests) > 0.1"
+//go:generate go run ../../cmd/evaluator gen -pkg gentest -func InRange -o in_range.go "0 <= x < 10 || !(y > 5) && x != y"
+//go:generate go run ../../cmd/evaluator gen -pkg gentest -func Score -o score.go "round_to(abs(x - y) / (z + 1), 2) * sign(x)"
+//go:generate go run ../../cmd/evaluator gen -pkg gentest -func Fallback -o fallback.go "coalesce(rate(a, b), clamp(c, -1, 1))"
+//go:generate go run ../../cmd/evaluator gen -pkg gentest -func Choose -o choose.go "if(x > y, sqrt(x), log(y)) + pow(x, 2) - -1"
+//go:generate go run ../../cmd/evaluator gen -pkg gentest -func IsBroken -o is_broken.go "is_inf(x / y) || is_nan(rate(x, y)) || floor(x) == ceil(y)"
+
+// Rule is a generated function and its expression.
+type Rule struct {
+	Func string
+	File string
+	Expr string
+	Fn   interface{}
+}
+
+// Rules are the generated functions, keep them in sync with the go:generate directives.
+var Rules = []Rule{
+	{Func: "IsHot", File: "is_hot.go", Expr: "(var1 + 0.5) * var2 > 3", Fn: IsHot},
+	{Func: "IsErrorRateHigh", File: "is_error_rate_high.go", Expr: "rate(failures, requests) > 0.1", Fn: IsErrorRateHigh},
+	{Func: "InRange", File: "in_range.go", Expr: "0 <= x < 10 || !(y > 5) && x != y", Fn: InRange},
+	{Func: "Score", File: "score.go", Expr: "round_to(abs(x - y) / (z + 1), 2) * sign(x)", Fn: Score},
+	{Func: "Fallback", File: "fallback.go", Expr: "coalesce(rate(a, b), clamp(c, -1, 1))", Fn: Fallback},
+	{Func: "Choose", File: "choose.go", Expr: "if(x > y, sqrt(x), log(y)) + pow(x, 2) - -1", Fn: Choose},
+	{Func: "IsBroken", File: "is_broken.go", Expr: "is_inf(x / y) || is_nan(rate(x, y)) || floor(x) == ceil(y)", Fn: IsBroken},
+}
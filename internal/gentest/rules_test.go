@@ -0,0 +1,57 @@
+package gentest_test
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/mashiike/evaluator"
+	"github.com/mashiike/evaluator/internal/gentest"
+	"github.com/stretchr/testify/require"
+)
+
+// specialValues are mixed into the random values to cover the boundaries like the division by zero.
+var specialValues = []float64{0, math.Copysign(0, -1), 1, -1, 0.5, 2, 5, 10, 0.1, math.NaN(), math.Inf(1), math.Inf(-1)}
+
+func TestRulesEquivalence(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, rule := range gentest.Rules {
+		t.Run(rule.Func, func(t *testing.T) {
+			e, err := evaluator.New(rule.Expr)
+			require.NoError(t, err)
+			names := evaluator.ReferencedVariables(e)
+			fn := reflect.ValueOf(rule.Fn)
+			require.Equal(t, len(names), fn.Type().NumIn())
+			for i := 0; i < 2000; i++ {
+				vars := make(evaluator.Variables, len(names))
+				args := make([]reflect.Value, 0, len(names))
+				for _, name := range names {
+					v := specialValues[r.Intn(len(specialValues))]
+					if r.Intn(2) == 0 {
+						v = (r.Float64() - 0.5) * 20
+					}
+					vars[name] = v
+					args = append(args, reflect.ValueOf(v))
+				}
+				expected, expectedErr := e.Eval(vars)
+				results := fn.Call(args)
+				var actualErr error
+				if len(results) == 2 && !results[1].IsNil() {
+					actualErr = results[1].Interface().(error)
+				}
+				if expectedErr != nil || actualErr != nil {
+					require.Error(t, expectedErr, "vars %v", vars)
+					require.Error(t, actualErr, "vars %v", vars)
+					continue
+				}
+				actual := results[0].Interface()
+				if f, ok := expected.(float64); ok && math.IsNaN(f) {
+					require.True(t, math.IsNaN(actual.(float64)), "vars %v", vars)
+					continue
+				}
+				require.Equal(t, expected, actual, "vars %v", vars)
+			}
+		})
+	}
+}
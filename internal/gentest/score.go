@@ -0,0 +1,28 @@
+// Code generated by evaluator gen. DO NOT EDIT.
+
+package gentest
+
+import (
+	"errors"
+	"math"
+)
+
+// Score evaluates `round_to(abs(x - y) / (z + 1), 2) * sign(x)`.
+func Score(x, y, z float64) (float64, error) {
+	t0 := math.Abs(x - y)
+	if (z + 1.0) == 0 {
+		return 0, errors.New("Score: divide by 0")
+	}
+	t1p := math.Pow(10, math.Trunc(2.0))
+	t1 := t0 / (z + 1.0)
+	if t1p != 0 && !math.IsInf(t1*t1p, 0) {
+		t1 = math.Round(t1*t1p) / t1p
+	}
+	t2 := x
+	if t2 > 0 {
+		t2 = 1
+	} else if t2 < 0 {
+		t2 = -1
+	}
+	return t1 * t2, nil
+}
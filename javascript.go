@@ -0,0 +1,124 @@
+package evaluator
+
+import (
+	_ "embed" // for the runtime of JavaScript
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+//go:embed js/runtime.js
+var javaScriptRuntime string
+
+// JavaScriptRuntime returns the source of the runtime of the functions generated by ToJavaScript.
+// It defines `evaluatorRuntime`, which is also exported as the CommonJS module.
+func JavaScriptRuntime() string {
+	return javaScriptRuntime
+}
+
+// ToJavaScript translates the expression into the source of a JavaScript function, like:
+//
+//	function (rt, vars) {
+//	  return rt.gt(rt.get(vars, "var1", false), 1);
+//	}
+//
+// The function has no free variable, it takes the runtime given by JavaScriptRuntime and the variables,
+// and `evaluatorRuntime.compile(source)` returns the function of the variables.
+// The results and the errors are the same as Eval, except that the regular expressions follow RegExp of JavaScript.
+// The functions not implemented by the runtime, like the list functions and the lambdas, are ErrNoJavaScriptEquivalent.
+func ToJavaScript(e Evaluator) (string, error) {
+	body, err := toJavaScript(unwrap(e))
+	if err != nil {
+		return "", err
+	}
+	return "function (rt, vars) {\n  return " + body + ";\n}", nil
+}
+
+var javaScriptOps = map[string]string{
+	"+": "add", "-": "sub", "*": "mul", "/": "quo",
+	"==": "eq", "=": "eq", "!=": "ne", "<": "lt", "<=": "le", ">": "gt", ">=": "ge",
+	"&&": "and", "||": "or",
+}
+
+var javaScriptUnaryOps = map[string]string{
+	"!": "not", "-": "neg", "+": "plus",
+}
+
+func toJavaScript(e Evaluator) (string, error) {
+	switch e := e.(type) {
+	case nilEvaluator:
+		return "null", nil
+	case *realNumericLiteralEvaluator:
+		return javaScriptNumber(e.value), nil
+	case *stringLiteralEvaluator:
+		bs, err := json.Marshal(e.str)
+		if err != nil {
+			return "", fmt.Errorf("ToJavaScript(`%s`) %w", e, err)
+		}
+		return string(bs), nil
+	case *lockupVariableEvaluator:
+		return fmt.Sprintf("rt.get(vars, %s, %t)", strconv.Quote(e.name), e.strict), nil
+	case *selectorEvaluator:
+		x, err := toJavaScript(e.x)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("rt.field(%s, %s, %t, %s)", x, strconv.Quote(e.name), e.strict, strconv.Quote(e.String())), nil
+	case *parenEvaluator:
+		return toJavaScript(e.x)
+	case *unaryEvaluator:
+		x, err := toJavaScript(e.x)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("rt.%s(%s)", javaScriptUnaryOps[e.op], x), nil
+	case *computableEvaluator:
+		return javaScriptBinary(javaScriptOps[e.op], e.x, e.y)
+	case *comparativeEvaluator:
+		return javaScriptBinary(javaScriptOps[e.op], e.x, e.y)
+	case *logicalEvaluator:
+		return javaScriptBinary(javaScriptOps[e.op], e.x, e.y)
+	case *callEvaluator:
+		if f, ok := getBuiltinFunc(e.funcName); !ok || !f.javaScript {
+			return "", fmt.Errorf("ToJavaScript(`%s`) %w", e, ErrNoJavaScriptEquivalent)
+		}
+		args := make([]string, 0, len(e.args))
+		for _, arg := range e.args {
+			s, err := toJavaScript(arg)
+			if err != nil {
+				return "", err
+			}
+			args = append(args, s)
+		}
+		return fmt.Sprintf("rt.fn.%s(%s)", e.funcName, strings.Join(args, ", ")), nil
+	default:
+		return "", fmt.Errorf("ToJavaScript(`%s`) %w", e, ErrNoJavaScriptEquivalent)
+	}
+}
+
+func javaScriptBinary(name string, x, y Evaluator) (string, error) {
+	xs, err := toJavaScript(x)
+	if err != nil {
+		return "", err
+	}
+	ys, err := toJavaScript(y)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("rt.%s(%s, %s)", name, xs, ys), nil
+}
+
+func javaScriptNumber(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	case math.Signbit(f):
+		return "(" + strconv.FormatFloat(f, 'g', -1, 64) + ")"
+	default:
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
@@ -0,0 +1,128 @@
+package evaluator_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/mashiike/evaluator"
+	"github.com/stretchr/testify/require"
+)
+
+type javaScriptCase struct {
+	Expr     string              `json:"expr"`
+	JS       string              `json:"js"`
+	Vars     evaluator.Variables `json:"vars"`
+	Expected interface{}         `json:"expected"`
+	Error    bool                `json:"error,omitempty"`
+}
+
+// TestJavaScriptConformance runs the conformance corpus against the JavaScript runtime.
+// The cases which are not parsed or have no JavaScript equivalent are skipped,
+// and so are the cases without the coercion, since the runtime always converts the values like Eval by default.
+func TestJavaScriptConformance(t *testing.T) {
+	files, err := filepath.Glob("testdata/conformance/*.yaml")
+	require.NoError(t, err)
+	var cases []javaScriptCase
+	for _, file := range files {
+		f, err := os.Open(file)
+		require.NoError(t, err)
+		loaded, err := evaluator.LoadConformanceCases(f)
+		f.Close()
+		require.NoError(t, err, file)
+		for _, c := range loaded {
+			if c.Coercion != nil && !*c.Coercion {
+				continue
+			}
+			e, err := evaluator.New(c.Expr)
+			if err != nil {
+				continue
+			}
+			e.Strict(c.Strict)
+			js, err := evaluator.ToJavaScript(e)
+			if errors.Is(err, evaluator.ErrNoJavaScriptEquivalent) {
+				continue
+			}
+			require.NoError(t, err, c.Expr)
+			jc := javaScriptCase{Expr: c.Expr, JS: js, Vars: c.Vars, Expected: c.Expected, Error: c.Error != ""}
+			if _, err := json.Marshal(jc); err != nil {
+				// JSON can not represent NaN and the objects whose keys are not string
+				continue
+			}
+			cases = append(cases, jc)
+		}
+	}
+	require.NotEmpty(t, cases)
+
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node is not found")
+	}
+	path := filepath.Join(t.TempDir(), "cases.json")
+	bs, err := json.Marshal(cases)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, bs, 0o600))
+	output, err := exec.Command(node, "js/conformance.js", path).CombinedOutput()
+	require.NoError(t, err, string(output))
+}
+
+func TestToJavaScript(t *testing.T) {
+	e, err := evaluator.New("coalesce(rate(errors, requests), 0) > 0.1 && obj.name == `foo`")
+	require.NoError(t, err)
+	actual, err := evaluator.ToJavaScript(e)
+	require.NoError(t, err)
+	expected := `function (rt, vars) {
+  return rt.and(rt.gt(rt.fn.coalesce(rt.fn.rate(rt.get(vars, "errors", false), rt.get(vars, "requests", false)), 0), 0.1), rt.eq(rt.field(rt.get(vars, "obj", false), "name", false, "obj.name"), "foo"));
+}`
+	require.Equal(t, expected, actual)
+}
+
+func TestToJavaScriptFailure(t *testing.T) {
+	cases := []struct {
+		expr        string
+		expectedErr string
+	}{
+		{
+			expr:        "sum(values)",
+			expectedErr: "ToJavaScript(`sum(values)`) has no JavaScript equivalent",
+		},
+		{
+			expr:        "any(values, x -> x > 1) && var1",
+			expectedErr: "ToJavaScript(`any(values, x -> x > 1)`) has no JavaScript equivalent",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.expr, func(t *testing.T) {
+			e, err := evaluator.New(c.expr)
+			require.NoError(t, err)
+			_, err = evaluator.ToJavaScript(e)
+			require.EqualError(t, err, c.expectedErr)
+			require.True(t, errors.Is(err, evaluator.ErrNoJavaScriptEquivalent))
+		})
+	}
+}
+
+func TestToJavaScriptWithCostBudget(t *testing.T) {
+	e, err := evaluator.New("var1 > 1", evaluator.WithCostBudget(100))
+	require.NoError(t, err)
+	actual, err := evaluator.ToJavaScript(e)
+	require.NoError(t, err)
+	expected := `function (rt, vars) {
+  return rt.gt(rt.get(vars, "var1", false), 1);
+}`
+	require.Equal(t, expected, actual)
+}
+
+func TestToJavaScriptWithRecover(t *testing.T) {
+	e, err := evaluator.New("var1 > 1", evaluator.WithCostBudget(100))
+	require.NoError(t, err)
+	actual, err := evaluator.ToJavaScript(evaluator.Recover(e))
+	require.NoError(t, err)
+	expected := `function (rt, vars) {
+  return rt.gt(rt.get(vars, "var1", false), 1);
+}`
+	require.Equal(t, expected, actual)
+}
@@ -0,0 +1,41 @@
+// Runs the conformance cases of the Go evaluator against the JavaScript runtime.
+//
+// Usage: node js/conformance.js <cases.json>
+//
+// Each case is {"expr", "js", "vars", "expected", "error"}, where "js" is the source generated by ToJavaScript.
+"use strict";
+
+var fs = require("fs");
+var path = require("path");
+var rt = require(path.join(__dirname, "runtime.js"));
+
+var cases = JSON.parse(fs.readFileSync(process.argv[2], "utf8"));
+var failures = 0;
+cases.forEach(function (c) {
+  var actual;
+  var err = null;
+  try {
+    actual = rt.compile(c.js)(c.vars || {});
+  } catch (e) {
+    err = e;
+  }
+  var expected = c.expected === undefined ? null : c.expected;
+  if (c.error) {
+    if (err === null) {
+      failures++;
+      console.log("FAIL " + c.expr + ": expected error, but got " + JSON.stringify(actual));
+    }
+    return;
+  }
+  if (err !== null) {
+    failures++;
+    console.log("FAIL " + c.expr + ": unexpected error " + err.message);
+    return;
+  }
+  if (JSON.stringify(actual) !== JSON.stringify(expected)) {
+    failures++;
+    console.log("FAIL " + c.expr + ": expected " + JSON.stringify(expected) + ", but got " + JSON.stringify(actual));
+  }
+});
+console.log(cases.length - failures + "/" + cases.length + " passed");
+process.exit(failures > 0 ? 1 : 0);
@@ -0,0 +1,403 @@
+// Runtime of the JavaScript functions generated by ToJavaScript of github.com/mashiike/evaluator.
+// The operators and the functions follow the semantics of the Go evaluator, and the errors are thrown as Error.
+var evaluatorRuntime = (function () {
+  "use strict";
+
+  function EvaluatorError(message) {
+    var err = new Error(message);
+    err.name = "EvaluatorError";
+    return err;
+  }
+
+  function typeName(v) {
+    if (v === null || v === undefined) {
+      return "nil";
+    }
+    if (Array.isArray(v)) {
+      return "list";
+    }
+    return typeof v;
+  }
+
+  function isNumber(v) {
+    return typeof v === "number";
+  }
+
+  function isString(v) {
+    return typeof v === "string";
+  }
+
+  function isBool(v) {
+    return typeof v === "boolean";
+  }
+
+  function cannot(op, args) {
+    var parts = [];
+    for (var i = 0; i < args.length; i++) {
+      parts.push("v" + (i + 1) + "[" + String(args[i]) + "]::" + typeName(args[i]));
+    }
+    return EvaluatorError(parts.join(" and ") + " can not `" + op + "` operation");
+  }
+
+  // parseFloat follows strconv.ParseFloat of Go for the decimal notation, Inf and NaN.
+  function parseFloat(s) {
+    if (/^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?$/.test(s)) {
+      return Number(s);
+    }
+    var m = /^([+-]?)(inf|infinity)$/i.exec(s);
+    if (m) {
+      return m[1] === "-" ? -Infinity : Infinity;
+    }
+    if (/^[+-]?nan$/i.test(s)) {
+      return NaN;
+    }
+    return null;
+  }
+
+  // formatFloat follows strconv.FormatFloat(n, 'f', -1, 64) of Go, the shortest decimal without the exponent.
+  function formatFloat(n) {
+    if (isNaN(n)) {
+      return "NaN";
+    }
+    if (n === Infinity) {
+      return "+Inf";
+    }
+    if (n === -Infinity) {
+      return "-Inf";
+    }
+    if (n === 0) {
+      return 1 / n < 0 ? "-0" : "0";
+    }
+    var s = String(n);
+    var m = /^(-?)(\d)(?:\.(\d+))?e([+-]\d+)$/.exec(s);
+    if (!m) {
+      return s;
+    }
+    var digits = m[2] + (m[3] || "");
+    var exp = parseInt(m[4], 10);
+    if (exp < 0) {
+      return m[1] + "0." + new Array(-exp).join("0") + digits;
+    }
+    if (digits.length <= exp + 1) {
+      return m[1] + digits + new Array(exp + 2 - digits.length).join("0");
+    }
+    return m[1] + digits.slice(0, exp + 1) + "." + digits.slice(exp + 1);
+  }
+
+  // parseBool follows strconv.ParseBool of Go.
+  function parseBool(s) {
+    if (["1", "t", "T", "TRUE", "true", "True"].indexOf(s) >= 0) {
+      return true;
+    }
+    if (["0", "f", "F", "FALSE", "false", "False"].indexOf(s) >= 0) {
+      return false;
+    }
+    return null;
+  }
+
+  function asNumber(v) {
+    if (isNumber(v)) {
+      return v;
+    }
+    if (isString(v)) {
+      return parseFloat(v);
+    }
+    return null;
+  }
+
+  function asString(v) {
+    if (isString(v)) {
+      return v;
+    }
+    if (isNumber(v)) {
+      return formatFloat(v);
+    }
+    return null;
+  }
+
+  // asBool follows the conversion of the Go evaluator, where the number is true if it is 0.
+  function asBool(v) {
+    if (isBool(v)) {
+      return v;
+    }
+    if (isNumber(v)) {
+      return v === 0;
+    }
+    if (isString(v)) {
+      return parseBool(v);
+    }
+    return null;
+  }
+
+  function nil(v) {
+    return v === null || v === undefined;
+  }
+
+  // compileRegexp converts the leading flags like `(?i)` of Go to the flags of RegExp.
+  function compileRegexp(pattern) {
+    var flags = "";
+    var m = /^\(\?([ims]+)\)/.exec(pattern);
+    if (m) {
+      pattern = pattern.slice(m[0].length);
+      flags = m[1].replace("s", "");
+      if (m[1].indexOf("s") >= 0) {
+        flags += "s";
+      }
+    }
+    try {
+      return new RegExp(pattern, flags);
+    } catch (e) {
+      throw EvaluatorError("pattern can not compile: " + e.message);
+    }
+  }
+
+  function unaryMath(name, f) {
+    return function (v) {
+      if (nil(v)) {
+        return null;
+      }
+      if (!isNumber(v)) {
+        throw cannot(name, [v]);
+      }
+      return f(v);
+    };
+  }
+
+  function round(n) {
+    return n < 0 ? -Math.round(-n) : Math.round(n);
+  }
+
+  var fn = {
+    coalesce: function () {
+      for (var i = 0; i < arguments.length; i++) {
+        if (!nil(arguments[i])) {
+          return arguments[i];
+        }
+      }
+      return null;
+    },
+    rate: function (v1, v2) {
+      if (!isNumber(v1) || !isNumber(v2)) {
+        throw cannot("rate", [v1, v2]);
+      }
+      if (v2 === 0) {
+        return null;
+      }
+      return v1 / v2;
+    },
+    as_numeric: function (v) {
+      return asNumber(v);
+    },
+    as_string: function (v) {
+      return asString(v);
+    },
+    if: function (cond, v1, v2) {
+      var b = asBool(cond);
+      if (b === null) {
+        throw cannot("if", [cond, v1, v2]);
+      }
+      return b ? v1 : v2;
+    },
+    regexp_match: function (s, pattern) {
+      if (!isString(s) || !isString(pattern)) {
+        throw cannot("regexp_match", [s, pattern]);
+      }
+      return compileRegexp(pattern).test(s);
+    },
+    string_contains: function (s1, s2) {
+      if (!isString(s1) || !isString(s2)) {
+        throw cannot("string_contains", [s1, s2]);
+      }
+      return s1.indexOf(s2) >= 0;
+    },
+    has_prefix: function (s1, s2) {
+      if (nil(s1) || nil(s2)) {
+        return null;
+      }
+      if (!isString(s1) || !isString(s2)) {
+        throw cannot("has_prefix", [s1, s2]);
+      }
+      return s1.slice(0, s2.length) === s2;
+    },
+    has_suffix: function (s1, s2) {
+      if (nil(s1) || nil(s2)) {
+        return null;
+      }
+      if (!isString(s1) || !isString(s2)) {
+        throw cannot("has_suffix", [s1, s2]);
+      }
+      return s2.length === 0 || s1.slice(-s2.length) === s2;
+    },
+    lower: function (s) {
+      if (nil(s)) {
+        return null;
+      }
+      if (!isString(s)) {
+        throw cannot("lower", [s]);
+      }
+      return s.toLowerCase();
+    },
+    upper: function (s) {
+      if (nil(s)) {
+        return null;
+      }
+      if (!isString(s)) {
+        throw cannot("upper", [s]);
+      }
+      return s.toUpperCase();
+    },
+    abs: unaryMath("abs", Math.abs),
+    round: unaryMath("round", round),
+    floor: unaryMath("floor", Math.floor),
+    ceil: unaryMath("ceil", Math.ceil),
+    trunc: unaryMath("trunc", function (n) {
+      return n < 0 ? Math.ceil(n) : Math.floor(n);
+    }),
+    sqrt: unaryMath("sqrt", Math.sqrt),
+    log: unaryMath("log", Math.log),
+    log10: unaryMath("log10", Math.log10 || function (n) {
+      return Math.log(n) / Math.LN10;
+    }),
+    log2: unaryMath("log2", Math.log2 || function (n) {
+      return Math.log(n) / Math.LN2;
+    }),
+    exp: unaryMath("exp", Math.exp),
+    sign: unaryMath("sign", function (n) {
+      return n > 0 ? 1 : n < 0 ? -1 : n;
+    }),
+    pow: function (v1, v2) {
+      if (nil(v1) || nil(v2)) {
+        return null;
+      }
+      if (!isNumber(v1) || !isNumber(v2)) {
+        throw cannot("pow", [v1, v2]);
+      }
+      return Math.pow(v1, v2);
+    },
+  };
+
+  function eq(v1, v2) {
+    if ((isBool(v1) && isBool(v2)) || (isString(v1) && isString(v2)) || (isNumber(v1) && isNumber(v2))) {
+      return v1 === v2;
+    }
+    throw cannot("==", [v1, v2]);
+  }
+
+  function lt(v1, v2) {
+    if ((isString(v1) && isString(v2)) || (isNumber(v1) && isNumber(v2))) {
+      return v1 < v2;
+    }
+    throw cannot("<", [v1, v2]);
+  }
+
+  function gt(v1, v2) {
+    if ((isString(v1) && isString(v2)) || (isNumber(v1) && isNumber(v2))) {
+      return v1 > v2;
+    }
+    throw cannot(">", [v1, v2]);
+  }
+
+  var rt = {
+    fn: fn,
+    get: function (vars, name, strict) {
+      if (vars !== null && vars !== undefined && Object.prototype.hasOwnProperty.call(vars, name) && vars[name] !== undefined) {
+        return vars[name];
+      }
+      if (strict) {
+        throw EvaluatorError(name + " variable not found");
+      }
+      return null;
+    },
+    field: function (v, name, strict, expr) {
+      if (v !== null && typeof v === "object" && !Array.isArray(v) && Object.prototype.hasOwnProperty.call(v, name) && v[name] !== undefined) {
+        return v[name];
+      }
+      if (strict) {
+        throw EvaluatorError(expr + " variable not found");
+      }
+      return null;
+    },
+    add: function (v1, v2) {
+      if ((isNumber(v1) && isNumber(v2)) || (isString(v1) && isString(v2))) {
+        return v1 + v2;
+      }
+      throw cannot("+", [v1, v2]);
+    },
+    sub: function (v1, v2) {
+      if (isNumber(v1) && isNumber(v2)) {
+        return v1 - v2;
+      }
+      throw cannot("-", [v1, v2]);
+    },
+    mul: function (v1, v2) {
+      if (isNumber(v1) && isNumber(v2)) {
+        return v1 * v2;
+      }
+      throw cannot("*", [v1, v2]);
+    },
+    quo: function (v1, v2) {
+      if (isNumber(v1) && isNumber(v2)) {
+        if (v2 === 0) {
+          throw EvaluatorError("divide by 0");
+        }
+        return v1 / v2;
+      }
+      throw cannot("/", [v1, v2]);
+    },
+    eq: eq,
+    ne: function (v1, v2) {
+      return !eq(v1, v2);
+    },
+    lt: lt,
+    gt: gt,
+    le: function (v1, v2) {
+      return lt(v1, v2) || eq(v1, v2);
+    },
+    ge: function (v1, v2) {
+      return gt(v1, v2) || eq(v1, v2);
+    },
+    and: function (v1, v2) {
+      if (!isBool(v1) || !isBool(v2)) {
+        throw EvaluatorError("is not both bool");
+      }
+      return v1 && v2;
+    },
+    or: function (v1, v2) {
+      if (!isBool(v1) || !isBool(v2)) {
+        throw EvaluatorError("is not both bool");
+      }
+      return v1 || v2;
+    },
+    not: function (v) {
+      var b = asBool(v);
+      if (b === null) {
+        throw cannot("!", [v]);
+      }
+      return !b;
+    },
+    neg: function (v) {
+      if (!isNumber(v)) {
+        throw cannot("-", [v]);
+      }
+      return -v;
+    },
+    plus: function (v) {
+      if (!isNumber(v)) {
+        throw cannot("+", [v]);
+      }
+      return v;
+    },
+    // compile creates the function of the variables from the source generated by ToJavaScript.
+    compile: function (source) {
+      var f = new Function("return " + source)();
+      return function (vars) {
+        return f(rt, vars);
+      };
+    },
+  };
+  return rt;
+})();
+
+if (typeof module !== "undefined" && module.exports) {
+  module.exports = evaluatorRuntime;
+}
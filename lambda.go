@@ -0,0 +1,374 @@
+package evaluator
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+const lambdaFuncName = "__lambda"
+
+// rewriteLambda rewrites the first lambda expression `x -> body` or `(x, y) -> body` into `__lambda(x, body)`.
+// The body continues until a `,` or a closing bracket at the same depth.
+func rewriteLambda(expr string, tokens []scannedToken) (string, bool) {
+	for i := 1; i+1 < len(tokens); i++ {
+		if tokens[i].tok != token.SUB || tokens[i+1].tok != token.GTR || tokens[i+1].offset != tokens[i].offset+1 {
+			continue
+		}
+		start, params, ok := scanLambdaParams(tokens[:i])
+		if !ok {
+			return expr, false
+		}
+		bodyStart := tokens[i+1].offset + 1
+		bodyEnd := len(expr)
+		depth := 0
+	body:
+		for _, tok := range tokens[i+2:] {
+			switch tok.tok {
+			case token.LPAREN, token.LBRACK, token.LBRACE:
+				depth++
+			case token.RPAREN, token.RBRACK, token.RBRACE:
+				if depth == 0 {
+					bodyEnd = tok.offset
+					break body
+				}
+				depth--
+			case token.COMMA:
+				if depth == 0 {
+					bodyEnd = tok.offset
+					break body
+				}
+			}
+		}
+		var builder strings.Builder
+		builder.WriteString(expr[:start])
+		builder.WriteString(lambdaFuncName)
+		builder.WriteRune('(')
+		builder.WriteString(strings.Join(params, ", "))
+		builder.WriteString(", ")
+		builder.WriteString(strings.TrimSpace(expr[bodyStart:bodyEnd]))
+		builder.WriteRune(')')
+		builder.WriteString(expr[bodyEnd:])
+		return builder.String(), true
+	}
+	return expr, false
+}
+
+func scanLambdaParams(tokens []scannedToken) (int, []string, bool) {
+	last := tokens[len(tokens)-1]
+	if last.tok == token.IDENT {
+		return last.offset, []string{last.lit}, true
+	}
+	if last.tok != token.RPAREN {
+		return 0, nil, false
+	}
+	params := make([]string, 0, 2)
+	for i := len(tokens) - 2; i >= 0; i-- {
+		switch tokens[i].tok {
+		case token.IDENT:
+			params = append([]string{tokens[i].lit}, params...)
+		case token.COMMA:
+		case token.LPAREN:
+			if i > 0 {
+				switch tokens[i-1].tok {
+				case token.IDENT, token.RPAREN, token.RBRACK:
+					return 0, nil, false
+				}
+			}
+			return tokens[i].offset, params, len(params) > 0
+		default:
+			return 0, nil, false
+		}
+	}
+	return 0, nil, false
+}
+
+type lambdaEvaluator struct {
+	params []string
+	body   Evaluator
+}
+
+func parseLambdaExpr(cfg *config, str string, expr *ast.CallExpr) (*lambdaEvaluator, error) {
+	if len(expr.Args) < 2 {
+		return nil, fmt.Errorf("parse lambda `%s` body not found", getSubExpr(str, expr))
+	}
+	params := make([]string, 0, len(expr.Args)-1)
+	for i, arg := range expr.Args[:len(expr.Args)-1] {
+		ident, ok := arg.(*ast.Ident)
+		if !ok {
+			return nil, fmt.Errorf("parse lambda params[%d] `%s` is not identifier", i, getSubExpr(str, arg))
+		}
+		params = append(params, ident.Name)
+	}
+	bodyExpr := expr.Args[len(expr.Args)-1]
+	body, err := parseExpr(cfg, str, bodyExpr)
+	if err != nil {
+		return nil, fmt.Errorf("parse lambda body `%s` %w", getSubExpr(str, bodyExpr), err)
+	}
+	return &lambdaEvaluator{
+		params: params,
+		body:   body,
+	}, nil
+}
+
+func (e *lambdaEvaluator) Eval(vars Variables) (interface{}, error) {
+	return nil, fmt.Errorf("Eval(`%s`) lambda can only be used as an argument of higher-order functions", e)
+}
+
+func (e *lambdaEvaluator) Strict(v bool) {
+	e.body.Strict(v)
+}
+
+func (e *lambdaEvaluator) AsComparator() (Comparator, bool) {
+	return nil, false
+}
+
+func (e *lambdaEvaluator) String() string {
+	if len(e.params) == 1 {
+		return fmt.Sprintf("%s -> %s", e.params[0], e.body)
+	}
+	return fmt.Sprintf("(%s) -> %s", strings.Join(e.params, ", "), e.body)
+}
+
+// bind creates a child scope layered over the caller's Variables, the params are bound in the child for each call.
+func (e *lambdaEvaluator) bind(vars Variables) *lambdaScope {
+	return &lambdaScope{
+		lambda: e,
+		vars:   childScope(vars, len(e.params)),
+		meter:  meterOf(vars),
+	}
+}
+
+type lambdaScope struct {
+	lambda *lambdaEvaluator
+	vars   Variables
+	meter  *costMeter
+}
+
+func (s *lambdaScope) call(args ...interface{}) (interface{}, error) {
+	if s.meter != nil {
+		if err := s.meter.charge(staticCost(s.lambda)); err != nil {
+			return nil, err
+		}
+	}
+	for i, param := range s.lambda.params {
+		s.vars[param] = args[i]
+	}
+	return s.lambda.body.Eval(s.vars)
+}
+
+func (s *lambdaScope) test(args ...interface{}) (bool, error) {
+	ret, err := s.call(args...)
+	if err != nil {
+		return false, err
+	}
+	if ret == nil {
+		return false, nil
+	}
+	b, ok := isBool(ret)
+	if !ok {
+		return false, fmt.Errorf("`%s` returns v[%v]::%T, is not bool", s.lambda, ret, ret)
+	}
+	return b, nil
+}
+
+type higherOrderFunc func(list []interface{}, args []interface{}, scope *lambdaScope) (interface{}, error)
+
+// higherOrderFuncSpec is the implementation of the higher-order function, the lambda is the last argument.
+type higherOrderFuncSpec struct {
+	f           higherOrderFunc
+	numOfParams int
+}
+
+func parseHigherOrderCallExpr(cfg *config, str string, builtin *builtinFunc, expr *ast.CallExpr) (Evaluator, error) {
+	displayName := builtin.name
+	spec := builtin.higherOrder
+	if err := builtin.checkNumOfArgs(len(expr.Args)); err != nil {
+		return nil, err
+	}
+	argEvaluators := make([]Evaluator, 0, len(expr.Args)-1)
+	for i, arg := range expr.Args[:len(expr.Args)-1] {
+		argStr := getSubExpr(str, arg)
+		argEvaluator, err := parseExpr(cfg, str, arg)
+		if err != nil {
+			return nil, fmt.Errorf("parse CallExpr.Args[%d] `%s` %w", i, argStr, err)
+		}
+		argEvaluators = append(argEvaluators, argEvaluator)
+	}
+	lambdaExpr := expr.Args[len(expr.Args)-1]
+	lambdaStr := getSubExpr(str, lambdaExpr)
+	call, ok := lambdaExpr.(*ast.CallExpr)
+	if !ok || !isLambdaExpr(call) {
+		return nil, fmt.Errorf("parse CallExpr.Args[%d] `%s` is not lambda", len(expr.Args)-1, lambdaStr)
+	}
+	lambda, err := parseLambdaExpr(cfg, str, call)
+	if err != nil {
+		return nil, fmt.Errorf("parse CallExpr.Args[%d] `%s` %w", len(expr.Args)-1, lambdaStr, err)
+	}
+	if len(lambda.params) != spec.numOfParams {
+		return nil, fmt.Errorf("%s() func is expected lambda with %d params, but given %d params", displayName, spec.numOfParams, len(lambda.params))
+	}
+	return &higherOrderEvaluator{
+		list:     argEvaluators[0],
+		args:     argEvaluators[1:],
+		lambda:   lambda,
+		f:        spec.f,
+		funcName: displayName,
+	}, nil
+}
+
+func isLambdaExpr(expr *ast.CallExpr) bool {
+	ident, ok := expr.Fun.(*ast.Ident)
+	return ok && ident.Name == lambdaFuncName
+}
+
+// higherOrderEvaluator evaluates the lambda lazily for each element of the list.
+type higherOrderEvaluator struct {
+	list     Evaluator
+	args     []Evaluator
+	lambda   *lambdaEvaluator
+	f        higherOrderFunc
+	funcName string
+}
+
+func (e *higherOrderEvaluator) Eval(vars Variables) (interface{}, error) {
+	v, err := e.list.Eval(vars)
+	if err != nil {
+		return nil, fmt.Errorf("Eval(`%s`) Args[0] %w", e, err)
+	}
+	var list []interface{}
+	if v != nil {
+		var ok bool
+		list, ok = isList(v)
+		if !ok {
+			return nil, fmt.Errorf("Eval(`%s`) Args[0] v[%v]::%T is not list", e, v, v)
+		}
+	}
+	args := make([]interface{}, 0, len(e.args))
+	for i, a := range e.args {
+		arg, err := a.Eval(vars)
+		if err != nil {
+			return nil, fmt.Errorf("Eval(`%s`) Args[%d] %w", e, i+1, err)
+		}
+		args = append(args, arg)
+	}
+	ret, err := e.f(list, args, e.lambda.bind(vars))
+	if err != nil {
+		return nil, fmt.Errorf("Eval(`%s`) %w", e, err)
+	}
+	return ret, nil
+}
+
+func (e *higherOrderEvaluator) Strict(v bool) {
+	e.list.Strict(v)
+	for _, arg := range e.args {
+		arg.Strict(v)
+	}
+	e.lambda.Strict(v)
+}
+
+func (e *higherOrderEvaluator) AsComparator() (Comparator, bool) {
+	return nil, false
+}
+
+func (e *higherOrderEvaluator) String() string {
+	var builder strings.Builder
+	builder.WriteString(e.funcName)
+	builder.WriteRune('(')
+	builder.WriteString(e.list.String())
+	for _, arg := range e.args {
+		builder.WriteString(", ")
+		builder.WriteString(arg.String())
+	}
+	builder.WriteString(", ")
+	builder.WriteString(e.lambda.String())
+	builder.WriteRune(')')
+	return builder.String()
+}
+
+func anyHigherOrderFunc(list []interface{}, _ []interface{}, scope *lambdaScope) (interface{}, error) {
+	for _, v := range list {
+		ok, err := scope.test(v)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func allHigherOrderFunc(list []interface{}, _ []interface{}, scope *lambdaScope) (interface{}, error) {
+	for _, v := range list {
+		ok, err := scope.test(v)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func noneHigherOrderFunc(list []interface{}, args []interface{}, scope *lambdaScope) (interface{}, error) {
+	ret, err := anyHigherOrderFunc(list, args, scope)
+	if err != nil {
+		return nil, err
+	}
+	return !ret.(bool), nil
+}
+
+func mapHigherOrderFunc(list []interface{}, _ []interface{}, scope *lambdaScope) (interface{}, error) {
+	ret := make([]interface{}, 0, len(list))
+	for _, v := range list {
+		mapped, err := scope.call(v)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, mapped)
+	}
+	return ret, nil
+}
+
+func filterHigherOrderFunc(list []interface{}, _ []interface{}, scope *lambdaScope) (interface{}, error) {
+	ret := make([]interface{}, 0, len(list))
+	for _, v := range list {
+		ok, err := scope.test(v)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			ret = append(ret, v)
+		}
+	}
+	return ret, nil
+}
+
+func countIfHigherOrderFunc(list []interface{}, _ []interface{}, scope *lambdaScope) (interface{}, error) {
+	count := 0
+	for _, v := range list {
+		ok, err := scope.test(v)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			count++
+		}
+	}
+	return float64(count), nil
+}
+
+func reduceHigherOrderFunc(list []interface{}, args []interface{}, scope *lambdaScope) (interface{}, error) {
+	acc := args[0]
+	for _, v := range list {
+		var err error
+		acc, err = scope.call(acc, v)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return acc, nil
+}
@@ -0,0 +1,295 @@
+package evaluator
+
+import "fmt"
+
+// Option is an option of New, which limits the resources, the functions and the conversions used by the expression.
+// The limits are not set by default, a zero or negative value also means no limit.
+type Option func(*config)
+
+type config struct {
+	maxLength        int
+	maxDepth         int
+	maxNodes         int
+	maxFunctionCalls int
+	maxPatternLength int
+	costBudget       int
+	funcs            *FuncSet
+	noCoercion       bool
+}
+
+// WithMaxLength limits the length of the expression in bytes.
+func WithMaxLength(n int) Option {
+	return func(cfg *config) {
+		cfg.maxLength = n
+	}
+}
+
+// WithMaxDepth limits the depth of the tree of the expression, the depth of `a + b` is 2.
+func WithMaxDepth(n int) Option {
+	return func(cfg *config) {
+		cfg.maxDepth = n
+	}
+}
+
+// WithMaxNodes limits the number of the nodes of the tree of the expression, the nodes of `a + b` are 3.
+func WithMaxNodes(n int) Option {
+	return func(cfg *config) {
+		cfg.maxNodes = n
+	}
+}
+
+// WithMaxFunctionCalls limits the number of the function calls written in the expression.
+func WithMaxFunctionCalls(n int) Option {
+	return func(cfg *config) {
+		cfg.maxFunctionCalls = n
+	}
+}
+
+// WithMaxPatternLength limits the length of the patterns of the regexp functions in bytes.
+// The literal patterns are checked by New, and the patterns given by the variables are checked in the evaluation.
+func WithMaxPatternLength(n int) Option {
+	return func(cfg *config) {
+		cfg.maxPatternLength = n
+	}
+}
+
+// WithCostBudget sets the budget of the cost of each evaluation, the evaluation exceeding it fails with ErrBudgetExceeded.
+// Each node of the expression costs 1, and the nodes of a lambda cost for each call of it.
+// The regexp functions cost 10 more, and the function calls and the string concatenations cost 1 more
+// for each byte of the string arguments and results and for each element of the list arguments and results.
+// The results of the functions building a large string, like format and replace, are estimated and charged before the call.
+func WithCostBudget(n int) Option {
+	return func(cfg *config) {
+		cfg.costBudget = n
+	}
+}
+
+// LimitExceededError is an error that occurs when the expression exceeds the limit given by Option.
+type LimitExceededError struct {
+	// Limit is the name of the limit, like `depth`.
+	Limit  string
+	Max    int
+	Actual int
+}
+
+func (e *LimitExceededError) Error() string {
+	return fmt.Sprintf("%s %d exceeds the limit %d", e.Limit, e.Actual, e.Max)
+}
+
+// apply checks the limits of the parsed expression, and returns the evaluator limited in the evaluation.
+func (cfg *config) apply(e Evaluator) (Evaluator, error) {
+	nodes, calls := 0, 0
+	var visit func(e Evaluator, depth int) error
+	visit = func(e Evaluator, depth int) error {
+		nodes++
+		if cfg.maxDepth > 0 && depth > cfg.maxDepth {
+			return &LimitExceededError{Limit: "depth", Max: cfg.maxDepth, Actual: depth}
+		}
+		switch e := e.(type) {
+		case *callEvaluator:
+			calls++
+			if err := cfg.limitPattern(e); err != nil {
+				return err
+			}
+		case *higherOrderEvaluator, *statefulCallEvaluator:
+			calls++
+		}
+		for _, child := range childrenOf(e) {
+			if err := visit(child, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := visit(e, 1); err != nil {
+		return nil, err
+	}
+	if cfg.maxNodes > 0 && nodes > cfg.maxNodes {
+		return nil, &LimitExceededError{Limit: "nodes", Max: cfg.maxNodes, Actual: nodes}
+	}
+	if cfg.maxFunctionCalls > 0 && calls > cfg.maxFunctionCalls {
+		return nil, &LimitExceededError{Limit: "function calls", Max: cfg.maxFunctionCalls, Actual: calls}
+	}
+	if cfg.costBudget > 0 {
+		return &budgetEvaluator{x: e, budget: cfg.costBudget, cost: staticCost(e)}, nil
+	}
+	return e, nil
+}
+
+// limitPattern checks the literal pattern of the regexp function, and replaces the function to check the pattern given by the variable.
+func (cfg *config) limitPattern(e *callEvaluator) error {
+	const i = 1 // the pattern is the second argument of the regexp functions
+	builtin, ok := getBuiltinFunc(e.funcName)
+	if !ok || !builtin.regexp || cfg.maxPatternLength <= 0 || i >= len(e.args) {
+		return nil
+	}
+	max := cfg.maxPatternLength
+	if lit, ok := e.args[i].(*stringLiteralEvaluator); ok {
+		if len(lit.str) > max {
+			return &LimitExceededError{Limit: "pattern length", Max: max, Actual: len(lit.str)}
+		}
+		return nil
+	}
+	f := e.f
+	e.f = func(args ...interface{}) (interface{}, error) {
+		if pattern, ok := isString(args[i]); ok && len(pattern) > max {
+			return nil, fmt.Errorf("%s() %w", e.funcName, &LimitExceededError{Limit: "pattern length", Max: max, Actual: len(pattern)})
+		}
+		return f(args...)
+	}
+	if size := e.size; size != nil {
+		// the pattern is not compiled to estimate the size, since the call fails by the limit
+		e.size = func(args ...interface{}) int {
+			if pattern, ok := isString(args[i]); ok && len(pattern) > max {
+				return 0
+			}
+			return size(args...)
+		}
+	}
+	return nil
+}
+
+// budgetVariableName is the reserved name of the cost meter in Variables, it can not be referenced by the expressions.
+const budgetVariableName = "\x00budget"
+
+// costMeter is the remaining budget of an evaluation.
+type costMeter struct {
+	remaining int
+}
+
+func meterOf(vars Variables) *costMeter {
+	v, _ := vars.Lookup(budgetVariableName)
+	m, _ := v.(*costMeter)
+	return m
+}
+
+// charge consumes the cost from the budget, it returns ErrBudgetExceeded if the budget runs out.
+func (m *costMeter) charge(cost int) error {
+	m.remaining -= cost
+	if m.remaining < 0 {
+		return ErrBudgetExceeded
+	}
+	return nil
+}
+
+// staticCost returns the number of the nodes evaluated once in an evaluation, all of them are evaluated since the evaluation is eager.
+// The nodes in the lambdas are not included, they are charged for each call of the lambda.
+func staticCost(e Evaluator) int {
+	cost := 1
+	for _, child := range childrenOf(e) {
+		if _, ok := child.(*lambdaEvaluator); ok {
+			continue
+		}
+		cost += staticCost(child)
+	}
+	return cost
+}
+
+// regexpCost is the cost of the regexp function call in addition to the cost of the node.
+const regexpCost = 10
+
+// callCost returns the cost of the function call in addition to the cost of the node, it is charged before the call.
+func callCost(funcName string, args []interface{}) int {
+	cost := 0
+	if f, ok := getBuiltinFunc(funcName); ok && f.regexp {
+		cost = regexpCost
+	}
+	for _, arg := range args {
+		cost += valueCost(arg)
+	}
+	return cost
+}
+
+// valueCost returns the cost of the value given to or returned by the functions and the operators,
+// the strings cost 1 for each byte and the lists cost 1 for each element, so that building a large string is limited.
+func valueCost(v interface{}) int {
+	switch v := v.(type) {
+	case nil, bool, float64, int:
+		return 0
+	case string:
+		return len(v)
+	case []interface{}:
+		return len(v)
+	case []float64:
+		return len(v)
+	}
+	if s, ok := isString(v); ok {
+		return len(s)
+	}
+	if list, ok := isList(v); ok {
+		return len(list)
+	}
+	return 0
+}
+
+// budgetEvaluator gives the cost meter to each evaluation.
+type budgetEvaluator struct {
+	x      Evaluator
+	budget int
+	// cost is the static cost of x.
+	cost int
+}
+
+func (e *budgetEvaluator) scope(vars Variables) (Variables, error) {
+	if e.cost > e.budget {
+		return nil, fmt.Errorf("Eval(`%s`) %w", e, ErrBudgetExceeded)
+	}
+	scope := childScope(vars, 1)
+	scope[budgetVariableName] = &costMeter{remaining: e.budget - e.cost}
+	return scope, nil
+}
+
+func (e *budgetEvaluator) Eval(vars Variables) (interface{}, error) {
+	scope, err := e.scope(vars)
+	if err != nil {
+		return nil, err
+	}
+	return e.x.Eval(scope)
+}
+
+func (e *budgetEvaluator) Strict(v bool) {
+	e.x.Strict(v)
+}
+
+func (e *budgetEvaluator) AsComparator() (Comparator, bool) {
+	c, ok := e.x.AsComparator()
+	if !ok {
+		return nil, false
+	}
+	return &budgetComparator{x: c, e: e}, true
+}
+
+func (e *budgetEvaluator) String() string {
+	return e.x.String()
+}
+
+func (e *budgetEvaluator) children() []Evaluator { return []Evaluator{e.x} }
+
+func (e *budgetEvaluator) withChildren(children []Evaluator) Evaluator {
+	return &budgetEvaluator{x: children[0], budget: e.budget, cost: staticCost(children[0])}
+}
+
+type budgetComparator struct {
+	x Comparator
+	e *budgetEvaluator
+}
+
+func (c *budgetComparator) Compare(vars Variables) (bool, error) {
+	scope, err := c.e.scope(vars)
+	if err != nil {
+		return false, err
+	}
+	return c.x.Compare(scope)
+}
+
+func (c *budgetComparator) CompareDetailed(vars Variables) (bool, []Condition, error) {
+	scope, err := c.e.scope(vars)
+	if err != nil {
+		return false, nil, err
+	}
+	return CompareDetailed(c.x, scope)
+}
+
+func (c *budgetComparator) String() string {
+	return c.x.String()
+}
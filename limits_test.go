@@ -0,0 +1,232 @@
+package evaluator_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mashiike/evaluator"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewWithLimits(t *testing.T) {
+	cases := []struct {
+		expr        string
+		opts        []evaluator.Option
+		expectedErr string
+	}{
+		{
+			expr: "var1 + var2",
+			opts: []evaluator.Option{evaluator.WithMaxLength(11), evaluator.WithMaxDepth(2), evaluator.WithMaxNodes(3)},
+		},
+		{
+			expr:        "var1 + var2",
+			opts:        []evaluator.Option{evaluator.WithMaxLength(5)},
+			expectedErr: "length 11 exceeds the limit 5",
+		},
+		{
+			expr:        "(a + b) * c",
+			opts:        []evaluator.Option{evaluator.WithMaxDepth(2)},
+			expectedErr: "depth 3 exceeds the limit 2",
+		},
+		{
+			expr:        "a + b + c",
+			opts:        []evaluator.Option{evaluator.WithMaxNodes(3)},
+			expectedErr: "nodes 5 exceeds the limit 3",
+		},
+		{
+			expr: "abs(a) + abs(b)",
+			opts: []evaluator.Option{evaluator.WithMaxFunctionCalls(2)},
+		},
+		{
+			expr:        "any(values, v -> abs(v) > 1)",
+			opts:        []evaluator.Option{evaluator.WithMaxFunctionCalls(1)},
+			expectedErr: "function calls 2 exceeds the limit 1",
+		},
+		{
+			expr: "regexp_match(var1, `^abc$`)",
+			opts: []evaluator.Option{evaluator.WithMaxPatternLength(5)},
+		},
+		{
+			expr:        "regexp_match(var1, `^abcdef$`)",
+			opts:        []evaluator.Option{evaluator.WithMaxPatternLength(5)},
+			expectedErr: "pattern length 8 exceeds the limit 5",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.expr, func(t *testing.T) {
+			_, err := evaluator.New(c.expr, c.opts...)
+			if c.expectedErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, c.expectedErr)
+			var limitErr *evaluator.LimitExceededError
+			require.True(t, errors.As(err, &limitErr))
+		})
+	}
+}
+
+func TestMaxPatternLengthOfVariable(t *testing.T) {
+	e, err := evaluator.New("regexp_match(var1, var2)", evaluator.WithMaxPatternLength(5))
+	require.NoError(t, err)
+	ret, err := e.Eval(evaluator.Variables{"var1": "abc", "var2": "^abc$"})
+	require.NoError(t, err)
+	require.Equal(t, true, ret)
+	_, err = e.Eval(evaluator.Variables{"var1": "abc", "var2": "^abcdef$"})
+	require.EqualError(t, err, "regexp_match() pattern length 8 exceeds the limit 5")
+	var limitErr *evaluator.LimitExceededError
+	require.True(t, errors.As(err, &limitErr))
+}
+
+func TestCostBudget(t *testing.T) {
+	values := []interface{}{1.0, 2.0, 3.0, 4.0, 5.0, 6.0, 7.0, 8.0, 9.0, 10.0}
+	cases := []struct {
+		expr   string
+		vars   evaluator.Variables
+		cost   int
+		result interface{}
+	}{
+		{
+			expr:   "var1 + var2 * 2",
+			vars:   evaluator.Variables{"var1": 1, "var2": 2},
+			cost:   5,
+			result: 5.0,
+		},
+		{
+			expr:   "sum(values)",
+			vars:   evaluator.Variables{"values": values},
+			cost:   12,
+			result: 55.0,
+		},
+		{
+			expr:   "regexp_match(var1, `^a`)",
+			vars:   evaluator.Variables{"var1": "abc"},
+			cost:   3 + 10 + 3 + 2,
+			result: true,
+		},
+		{
+			expr:   "upper(var1 + `b`)",
+			vars:   evaluator.Variables{"var1": "a"},
+			cost:   4 + (1 + 1 + 2) + (2 + 2),
+			result: "AB",
+		},
+		{
+			expr:   "any(values, v -> v > 100)",
+			vars:   evaluator.Variables{"values": values},
+			cost:   42,
+			result: false,
+		},
+		{
+			expr:   "count_if(values, v -> count_if(values, w -> w > v) > 0)",
+			vars:   evaluator.Variables{"values": values},
+			cost:   2 + 10*(5+10*4),
+			result: 9.0,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.expr, func(t *testing.T) {
+			e, err := evaluator.New(c.expr, evaluator.WithCostBudget(c.cost))
+			require.NoError(t, err)
+			for i := 0; i < 2; i++ {
+				ret, err := e.Eval(c.vars)
+				require.NoError(t, err, "the budget is for each evaluation")
+				require.EqualValues(t, c.result, ret)
+			}
+
+			e, err = evaluator.New(c.expr, evaluator.WithCostBudget(c.cost-1))
+			require.NoError(t, err)
+			_, err = e.Eval(c.vars)
+			require.Error(t, err)
+			require.True(t, errors.Is(err, evaluator.ErrBudgetExceeded), err.Error())
+		})
+	}
+}
+
+func TestCostBudgetString(t *testing.T) {
+	s := strings.Repeat("a", 20)
+	e, err := evaluator.New("len(replace(replace(replace(s, ``, s), ``, s), ``, s))", evaluator.WithCostBudget(50))
+	require.NoError(t, err)
+	_, err = e.Eval(evaluator.Variables{"s": s})
+	require.True(t, errors.Is(err, evaluator.ErrBudgetExceeded))
+
+	e, err = evaluator.New("len(replace(s, `a`, `b`))", evaluator.WithCostBudget(100))
+	require.NoError(t, err)
+	ret, err := e.Eval(evaluator.Variables{"s": s})
+	require.NoError(t, err)
+	require.EqualValues(t, 20, ret)
+}
+
+func TestCostBudgetStringEstimated(t *testing.T) {
+	cases := []string{
+		"len(format(`%1000000d`, 1))",
+		"len(format(`%*d`, 1000000, 1))",
+		"len(join(values, s))",
+		"len(replace(s, `a`, s))",
+		"len(regexp_replace(s, `a`, s))",
+	}
+	vars := evaluator.Variables{
+		"s":      strings.Repeat("a", 1000),
+		"values": strings.Split(strings.Repeat("a", 20), ""),
+	}
+	for _, expr := range cases {
+		t.Run(expr, func(t *testing.T) {
+			e, err := evaluator.New(expr, evaluator.WithCostBudget(10000))
+			require.NoError(t, err)
+			_, err = e.Eval(vars)
+			require.True(t, errors.Is(err, evaluator.ErrBudgetExceeded), "the result is charged before it is built")
+		})
+	}
+
+	e, err := evaluator.New("format(`%5d-%s`, 1, s)", evaluator.WithCostBudget(100))
+	require.NoError(t, err)
+	ret, err := e.Eval(evaluator.Variables{"s": "abc"})
+	require.NoError(t, err)
+	require.EqualValues(t, "    1-abc", ret)
+}
+
+func TestCostBudgetEnvironment(t *testing.T) {
+	env := evaluator.NewEnvironment(evaluator.WithCostBudget(1000))
+	require.NoError(t, env.Define("total", "sum(values)"))
+	values := make([]interface{}, 100)
+	for i := range values {
+		values[i] = float64(i)
+	}
+
+	e, err := evaluator.New("total > 0", evaluator.WithCostBudget(50))
+	require.NoError(t, err)
+	_, err = env.Eval(e, evaluator.Variables{"values": values})
+	require.True(t, errors.Is(err, evaluator.ErrBudgetExceeded), "the derived variable is charged to the budget of the referrer")
+
+	e, err = evaluator.New("total > 0", evaluator.WithCostBudget(200))
+	require.NoError(t, err)
+	ret, err := env.Eval(e, evaluator.Variables{"values": values})
+	require.NoError(t, err)
+	require.EqualValues(t, true, ret)
+
+	e, err = evaluator.New("total > 0")
+	require.NoError(t, err)
+	ret, err = env.Eval(e, evaluator.Variables{"values": values})
+	require.NoError(t, err, "the derived variable is evaluated with its own budget")
+	require.EqualValues(t, true, ret)
+}
+
+func TestCostBudgetComparator(t *testing.T) {
+	e, err := evaluator.New("any(values, v -> v > 1) && var1 > 0", evaluator.WithCostBudget(14))
+	require.NoError(t, err)
+	require.Equal(t, "any(values, v -> v > 1) && (var1 > 0)", e.String())
+	c, ok := e.AsComparator()
+	require.True(t, ok)
+	ret, err := c.Compare(evaluator.Variables{"values": []float64{0, 2}, "var1": 1})
+	require.NoError(t, err)
+	require.True(t, ret)
+	_, err = c.Compare(evaluator.Variables{"values": []float64{0, 1, 2}, "var1": 1})
+	require.EqualError(t, err, "Eval(`any(values, v -> v > 1) && (var1 > 0)`) Eval(`any(values, v -> v > 1)`) cost budget exceeded")
+
+	e, err = evaluator.New("var1 + var2 > 0", evaluator.WithCostBudget(4))
+	require.NoError(t, err)
+	c, ok = e.AsComparator()
+	require.True(t, ok)
+	_, err = c.Compare(evaluator.Variables{"var1": 1, "var2": 1})
+	require.EqualError(t, err, "Eval(`var1 + var2 > 0`) cost budget exceeded")
+}
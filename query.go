@@ -0,0 +1,360 @@
+package evaluator
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ToMongoFilter translates the comparator into a MongoDB query filter, like `{"$and": [{"cpu": {"$gt": 90}}, ...]}`.
+// The comparisons must be between a variable and a literal, `x.y` is the field path `x.y`.
+// The equalities of the same field joined by `||` are `$in`, and regexp_match, string_contains, has_prefix and has_suffix are `$regex`.
+// The constructs which have no equivalent, like the arithmetic of the variables, are ErrNoQueryEquivalent.
+func ToMongoFilter(c Comparator) (map[string]interface{}, error) {
+	e, ok := unwrapComparator(c).(Evaluator)
+	if !ok {
+		return nil, fmt.Errorf("ToMongoFilter(`%s`) %w", c, ErrNoQueryEquivalent)
+	}
+	return toMongoFilter(e)
+}
+
+// ToElasticsearchQuery translates the comparator into a query of Elasticsearch, like `{"bool": {"filter": [{"range": ...}]}}`.
+// The comparisons are `term` and `range`, the equalities of the same field joined by `||` are `terms`,
+// regexp_match is `regexp`, string_contains is `wildcard` and has_prefix is `prefix`.
+// Note that `regexp` of Elasticsearch matches the whole value and does not support the syntax like `\d`,
+// the pattern is enclosed by `.*` unless it is anchored by `^` or `$`.
+func ToElasticsearchQuery(c Comparator) (map[string]interface{}, error) {
+	e, ok := unwrapComparator(c).(Evaluator)
+	if !ok {
+		return nil, fmt.Errorf("ToElasticsearchQuery(`%s`) %w", c, ErrNoQueryEquivalent)
+	}
+	return toElasticsearchQuery(e)
+}
+
+// queryComparison is the comparison between a field and a literal, the field is on the left.
+type queryComparison struct {
+	field string
+	op    string
+	value interface{}
+}
+
+var flippedOps = map[string]string{
+	"==": "==", "=": "==", "!=": "!=", "<": ">", ">": "<", "<=": ">=", ">=": "<=",
+}
+
+func newQueryComparison(e *comparativeEvaluator) (queryComparison, bool) {
+	if field, ok := queryField(e.x); ok {
+		if value, ok := queryValue(e.y); ok {
+			op := e.op
+			if op == "=" {
+				op = "=="
+			}
+			return queryComparison{field: field, op: op, value: value}, true
+		}
+	}
+	if field, ok := queryField(e.y); ok {
+		if value, ok := queryValue(e.x); ok {
+			return queryComparison{field: field, op: flippedOps[e.op], value: value}, true
+		}
+	}
+	return queryComparison{}, false
+}
+
+// queryField returns the field path of the variable, `x.y` for the selector.
+func queryField(e Evaluator) (string, bool) {
+	switch e := e.(type) {
+	case *lockupVariableEvaluator:
+		return e.name, true
+	case *selectorEvaluator:
+		x, ok := queryField(e.x)
+		if !ok {
+			return "", false
+		}
+		return x + "." + e.name, true
+	case *parenEvaluator:
+		return queryField(e.x)
+	default:
+		return "", false
+	}
+}
+
+func queryValue(e Evaluator) (interface{}, bool) {
+	switch e := e.(type) {
+	case nilEvaluator:
+		return nil, true
+	case *realNumericLiteralEvaluator:
+		return e.value, true
+	case *stringLiteralEvaluator:
+		return e.str, true
+	case *parenEvaluator:
+		return queryValue(e.x)
+	default:
+		return nil, false
+	}
+}
+
+// queryStringCall returns the field and the string literal of the string functions, like regexp_match(name, `^a`).
+func queryStringCall(e *callEvaluator) (string, string, bool) {
+	if len(e.args) != 2 {
+		return "", "", false
+	}
+	field, ok := queryField(e.args[0])
+	if !ok {
+		return "", "", false
+	}
+	lit, ok := e.args[1].(*stringLiteralEvaluator)
+	if !ok {
+		return "", "", false
+	}
+	return field, lit.str, true
+}
+
+// flattenLogical returns the operands of the successive logical operators of the same op, like a, b and c of `a && b && c`.
+func flattenLogical(e Evaluator, op string) []Evaluator {
+	if p, ok := e.(*parenEvaluator); ok {
+		e = p.x
+	}
+	if l, ok := e.(*logicalEvaluator); ok && l.op == op {
+		return append(flattenLogical(l.x, op), flattenLogical(l.y, op)...)
+	}
+	return []Evaluator{e}
+}
+
+// inValues returns the field and the values if all operands are the equalities of the same field.
+func inValues(operands []Evaluator) (string, []interface{}, bool) {
+	var field string
+	values := make([]interface{}, 0, len(operands))
+	for _, operand := range operands {
+		if p, ok := operand.(*parenEvaluator); ok {
+			operand = p.x
+		}
+		c, ok := operand.(*comparativeEvaluator)
+		if !ok {
+			return "", nil, false
+		}
+		qc, ok := newQueryComparison(c)
+		if !ok || qc.op != "==" || qc.value == nil || (field != "" && qc.field != field) {
+			return "", nil, false
+		}
+		field = qc.field
+		values = append(values, qc.value)
+	}
+	return field, values, true
+}
+
+func toMongoFilter(e Evaluator) (map[string]interface{}, error) {
+	unsupported := func() error {
+		return fmt.Errorf("ToMongoFilter(`%s`) %w", e, ErrNoQueryEquivalent)
+	}
+	switch e := e.(type) {
+	case *parenEvaluator:
+		return toMongoFilter(e.x)
+	case *lockupVariableEvaluator, *selectorEvaluator:
+		field, _ := queryField(e)
+		return map[string]interface{}{field: true}, nil
+	case *unaryEvaluator:
+		if e.op != "!" {
+			return nil, unsupported()
+		}
+		x, err := toMongoFilter(e.x)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"$nor": []interface{}{x}}, nil
+	case *logicalEvaluator:
+		operands := flattenLogical(e, e.op)
+		if e.op == "||" {
+			if field, values, ok := inValues(operands); ok {
+				return map[string]interface{}{field: map[string]interface{}{"$in": values}}, nil
+			}
+		}
+		filters := make([]interface{}, 0, len(operands))
+		for _, operand := range operands {
+			f, err := toMongoFilter(operand)
+			if err != nil {
+				return nil, err
+			}
+			filters = append(filters, f)
+		}
+		if e.op == "||" {
+			return map[string]interface{}{"$or": filters}, nil
+		}
+		return map[string]interface{}{"$and": filters}, nil
+	case *comparativeEvaluator:
+		qc, ok := newQueryComparison(e)
+		if !ok || (qc.value == nil && qc.op != "==" && qc.op != "!=") {
+			return nil, unsupported()
+		}
+		ops := map[string]string{"==": "$eq", "!=": "$ne", "<": "$lt", "<=": "$lte", ">": "$gt", ">=": "$gte"}
+		return map[string]interface{}{qc.field: map[string]interface{}{ops[qc.op]: qc.value}}, nil
+	case *callEvaluator:
+		field, s, ok := queryStringCall(e)
+		if !ok {
+			return nil, unsupported()
+		}
+		var pattern string
+		switch e.funcName {
+		case "regexp_match":
+			pattern = s
+		case "string_contains":
+			pattern = regexp.QuoteMeta(s)
+		case "has_prefix":
+			pattern = "^" + regexp.QuoteMeta(s)
+		case "has_suffix":
+			pattern = regexp.QuoteMeta(s) + "$"
+		default:
+			return nil, unsupported()
+		}
+		return map[string]interface{}{field: map[string]interface{}{"$regex": pattern}}, nil
+	default:
+		return nil, unsupported()
+	}
+}
+
+func toElasticsearchQuery(e Evaluator) (map[string]interface{}, error) {
+	unsupported := func() error {
+		return fmt.Errorf("ToElasticsearchQuery(`%s`) %w", e, ErrNoQueryEquivalent)
+	}
+	boolQuery := func(clause string, queries ...interface{}) map[string]interface{} {
+		return map[string]interface{}{"bool": map[string]interface{}{clause: queries}}
+	}
+	switch e := e.(type) {
+	case *parenEvaluator:
+		return toElasticsearchQuery(e.x)
+	case *lockupVariableEvaluator, *selectorEvaluator:
+		field, _ := queryField(e)
+		return map[string]interface{}{"term": map[string]interface{}{field: true}}, nil
+	case *unaryEvaluator:
+		if e.op != "!" {
+			return nil, unsupported()
+		}
+		x, err := toElasticsearchQuery(e.x)
+		if err != nil {
+			return nil, err
+		}
+		return boolQuery("must_not", x), nil
+	case *logicalEvaluator:
+		operands := flattenLogical(e, e.op)
+		if e.op == "||" {
+			if field, values, ok := inValues(operands); ok {
+				return map[string]interface{}{"terms": map[string]interface{}{field: values}}, nil
+			}
+		}
+		queries := make([]interface{}, 0, len(operands))
+		for _, operand := range operands {
+			q, err := toElasticsearchQuery(operand)
+			if err != nil {
+				return nil, err
+			}
+			queries = append(queries, q)
+		}
+		if e.op == "||" {
+			q := boolQuery("should", queries...)
+			q["bool"].(map[string]interface{})["minimum_should_match"] = 1
+			return q, nil
+		}
+		return boolQuery("filter", queries...), nil
+	case *comparativeEvaluator:
+		qc, ok := newQueryComparison(e)
+		if !ok {
+			return nil, unsupported()
+		}
+		if qc.value == nil {
+			exists := map[string]interface{}{"exists": map[string]interface{}{"field": qc.field}}
+			switch qc.op {
+			case "==":
+				return boolQuery("must_not", exists), nil
+			case "!=":
+				return exists, nil
+			default:
+				return nil, unsupported()
+			}
+		}
+		term := map[string]interface{}{"term": map[string]interface{}{qc.field: qc.value}}
+		switch qc.op {
+		case "==":
+			return term, nil
+		case "!=":
+			return boolQuery("must_not", term), nil
+		}
+		ops := map[string]string{"<": "lt", "<=": "lte", ">": "gt", ">=": "gte"}
+		return map[string]interface{}{
+			"range": map[string]interface{}{qc.field: map[string]interface{}{ops[qc.op]: qc.value}},
+		}, nil
+	case *callEvaluator:
+		field, s, ok := queryStringCall(e)
+		if !ok {
+			return nil, unsupported()
+		}
+		switch e.funcName {
+		case "regexp_match":
+			return map[string]interface{}{"regexp": map[string]interface{}{field: lucenePattern(s)}}, nil
+		case "string_contains":
+			wildcard := "*" + strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`).Replace(s) + "*"
+			return map[string]interface{}{"wildcard": map[string]interface{}{field: wildcard}}, nil
+		case "has_prefix":
+			return map[string]interface{}{"prefix": map[string]interface{}{field: s}}, nil
+		default:
+			return nil, unsupported()
+		}
+	default:
+		return nil, unsupported()
+	}
+}
+
+// lucenePattern converts the unanchored pattern to the pattern of Lucene which matches the whole value, like `.*(a|b).*`.
+// The branches of the alternation are converted one by one if any of them is anchored, like `^a|b` to `(a).*|.*(b).*`.
+func lucenePattern(pattern string) string {
+	branches := splitAlternation(pattern)
+	if len(branches) == 1 {
+		return luceneBranch(pattern)
+	}
+	for _, branch := range branches {
+		if isAnchoredBranch(branch) {
+			for i, branch := range branches {
+				branches[i] = luceneBranch(branch)
+			}
+			return strings.Join(branches, "|")
+		}
+	}
+	return luceneBranch(pattern)
+}
+
+func isAnchoredBranch(pattern string) bool {
+	return strings.HasPrefix(pattern, "^") || (strings.HasSuffix(pattern, "$") && !strings.HasSuffix(pattern, `\$`))
+}
+
+func luceneBranch(pattern string) string {
+	prefix, suffix := ".*", ".*"
+	if strings.HasPrefix(pattern, "^") {
+		pattern, prefix = pattern[1:], ""
+	}
+	if strings.HasSuffix(pattern, "$") && !strings.HasSuffix(pattern, `\$`) {
+		pattern, suffix = pattern[:len(pattern)-1], ""
+	}
+	return prefix + "(" + pattern + ")" + suffix
+}
+
+// splitAlternation splits the pattern by `|` out of the groups and the character classes.
+func splitAlternation(pattern string) []string {
+	var branches []string
+	depth, inClass, last := 0, false, 0
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '\\':
+			i++
+		case inClass:
+			inClass = c != ']'
+		case c == '[':
+			inClass = true
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == '|' && depth == 0:
+			branches = append(branches, pattern[last:i])
+			last = i + 1
+		}
+	}
+	return append(branches, pattern[last:])
+}
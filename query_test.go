@@ -0,0 +1,381 @@
+package evaluator_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/mashiike/evaluator"
+	"github.com/stretchr/testify/require"
+)
+
+var queryTestDocuments = []evaluator.Variables{
+	{"cpu": 95.0, "host": "web-1", "env": "prod", "count": 3.0, "enabled": true, "owner": nil, "meta": map[string]interface{}{"zone": "a"}},
+	{"cpu": 40.0, "host": "web-2", "env": "staging", "count": 5.0, "enabled": false, "owner": "alice", "meta": map[string]interface{}{"zone": "b"}},
+	{"cpu": 5.0, "host": "db-1", "env": "prod", "count": 1.0, "enabled": true, "owner": "bob", "meta": map[string]interface{}{"zone": "b"}},
+	{"cpu": 90.0, "host": "db-2*", "env": "dev", "count": 0.0, "enabled": true, "owner": nil, "meta": map[string]interface{}{"zone": "a"}},
+}
+
+func TestQueryConverters(t *testing.T) {
+	exprs := []string{
+		"cpu > 90",
+		"cpu >= 90 && env == `prod`",
+		"90 < cpu",
+		"env == `prod` || env == `staging`",
+		"!(cpu > 50) || host == `web-2`",
+		"regexp_match(host, `^web-[0-9]+`) && cpu >= 0",
+		"regexp_match(host, `b-`) || cpu < 0",
+		"regexp_match(host, `-2|eb`) || cpu < 0",
+		"regexp_match(host, `^db|1$`) && cpu >= 0",
+		"regexp_match(host, `(w|d)b-[12]`) && cpu >= 0",
+		"string_contains(host, `b-`) && meta.zone != `a`",
+		"string_contains(host, `2*`) && cpu >= 0",
+		"has_prefix(host, `web`) && count <= 3",
+		"enabled && cpu < 60",
+		"(cpu > 90 || cpu < 10) && (env == `prod`)",
+		"cpu == 40 || cpu == 5 || env == `dev`",
+	}
+	for _, expr := range exprs {
+		t.Run(expr, func(t *testing.T) {
+			e, err := evaluator.New(expr)
+			require.NoError(t, err)
+			c, ok := e.AsComparator()
+			require.True(t, ok)
+			filter, err := evaluator.ToMongoFilter(c)
+			require.NoError(t, err)
+			filter = roundTripJSON(t, filter)
+			query, err := evaluator.ToElasticsearchQuery(c)
+			require.NoError(t, err)
+			query = roundTripJSON(t, query)
+			for i, doc := range queryTestDocuments {
+				expected, err := c.Compare(doc)
+				require.NoError(t, err)
+				require.Equal(t, expected, mongoMatch(filter, doc), "documents[%d] filter %v", i, filter)
+				require.Equal(t, expected, esMatch(query, doc), "documents[%d] query %v", i, query)
+			}
+		})
+	}
+}
+
+func TestQueryConvertersDocument(t *testing.T) {
+	e, err := evaluator.New("env == `prod` || env == `staging`")
+	require.NoError(t, err)
+	c, _ := e.AsComparator()
+	filter, err := evaluator.ToMongoFilter(c)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"env": map[string]interface{}{"$in": []interface{}{"prod", "staging"}},
+	}, filter)
+	query, err := evaluator.ToElasticsearchQuery(c)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"terms": map[string]interface{}{"env": []interface{}{"prod", "staging"}},
+	}, query)
+
+	e, err = evaluator.New("cpu > 90 && regexp_match(host, `^web`)")
+	require.NoError(t, err)
+	c, _ = e.AsComparator()
+	filter, err = evaluator.ToMongoFilter(c)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"$and": []interface{}{
+			map[string]interface{}{"cpu": map[string]interface{}{"$gt": 90.0}},
+			map[string]interface{}{"host": map[string]interface{}{"$regex": "^web"}},
+		},
+	}, filter)
+	query, err = evaluator.ToElasticsearchQuery(c)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"bool": map[string]interface{}{
+			"filter": []interface{}{
+				map[string]interface{}{"range": map[string]interface{}{"cpu": map[string]interface{}{"gt": 90.0}}},
+				map[string]interface{}{"regexp": map[string]interface{}{"host": "(web).*"}},
+			},
+		},
+	}, query)
+
+	e, err = evaluator.New("owner == nil || nil != group")
+	require.NoError(t, err)
+	c, _ = e.AsComparator()
+	filter, err = evaluator.ToMongoFilter(c)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"$or": []interface{}{
+			map[string]interface{}{"owner": map[string]interface{}{"$eq": nil}},
+			map[string]interface{}{"group": map[string]interface{}{"$ne": nil}},
+		},
+	}, filter)
+	query, err = evaluator.ToElasticsearchQuery(c)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"bool": map[string]interface{}{
+			"should": []interface{}{
+				map[string]interface{}{"bool": map[string]interface{}{"must_not": []interface{}{
+					map[string]interface{}{"exists": map[string]interface{}{"field": "owner"}},
+				}}},
+				map[string]interface{}{"exists": map[string]interface{}{"field": "group"}},
+			},
+			"minimum_should_match": 1,
+		},
+	}, query)
+}
+
+func TestQueryConvertersFail(t *testing.T) {
+	cases := []struct {
+		expr     string
+		expected string
+	}{
+		{expr: "cpu + 1 > 2", expected: "(`cpu + 1 > 2`) has no query equivalent"},
+		{expr: "sum(values) > 1 && x > 1", expected: "(`sum(values) > 1`) has no query equivalent"},
+		{expr: "regexp_match(host, pattern) && x > 1", expected: "(`regexp_match(host, pattern)`) has no query equivalent"},
+		{expr: "cpu > nil", expected: "(`cpu > nil`) has no query equivalent"},
+		{expr: "x == y", expected: "(`x == y`) has no query equivalent"},
+	}
+	for _, c := range cases {
+		t.Run(c.expr, func(t *testing.T) {
+			e, err := evaluator.New(c.expr)
+			require.NoError(t, err)
+			comparator, ok := e.AsComparator()
+			require.True(t, ok)
+			_, err = evaluator.ToMongoFilter(comparator)
+			require.EqualError(t, err, "ToMongoFilter"+c.expected)
+			require.True(t, errors.Is(err, evaluator.ErrNoQueryEquivalent))
+			_, err = evaluator.ToElasticsearchQuery(comparator)
+			require.EqualError(t, err, "ToElasticsearchQuery"+c.expected)
+		})
+	}
+}
+
+func roundTripJSON(t *testing.T, v map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	bs, err := json.Marshal(v)
+	require.NoError(t, err)
+	var ret map[string]interface{}
+	require.NoError(t, json.Unmarshal(bs, &ret))
+	return ret
+}
+
+// lookupPath returns the value of the dotted path of the document.
+func lookupPath(doc map[string]interface{}, path string) interface{} {
+	var v interface{} = doc
+	for _, name := range strings.Split(path, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[name]
+	}
+	return v
+}
+
+// compareQueryValues compares the values of the same type like the document stores, ok is false for the different types.
+func compareQueryValues(v1, v2 interface{}) (int, bool) {
+	switch v1 := v1.(type) {
+	case float64:
+		v2, ok := v2.(float64)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case v1 < v2:
+			return -1, true
+		case v1 > v2:
+			return 1, true
+		default:
+			return 0, true
+		}
+	case string:
+		v2, ok := v2.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(v1, v2), true
+	case bool:
+		v2, ok := v2.(bool)
+		return 0, ok && v1 == v2
+	case nil:
+		return 0, v2 == nil
+	default:
+		return 0, false
+	}
+}
+
+func equalQueryValues(v1, v2 interface{}) bool {
+	c, ok := compareQueryValues(v1, v2)
+	return ok && c == 0
+}
+
+// mongoMatch is a minimal matcher of the MongoDB query filter for the tests.
+func mongoMatch(filter map[string]interface{}, doc map[string]interface{}) bool {
+	for key, cond := range filter {
+		switch key {
+		case "$and", "$or", "$nor":
+			n := 0
+			for _, sub := range cond.([]interface{}) {
+				if mongoMatch(sub.(map[string]interface{}), doc) {
+					n++
+				}
+			}
+			if (key == "$and" && n != len(cond.([]interface{}))) || (key == "$or" && n == 0) || (key == "$nor" && n != 0) {
+				return false
+			}
+			continue
+		}
+		v := lookupPath(doc, key)
+		ops, ok := cond.(map[string]interface{})
+		if !ok {
+			ops = map[string]interface{}{"$eq": cond}
+		}
+		for op, operand := range ops {
+			c, comparable := compareQueryValues(v, operand)
+			var matched bool
+			switch op {
+			case "$eq":
+				matched = comparable && c == 0
+			case "$ne":
+				matched = !(comparable && c == 0)
+			case "$gt":
+				matched = comparable && c > 0
+			case "$gte":
+				matched = comparable && c >= 0
+			case "$lt":
+				matched = comparable && c < 0
+			case "$lte":
+				matched = comparable && c <= 0
+			case "$in":
+				for _, operand := range operand.([]interface{}) {
+					matched = matched || equalQueryValues(v, operand)
+				}
+			case "$regex":
+				s, ok := v.(string)
+				matched = ok && regexp.MustCompile(operand.(string)).MatchString(s)
+			default:
+				panic(fmt.Sprintf("unknown operator %s", op))
+			}
+			if !matched {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// esMatch is a minimal matcher of the Elasticsearch query for the tests, the fields are assumed to be keyword.
+func esMatch(query map[string]interface{}, doc map[string]interface{}) bool {
+	for kind, body := range query {
+		body := body.(map[string]interface{})
+		switch kind {
+		case "bool":
+			count := func(clause string) (int, int) {
+				queries, _ := body[clause].([]interface{})
+				n := 0
+				for _, q := range queries {
+					if esMatch(q.(map[string]interface{}), doc) {
+						n++
+					}
+				}
+				return n, len(queries)
+			}
+			if n, total := count("filter"); n != total {
+				return false
+			}
+			if n, _ := count("must_not"); n != 0 {
+				return false
+			}
+			if n, total := count("should"); total > 0 && n < 1 {
+				return false
+			}
+		case "exists":
+			if lookupPath(doc, body["field"].(string)) == nil {
+				return false
+			}
+		default:
+			for field, operand := range body {
+				v := lookupPath(doc, field)
+				s, isString := v.(string)
+				var matched bool
+				switch kind {
+				case "term":
+					matched = equalQueryValues(v, operand)
+				case "terms":
+					for _, operand := range operand.([]interface{}) {
+						matched = matched || equalQueryValues(v, operand)
+					}
+				case "range":
+					matched = true
+					for op, bound := range operand.(map[string]interface{}) {
+						c, ok := compareQueryValues(v, bound)
+						matched = matched && ok && map[string]bool{"gt": c > 0, "gte": c >= 0, "lt": c < 0, "lte": c <= 0}[op]
+					}
+				case "regexp":
+					matched = isString && regexp.MustCompile("^(?:"+operand.(string)+")$").MatchString(s)
+				case "prefix":
+					matched = isString && strings.HasPrefix(s, operand.(string))
+				case "wildcard":
+					matched = isString && wildcardRegexp(operand.(string)).MatchString(s)
+				default:
+					panic(fmt.Sprintf("unknown query %s", kind))
+				}
+				if !matched {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+func wildcardRegexp(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+func TestQueryConvertersWithCostBudget(t *testing.T) {
+	e, err := evaluator.New("cpu > 90 && env == `prod`", evaluator.WithCostBudget(100))
+	require.NoError(t, err)
+	c, ok := e.AsComparator()
+	require.True(t, ok)
+	mongo, err := evaluator.ToMongoFilter(c)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"$and": []interface{}{
+		map[string]interface{}{"cpu": map[string]interface{}{"$gt": 90.0}},
+		map[string]interface{}{"env": map[string]interface{}{"$eq": "prod"}},
+	}}, mongo)
+	es, err := evaluator.ToElasticsearchQuery(c)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"bool": map[string]interface{}{"filter": []interface{}{
+		map[string]interface{}{"range": map[string]interface{}{"cpu": map[string]interface{}{"gt": 90.0}}},
+		map[string]interface{}{"term": map[string]interface{}{"env": "prod"}},
+	}}}, es)
+}
+
+func TestQueryConvertersWithRecover(t *testing.T) {
+	e, err := evaluator.New("cpu > 90", evaluator.WithCostBudget(100))
+	require.NoError(t, err)
+	c, ok := evaluator.Recover(e).AsComparator()
+	require.True(t, ok)
+	mongo, err := evaluator.ToMongoFilter(c)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"cpu": map[string]interface{}{"$gt": 90.0}}, mongo)
+	es, err := evaluator.ToElasticsearchQuery(c)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"range": map[string]interface{}{"cpu": map[string]interface{}{"gt": 90.0}}}, es)
+}
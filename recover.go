@@ -0,0 +1,96 @@
+package evaluator
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError is an error converted from a panic in the parsing or the evaluation, which means a bug of this package.
+type PanicError struct {
+	// Value is the value given to panic.
+	Value interface{}
+	// Stack is the stack trace of the panic.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+func newPanicError(v interface{}) *PanicError {
+	return &PanicError{
+		Value: v,
+		Stack: debug.Stack(),
+	}
+}
+
+// Recover returns the evaluator which converts a panic in the evaluation into PanicError,
+// so that a bug of this package does not crash the process evaluating the expressions given by the users.
+// New also converts a panic in the parsing into PanicError.
+func Recover(e Evaluator) Evaluator {
+	if _, ok := e.(*recoverEvaluator); ok {
+		return e
+	}
+	return &recoverEvaluator{x: e}
+}
+
+type recoverEvaluator struct {
+	x Evaluator
+}
+
+func (e *recoverEvaluator) Eval(vars Variables) (ret interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			ret, err = nil, fmt.Errorf("Eval(`%s`) %w", e, newPanicError(r))
+		}
+	}()
+	return e.x.Eval(vars)
+}
+
+func (e *recoverEvaluator) Strict(v bool) {
+	e.x.Strict(v)
+}
+
+func (e *recoverEvaluator) AsComparator() (Comparator, bool) {
+	c, ok := e.x.AsComparator()
+	if !ok {
+		return nil, false
+	}
+	return &recoverComparator{x: c}, true
+}
+
+func (e *recoverEvaluator) String() string {
+	return e.x.String()
+}
+
+func (e *recoverEvaluator) children() []Evaluator { return []Evaluator{e.x} }
+
+func (e *recoverEvaluator) withChildren(children []Evaluator) Evaluator {
+	return &recoverEvaluator{x: children[0]}
+}
+
+type recoverComparator struct {
+	x Comparator
+}
+
+func (c *recoverComparator) Compare(vars Variables) (ret bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			ret, err = false, fmt.Errorf("Eval(`%s`) %w", c, newPanicError(r))
+		}
+	}()
+	return c.x.Compare(vars)
+}
+
+func (c *recoverComparator) CompareDetailed(vars Variables) (ret bool, conds []Condition, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			ret, conds, err = false, nil, fmt.Errorf("Eval(`%s`) %w", c, newPanicError(r))
+		}
+	}()
+	return CompareDetailed(c.x, vars)
+}
+
+func (c *recoverComparator) String() string {
+	return c.x.String()
+}
@@ -0,0 +1,70 @@
+package evaluator_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mashiike/evaluator"
+	"github.com/stretchr/testify/require"
+)
+
+// panicEvaluator is an Evaluator with a bug.
+type panicEvaluator struct{}
+
+func (panicEvaluator) Eval(evaluator.Variables) (interface{}, error) { panic("boom") }
+
+func (panicEvaluator) Strict(bool) {}
+
+func (e panicEvaluator) AsComparator() (evaluator.Comparator, bool) { return e, true }
+
+func (panicEvaluator) Compare(evaluator.Variables) (bool, error) { panic("boom") }
+
+func (panicEvaluator) CompareDetailed(evaluator.Variables) (bool, []evaluator.Condition, error) {
+	var conds []evaluator.Condition
+	_ = conds[0]
+	return false, nil, nil
+}
+
+func (panicEvaluator) String() string { return "panic()" }
+
+func TestRecover(t *testing.T) {
+	e := evaluator.Recover(panicEvaluator{})
+	require.Equal(t, e, evaluator.Recover(e))
+	require.Equal(t, "panic()", e.String())
+
+	_, err := e.Eval(nil)
+	require.EqualError(t, err, "Eval(`panic()`) panic: boom")
+	var panicErr *evaluator.PanicError
+	require.True(t, errors.As(err, &panicErr))
+	require.Equal(t, "boom", panicErr.Value)
+	require.NotEmpty(t, panicErr.Stack)
+
+	c, ok := e.AsComparator()
+	require.True(t, ok)
+	_, err = c.Compare(nil)
+	require.EqualError(t, err, "Eval(`panic()`) panic: boom")
+	_, _, err = evaluator.CompareDetailed(c, nil)
+	require.EqualError(t, err, "Eval(`panic()`) panic: runtime error: index out of range [0] with length 0")
+}
+
+func TestRecoverPassThrough(t *testing.T) {
+	e, err := evaluator.New("var1 > 1")
+	require.NoError(t, err)
+	e = evaluator.Recover(e)
+	e.Strict(true)
+	_, err = e.Eval(evaluator.Variables{})
+	require.True(t, evaluator.IsVariableNotFound(err))
+	c, ok := e.AsComparator()
+	require.True(t, ok)
+	ret, err := c.Compare(evaluator.Variables{"var1": 2})
+	require.NoError(t, err)
+	require.True(t, ret)
+	require.Equal(t, []string{"var1"}, evaluator.ReferencedVariables(e))
+
+	_, ok = evaluator.Recover(evaluator.Recover(e)).AsComparator()
+	require.True(t, ok)
+	e, err = evaluator.New("var1 + 1")
+	require.NoError(t, err)
+	_, ok = evaluator.Recover(e).AsComparator()
+	require.False(t, ok)
+}
@@ -0,0 +1,330 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rule is a named expression of RuleSet, the expression is expected to be evaluated as bool.
+type Rule struct {
+	// Name is the unique name of the rule in the RuleSet.
+	Name string
+	// Expr is the expression of the rule.
+	Expr string
+	// Priority orders the rules, the higher priority is evaluated first.
+	Priority int
+	// Metadata is the arbitrary data of the rule, like the severity.
+	Metadata map[string]interface{}
+	// Enabled is false if the rule is skipped by Match.
+	Enabled bool
+
+	evaluator Evaluator
+	compiled  Evaluator
+}
+
+// Evaluator returns the parsed expression of the rule.
+func (r *Rule) Evaluator() Evaluator {
+	return r.evaluator
+}
+
+// ruleDefinition is the format of the rules in YAML and JSON, Enabled is true if omitted.
+type ruleDefinition struct {
+	Name     string                 `json:"name" yaml:"name"`
+	Expr     string                 `json:"expr" yaml:"expr"`
+	Priority int                    `json:"priority" yaml:"priority"`
+	Metadata map[string]interface{} `json:"metadata" yaml:"metadata"`
+	Enabled  *bool                  `json:"enabled" yaml:"enabled"`
+}
+
+type ruleSetDefinition struct {
+	Rules []ruleDefinition `json:"rules" yaml:"rules"`
+}
+
+// MatchMode decides how many rules RuleSet.Match returns.
+type MatchMode int
+
+// The match modes of RuleSet
+const (
+	// MatchAll returns all matched rules.
+	MatchAll MatchMode = iota
+	// MatchFirst returns the first matched rule in the priority order.
+	MatchFirst
+)
+
+// RuleError is an error of the evaluation of a rule.
+type RuleError struct {
+	Rule string
+	Err  error
+}
+
+func (e *RuleError) Error() string {
+	return fmt.Sprintf("rule `%s`: %s", e.Rule, e.Err)
+}
+
+func (e *RuleError) Unwrap() error {
+	return e.Err
+}
+
+// RuleSetError is the errors of the rules which failed in RuleSet.Match.
+type RuleSetError struct {
+	Errors []*RuleError
+}
+
+func (e *RuleSetError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the first error, for errors.Is and errors.As.
+func (e *RuleSetError) Unwrap() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e.Errors[0]
+}
+
+// RuleSet is a set of rules evaluated together against one Variables.
+// The rules are ordered by the priority, the higher priority first and the defined order for the same priority.
+// The same sub expressions among the rules are evaluated once for each Match.
+type RuleSet struct {
+	rules []*Rule
+}
+
+// NewRuleSet parses the expressions of the rules with the options of New to create a RuleSet.
+func NewRuleSet(rules []Rule, opts ...Option) (*RuleSet, error) {
+	rs := &RuleSet{
+		rules: make([]*Rule, 0, len(rules)),
+	}
+	names := make(map[string]bool, len(rules))
+	for i, rule := range rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("rules[%d] name is required", i)
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("rule `%s` is duplicated", rule.Name)
+		}
+		names[rule.Name] = true
+		e, err := New(rule.Expr, opts...)
+		if err != nil {
+			return nil, &RuleError{Rule: rule.Name, Err: err}
+		}
+		r := rule
+		r.evaluator = e
+		rs.rules = append(rs.rules, &r)
+	}
+	sort.SliceStable(rs.rules, func(i, j int) bool {
+		return rs.rules[i].Priority > rs.rules[j].Priority
+	})
+	rs.compile()
+	return rs, nil
+}
+
+// LoadRuleSet decodes the rules from JSON or YAML, like:
+//
+//	rules:
+//	  - name: high_cpu
+//	    expr: cpu_usage > 90
+//	    priority: 10
+//	    metadata:
+//	      severity: critical
+//	  - name: low_disk
+//	    expr: disk_free < 10
+//	    enabled: false
+func LoadRuleSet(r io.Reader, opts ...Option) (*RuleSet, error) {
+	bs, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var def ruleSetDefinition
+	if json.Valid(bs) {
+		err = json.Unmarshal(bs, &def)
+	} else {
+		err = yaml.Unmarshal(bs, &def)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decode rule set: %w", err)
+	}
+	rules := make([]Rule, 0, len(def.Rules))
+	for _, d := range def.Rules {
+		rule := Rule{
+			Name:     d.Name,
+			Expr:     d.Expr,
+			Priority: d.Priority,
+			Metadata: d.Metadata,
+			Enabled:  true,
+		}
+		if d.Enabled != nil {
+			rule.Enabled = *d.Enabled
+		}
+		rules = append(rules, rule)
+	}
+	return NewRuleSet(rules, opts...)
+}
+
+// LoadRuleSetFile loads the rules from the JSON or YAML file.
+func LoadRuleSetFile(path string, opts ...Option) (*RuleSet, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	rs, err := LoadRuleSet(f, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	return rs, nil
+}
+
+// Rules returns the rules in the priority order.
+func (rs *RuleSet) Rules() []*Rule {
+	ret := make([]*Rule, len(rs.rules))
+	copy(ret, rs.rules)
+	return ret
+}
+
+// Strict sets all rules to run in strict mode.
+func (rs *RuleSet) Strict(v bool) {
+	for _, rule := range rs.rules {
+		rule.evaluator.Strict(v)
+		rule.compiled.Strict(v)
+	}
+}
+
+// Match evaluates the enabled rules, and returns the rules evaluated true in the priority order.
+// The rules which failed are not matched, and their errors are returned as *RuleSetError with the matched rules.
+// In MatchFirst mode, the rules after the first matched rule are not evaluated.
+func (rs *RuleSet) Match(vars Variables, mode MatchMode) ([]*Rule, error) {
+	scope := childScope(vars, 1)
+	scope[memoVariableName] = make(memoCache)
+	var matched []*Rule
+	var errs []*RuleError
+	for _, rule := range rs.rules {
+		if !rule.Enabled {
+			continue
+		}
+		v, err := rule.compiled.Eval(scope)
+		if err != nil {
+			errs = append(errs, &RuleError{Rule: rule.Name, Err: err})
+			continue
+		}
+		b, ok := isBool(v)
+		if !ok {
+			errs = append(errs, &RuleError{Rule: rule.Name, Err: fmt.Errorf("Eval(`%s`) v[%v]::%T is not bool", rule.evaluator, v, v)})
+			continue
+		}
+		if !b {
+			continue
+		}
+		matched = append(matched, rule)
+		if mode == MatchFirst {
+			break
+		}
+	}
+	if len(errs) > 0 {
+		return matched, &RuleSetError{Errors: errs}
+	}
+	return matched, nil
+}
+
+// compile rebuilds the expressions of the rules so that the same sub expressions are shared and memoized.
+func (rs *RuleSet) compile() {
+	counts := make(map[string]int)
+	for _, rule := range rs.rules {
+		countSubExprs(rule.evaluator, counts)
+	}
+	shared := make(map[string]Evaluator)
+	for _, rule := range rs.rules {
+		rule.compiled = shareSubExprs(rule.evaluator, counts, shared)
+	}
+}
+
+func subExprKey(e Evaluator) string {
+	return fmt.Sprintf("%T:%s", e, e)
+}
+
+// isMemoizable reports whether the evaluator is worth to be memoized, the leaves are cheaper than the memo.
+func isMemoizable(e Evaluator) bool {
+	switch e.(type) {
+	case nilEvaluator, *lockupVariableEvaluator, *realNumericLiteralEvaluator, *stringLiteralEvaluator, *lambdaEvaluator:
+		return false
+	default:
+		return true
+	}
+}
+
+func countSubExprs(e Evaluator, counts map[string]int) {
+	if _, ok := e.(*lambdaEvaluator); ok {
+		return
+	}
+	counts[subExprKey(e)]++
+	for _, child := range childrenOf(e) {
+		countSubExprs(child, counts)
+	}
+}
+
+// shareSubExprs returns the evaluator whose sub expressions are replaced by the shared ones,
+// the lambda bodies are kept as is, because they depend on the parameters.
+func shareSubExprs(e Evaluator, counts map[string]int, shared map[string]Evaluator) Evaluator {
+	if _, ok := e.(*lambdaEvaluator); ok {
+		return e
+	}
+	key := subExprKey(e)
+	if s, ok := shared[key]; ok {
+		return s
+	}
+	ret := e
+	if n, ok := e.(node); ok {
+		children := n.children()
+		if len(children) > 0 {
+			replaced := make([]Evaluator, 0, len(children))
+			for _, child := range children {
+				replaced = append(replaced, shareSubExprs(child, counts, shared))
+			}
+			ret = n.withChildren(replaced)
+		}
+	}
+	if counts[key] > 1 && isMemoizable(e) {
+		ret = &memoEvaluator{Evaluator: ret}
+	}
+	shared[key] = ret
+	return ret
+}
+
+// memoVariableName is the reserved name of the memo in Variables, it can not be referenced by the expressions.
+const memoVariableName = "\x00memo"
+
+type memoCache map[*memoEvaluator]memoResult
+
+type memoResult struct {
+	value interface{}
+	err   error
+}
+
+// memoEvaluator caches the result in the memo of the Variables.
+type memoEvaluator struct {
+	Evaluator
+}
+
+func (e *memoEvaluator) Eval(vars Variables) (interface{}, error) {
+	v, _ := vars.Lookup(memoVariableName)
+	cache, ok := v.(memoCache)
+	if !ok {
+		return e.Evaluator.Eval(vars)
+	}
+	if ret, ok := cache[e]; ok {
+		return ret.value, ret.err
+	}
+	v, err := e.Evaluator.Eval(vars)
+	cache[e] = memoResult{value: v, err: err}
+	return v, err
+}
@@ -0,0 +1,129 @@
+package evaluator_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mashiike/evaluator"
+	"github.com/stretchr/testify/require"
+)
+
+const testRuleSetYAML = `
+rules:
+  - name: warning_cpu
+    expr: cpu_usage > 80
+    priority: 1
+    metadata:
+      severity: warning
+  - name: critical_cpu
+    expr: cpu_usage > 80 && rate(cpu_usage, cpu_limit) > 0.95
+    priority: 10
+    metadata:
+      severity: critical
+  - name: low_disk
+    expr: disk_free < 10
+    priority: 1
+  - name: disabled
+    expr: cpu_usage >= 0
+    priority: 100
+    enabled: false
+  - name: busy
+    expr: rate(cpu_usage, cpu_limit) > 0.5 || any(procs, p -> p > 10)
+`
+
+func matchedNames(rules []*evaluator.Rule) []string {
+	names := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		names = append(names, rule.Name)
+	}
+	return names
+}
+
+func TestRuleSet(t *testing.T) {
+	rs, err := evaluator.LoadRuleSet(strings.NewReader(testRuleSetYAML))
+	require.NoError(t, err)
+	require.Equal(t, []string{"disabled", "critical_cpu", "warning_cpu", "low_disk", "busy"}, matchedNames(rs.Rules()))
+	require.Equal(t, "critical", rs.Rules()[1].Metadata["severity"])
+	require.False(t, rs.Rules()[0].Enabled)
+
+	cases := []struct {
+		name     string
+		vars     evaluator.Variables
+		mode     evaluator.MatchMode
+		expected []string
+	}{
+		{
+			name:     "all",
+			vars:     evaluator.Variables{"cpu_usage": 98, "cpu_limit": 100, "disk_free": 5, "procs": []interface{}{1}},
+			mode:     evaluator.MatchAll,
+			expected: []string{"critical_cpu", "warning_cpu", "low_disk", "busy"},
+		},
+		{
+			name:     "first",
+			vars:     evaluator.Variables{"cpu_usage": 90, "cpu_limit": 100, "disk_free": 5, "procs": []interface{}{1}},
+			mode:     evaluator.MatchFirst,
+			expected: []string{"warning_cpu"},
+		},
+		{
+			name:     "none",
+			vars:     evaluator.Variables{"cpu_usage": 10, "cpu_limit": 100, "disk_free": 50, "procs": []interface{}{1, 2}},
+			mode:     evaluator.MatchAll,
+			expected: []string{},
+		},
+		{
+			name:     "lambda",
+			vars:     evaluator.Variables{"cpu_usage": 10, "cpu_limit": 100, "disk_free": 50, "procs": []interface{}{1, 20}},
+			mode:     evaluator.MatchFirst,
+			expected: []string{"busy"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			matched, err := rs.Match(c.vars, c.mode)
+			require.NoError(t, err)
+			require.Equal(t, c.expected, matchedNames(matched))
+		})
+	}
+}
+
+func TestRuleSetError(t *testing.T) {
+	rs, err := evaluator.NewRuleSet([]evaluator.Rule{
+		{Name: "ratio", Expr: "var1 / var2 > 1", Priority: 2, Enabled: true},
+		{Name: "ratio_low", Expr: "var1 / var2 < 0.5", Priority: 1, Enabled: true},
+		{Name: "number", Expr: "var1 + 1", Enabled: true},
+		{Name: "positive", Expr: "var1 > 0", Enabled: true},
+	})
+	require.NoError(t, err)
+	matched, err := rs.Match(evaluator.Variables{"var1": 1, "var2": 0}, evaluator.MatchAll)
+	require.Equal(t, []string{"positive"}, matchedNames(matched))
+	var rsErr *evaluator.RuleSetError
+	require.True(t, errors.As(err, &rsErr))
+	require.Len(t, rsErr.Errors, 3)
+	require.EqualError(t, rsErr.Errors[0], "rule `ratio`: Eval(`var1 / var2 > 1`) Eval(`var1 / var2`) divide by 0")
+	require.EqualError(t, rsErr.Errors[1], "rule `ratio_low`: Eval(`var1 / var2 < 0.5`) Eval(`var1 / var2`) divide by 0")
+	require.EqualError(t, rsErr.Errors[2], "rule `number`: Eval(`var1 + 1`) v[2]::float64 is not bool")
+	require.True(t, evaluator.IsDivideByZero(err))
+
+	rs.Strict(true)
+	_, err = rs.Match(evaluator.Variables{"var1": 1}, evaluator.MatchFirst)
+	require.True(t, evaluator.IsVariableNotFound(err))
+
+	_, err = evaluator.NewRuleSet([]evaluator.Rule{{Name: "a", Expr: "var1 > 1"}, {Name: "a", Expr: "var1 > 2"}})
+	require.EqualError(t, err, "rule `a` is duplicated")
+	_, err = evaluator.NewRuleSet([]evaluator.Rule{{Name: "a", Expr: "var1 >"}})
+	require.Error(t, err)
+}
+
+func TestLoadRuleSetFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"rules": [{"name": "a", "expr": "var1 > 1", "metadata": {"team": "infra"}}]}`), 0o644))
+	rs, err := evaluator.LoadRuleSetFile(path)
+	require.NoError(t, err)
+	matched, err := rs.Match(evaluator.Variables{"var1": 2}, evaluator.MatchAll)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a"}, matchedNames(matched))
+	require.Equal(t, "infra", matched[0].Metadata["team"])
+}
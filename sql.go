@@ -0,0 +1,279 @@
+package evaluator
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SQLDialect is the dialect of SQL generated by ToSQL.
+type SQLDialect string
+
+// The dialects of ToSQL
+const (
+	// SQLDialectPostgres uses `$1` placeholders, `~` for regexp_match and strpos for string_contains.
+	SQLDialectPostgres SQLDialect = "postgres"
+	// SQLDialectSQLite uses `?` placeholders, `REGEXP` for regexp_match and instr for string_contains.
+	// REGEXP and the math functions need the extensions of SQLite.
+	SQLDialectSQLite SQLDialect = "sqlite"
+)
+
+// ToSQL translates the expression into a SQL condition for WHERE clause, and returns it with the parameters of the placeholders.
+// The literals are passed as the parameters, and the variables are the quoted identifiers, `x.y` is `"x"."y"`.
+// The constructs which have no SQL equivalent, like the list functions and the lambdas, are ErrNoSQLEquivalent.
+//
+// Note that SQL differs in NULL handling: the comparison with NULL is not false but NULL, so `NOT (x > 1)` does not match the rows where x is NULL.
+func ToSQL(e Evaluator, dialect SQLDialect) (string, []interface{}, error) {
+	switch dialect {
+	case SQLDialectPostgres, SQLDialectSQLite:
+	default:
+		return "", nil, fmt.Errorf("ToSQL: unknown dialect `%s`", dialect)
+	}
+	b := &sqlBuilder{dialect: dialect}
+	query, err := b.build(unwrap(e))
+	if err != nil {
+		return "", nil, err
+	}
+	return query, b.args, nil
+}
+
+type sqlBuilder struct {
+	dialect SQLDialect
+	args    []interface{}
+}
+
+func (b *sqlBuilder) param(v interface{}) string {
+	b.args = append(b.args, v)
+	if b.dialect == SQLDialectPostgres {
+		return fmt.Sprintf("$%d", len(b.args))
+	}
+	return "?"
+}
+
+func (b *sqlBuilder) unsupported(e Evaluator) error {
+	return fmt.Errorf("ToSQL(`%s`) %w", e, ErrNoSQLEquivalent)
+}
+
+func (b *sqlBuilder) build(e Evaluator) (string, error) {
+	switch e := e.(type) {
+	case nilEvaluator:
+		return "NULL", nil
+	case *realNumericLiteralEvaluator:
+		return b.param(e.value), nil
+	case *stringLiteralEvaluator:
+		return b.param(e.str), nil
+	case *lockupVariableEvaluator, *selectorEvaluator:
+		return b.identifier(e)
+	case *parenEvaluator:
+		x, err := b.build(e.x)
+		if err != nil {
+			return "", err
+		}
+		return "(" + x + ")", nil
+	case *unaryEvaluator:
+		x, err := b.buildOperand(e.x)
+		if err != nil {
+			return "", err
+		}
+		switch e.op {
+		case "!":
+			return "NOT " + x, nil
+		case "-":
+			return "-" + x, nil
+		default:
+			return x, nil
+		}
+	case *comparativeEvaluator:
+		return b.buildComparative(e)
+	case *logicalEvaluator:
+		op := "AND"
+		if e.op == "||" {
+			op = "OR"
+		}
+		operands := make([]string, 0, 2)
+		for _, operand := range []Evaluator{e.x, e.y} {
+			s, err := b.build(operand)
+			if err != nil {
+				return "", err
+			}
+			if l, ok := operand.(*logicalEvaluator); ok && l.op != e.op {
+				s = "(" + s + ")"
+			}
+			operands = append(operands, s)
+		}
+		return operands[0] + " " + op + " " + operands[1], nil
+	case *computableEvaluator:
+		x, err := b.build(e.x)
+		if err != nil {
+			return "", err
+		}
+		y, err := b.build(e.y)
+		if err != nil {
+			return "", err
+		}
+		op := e.op
+		switch op {
+		case "+":
+			if kind, _ := inferKind(e); kind == kindString {
+				op = "||"
+			}
+		case "/":
+			// the division of the integer columns is truncated in SQL, while it is the float division in Eval
+			x, y = b.castNumber(x), b.castNumber(y)
+		}
+		return x + " " + op + " " + y, nil
+	case *callEvaluator:
+		return b.buildCall(e)
+	default:
+		return "", b.unsupported(e)
+	}
+}
+
+// buildOperand builds the operand of the unary operator, enclosed in parentheses unless it is atomic.
+func (b *sqlBuilder) buildOperand(e Evaluator) (string, error) {
+	s, err := b.build(e)
+	if err != nil {
+		return "", err
+	}
+	switch e.(type) {
+	case nilEvaluator, *lockupVariableEvaluator, *selectorEvaluator, *realNumericLiteralEvaluator, *stringLiteralEvaluator, *parenEvaluator, *callEvaluator:
+		return s, nil
+	default:
+		return "(" + s + ")", nil
+	}
+}
+
+func (b *sqlBuilder) identifier(e Evaluator) (string, error) {
+	switch e := e.(type) {
+	case *lockupVariableEvaluator:
+		return quoteSQLIdentifier(e.name), nil
+	case *selectorEvaluator:
+		x, err := b.identifier(e.x)
+		if err != nil {
+			return "", err
+		}
+		return x + "." + quoteSQLIdentifier(e.name), nil
+	default:
+		return "", b.unsupported(e)
+	}
+}
+
+func quoteSQLIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func (b *sqlBuilder) buildComparative(e *comparativeEvaluator) (string, error) {
+	_, xNil := e.x.(nilEvaluator)
+	_, yNil := e.y.(nilEvaluator)
+	if xNil || yNil {
+		operand := e.x
+		if xNil {
+			operand = e.y
+		}
+		s, err := b.build(operand)
+		if err != nil {
+			return "", err
+		}
+		switch e.op {
+		case "==", "=":
+			return s + " IS NULL", nil
+		case "!=":
+			return s + " IS NOT NULL", nil
+		default:
+			return "", b.unsupported(e)
+		}
+	}
+	x, err := b.build(e.x)
+	if err != nil {
+		return "", err
+	}
+	y, err := b.build(e.y)
+	if err != nil {
+		return "", err
+	}
+	op := e.op
+	switch op {
+	case "==":
+		op = "="
+	case "!=":
+		op = "<>"
+	}
+	return x + " " + op + " " + y, nil
+}
+
+func (b *sqlBuilder) buildCall(e *callEvaluator) (string, error) {
+	switch e.funcName {
+	case "has_prefix", "has_suffix":
+		return b.buildLike(e)
+	case "trim":
+		if len(e.args) != 1 {
+			return "", b.unsupported(e)
+		}
+	case "len":
+		if kind, _ := inferKind(e.args[0]); kind != kindString {
+			return "", b.unsupported(e)
+		}
+	}
+	args := make([]string, 0, len(e.args))
+	for _, arg := range e.args {
+		s, err := b.build(arg)
+		if err != nil {
+			return "", err
+		}
+		args = append(args, s)
+	}
+	stringType := "TEXT"
+	switch e.funcName {
+	case "if":
+		return fmt.Sprintf("CASE WHEN %s THEN %s ELSE %s END", args[0], args[1], args[2]), nil
+	case "rate":
+		return fmt.Sprintf("%s / NULLIF(%s, 0)", b.castNumber(args[0]), args[1]), nil
+	case "as_numeric":
+		return b.castNumber(args[0]), nil
+	case "as_string":
+		return fmt.Sprintf("CAST(%s AS %s)", args[0], stringType), nil
+	case "equal_fold":
+		return fmt.Sprintf("(LOWER(%s) = LOWER(%s))", args[0], args[1]), nil
+	case "string_contains":
+		if b.dialect == SQLDialectPostgres {
+			return fmt.Sprintf("(STRPOS(%s, %s) > 0)", args[0], args[1]), nil
+		}
+		return fmt.Sprintf("(INSTR(%s, %s) > 0)", args[0], args[1]), nil
+	case "regexp_match":
+		if b.dialect == SQLDialectPostgres {
+			return fmt.Sprintf("(%s ~ %s)", args[0], args[1]), nil
+		}
+		return fmt.Sprintf("(%s REGEXP %s)", args[0], args[1]), nil
+	}
+	f, ok := getBuiltinFunc(e.funcName)
+	if !ok || f.sql[b.dialect] == "" {
+		return "", b.unsupported(e)
+	}
+	return f.sql[b.dialect] + "(" + strings.Join(args, ", ") + ")", nil
+}
+
+// castNumber casts the expression to the floating point number, like the numbers of Eval.
+func (b *sqlBuilder) castNumber(s string) string {
+	if b.dialect == SQLDialectSQLite {
+		return "CAST(" + s + " AS REAL)"
+	}
+	return "CAST(" + s + " AS DOUBLE PRECISION)"
+}
+
+// buildLike builds has_prefix and has_suffix by LIKE, the pattern must be a string literal to escape the wildcards.
+func (b *sqlBuilder) buildLike(e *callEvaluator) (string, error) {
+	lit, ok := e.args[1].(*stringLiteralEvaluator)
+	if !ok {
+		return "", b.unsupported(e)
+	}
+	s, err := b.build(e.args[0])
+	if err != nil {
+		return "", err
+	}
+	pattern := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(lit.str)
+	if e.funcName == "has_prefix" {
+		pattern += "%"
+	} else {
+		pattern = "%" + pattern
+	}
+	return fmt.Sprintf(`(%s LIKE %s ESCAPE '\')`, s, b.param(pattern)), nil
+}
@@ -0,0 +1,169 @@
+package evaluator_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mashiike/evaluator"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToSQL(t *testing.T) {
+	cases := []struct {
+		expr         string
+		dialect      evaluator.SQLDialect
+		expected     string
+		expectedArgs []interface{}
+	}{
+		{
+			expr:         "var1 > 0.5 && name == `foo`",
+			dialect:      evaluator.SQLDialectPostgres,
+			expected:     `"var1" > $1 AND "name" = $2`,
+			expectedArgs: []interface{}{0.5, "foo"},
+		},
+		{
+			expr:         "var1 > 0.5 && name == `foo`",
+			dialect:      evaluator.SQLDialectSQLite,
+			expected:     `"var1" > ? AND "name" = ?`,
+			expectedArgs: []interface{}{0.5, "foo"},
+		},
+		{
+			expr:         "(a || b.c != 1) && !(x <= -2)",
+			dialect:      evaluator.SQLDialectPostgres,
+			expected:     `("a" OR "b"."c" <> $1) AND NOT ("x" <= $2)`,
+			expectedArgs: []interface{}{1.0, -2.0},
+		},
+		{
+			expr:         "a || b && c",
+			dialect:      evaluator.SQLDialectPostgres,
+			expected:     `"a" OR ("b" AND "c")`,
+			expectedArgs: nil,
+		},
+		{
+			expr:         "x == nil || y != nil",
+			dialect:      evaluator.SQLDialectPostgres,
+			expected:     `"x" IS NULL OR "y" IS NOT NULL`,
+			expectedArgs: nil,
+		},
+		{
+			expr:         "0 < x < 10",
+			dialect:      evaluator.SQLDialectSQLite,
+			expected:     `? < "x" AND "x" < ?`,
+			expectedArgs: []interface{}{0.0, 10.0},
+		},
+		{
+			expr:         "if(x > 1, `a`, `b`) == coalesce(y, `c`)",
+			dialect:      evaluator.SQLDialectPostgres,
+			expected:     `CASE WHEN "x" > $1 THEN $2 ELSE $3 END = COALESCE("y", $4)`,
+			expectedArgs: []interface{}{1.0, "a", "b", "c"},
+		},
+		{
+			expr:         "rate(errors, requests) * 100 >= 5",
+			dialect:      evaluator.SQLDialectPostgres,
+			expected:     `CAST("errors" AS DOUBLE PRECISION) / NULLIF("requests", 0) * $1 >= $2`,
+			expectedArgs: []interface{}{100.0, 5.0},
+		},
+		{
+			expr:         "a / b > 0.5",
+			dialect:      evaluator.SQLDialectPostgres,
+			expected:     `CAST("a" AS DOUBLE PRECISION) / CAST("b" AS DOUBLE PRECISION) > $1`,
+			expectedArgs: []interface{}{0.5},
+		},
+		{
+			expr:         "(a + 1) / 2 <= b",
+			dialect:      evaluator.SQLDialectSQLite,
+			expected:     `CAST(("a" + ?) AS REAL) / CAST(? AS REAL) <= "b"`,
+			expectedArgs: []interface{}{1.0, 2.0},
+		},
+		{
+			expr:         "as_numeric(x) > 1 && as_string(y) == `1`",
+			dialect:      evaluator.SQLDialectSQLite,
+			expected:     `CAST("x" AS REAL) > ? AND CAST("y" AS TEXT) = ?`,
+			expectedArgs: []interface{}{1.0, "1"},
+		},
+		{
+			expr:         "regexp_match(name, `^a.*`) && string_contains(name, `b`)",
+			dialect:      evaluator.SQLDialectPostgres,
+			expected:     `("name" ~ $1) AND (STRPOS("name", $2) > 0)`,
+			expectedArgs: []interface{}{"^a.*", "b"},
+		},
+		{
+			expr:         "regexp_match(name, `^a.*`) && string_contains(name, `b`)",
+			dialect:      evaluator.SQLDialectSQLite,
+			expected:     `("name" REGEXP ?) AND (INSTR("name", ?) > 0)`,
+			expectedArgs: []interface{}{"^a.*", "b"},
+		},
+		{
+			expr:         "has_prefix(path, `/api_v1/`) || has_suffix(path, `100%`)",
+			dialect:      evaluator.SQLDialectPostgres,
+			expected:     `("path" LIKE $1 ESCAPE '\') OR ("path" LIKE $2 ESCAPE '\')`,
+			expectedArgs: []interface{}{`/api\_v1/%`, `%100\%`},
+		},
+		{
+			expr:         "first + `-` + last == `a-b`",
+			dialect:      evaluator.SQLDialectPostgres,
+			expected:     `"first" || $1 || "last" = $2`,
+			expectedArgs: []interface{}{"-", "a-b"},
+		},
+		{
+			expr:         "abs(-x) < 1 && log(y) > 0 && len(lower(name)) == 3",
+			dialect:      evaluator.SQLDialectPostgres,
+			expected:     `ABS(-"x") < $1 AND LN("y") > $2 AND LENGTH(LOWER("name")) = $3`,
+			expectedArgs: []interface{}{1.0, 0.0, 3.0},
+		},
+	}
+	for _, c := range cases {
+		t.Run(string(c.dialect)+":"+c.expr, func(t *testing.T) {
+			e, err := evaluator.New(c.expr)
+			require.NoError(t, err)
+			actual, args, err := evaluator.ToSQL(e, c.dialect)
+			require.NoError(t, err)
+			require.Equal(t, c.expected, actual)
+			require.Equal(t, c.expectedArgs, args)
+		})
+	}
+}
+
+func TestToSQLFail(t *testing.T) {
+	cases := []struct {
+		expr     string
+		expected string
+	}{
+		{expr: "sum(values) > 1", expected: "ToSQL(`sum(values)`) has no SQL equivalent"},
+		{expr: "any(values, x -> x > 1)", expected: "ToSQL(`any(values, x -> x > 1)`) has no SQL equivalent"},
+		{expr: "has_prefix(a, b)", expected: "ToSQL(`has_prefix(a, b)`) has no SQL equivalent"},
+		{expr: "x > nil", expected: "ToSQL(`x > nil`) has no SQL equivalent"},
+		{expr: "sign(x) > 0", expected: "ToSQL(`sign(x)`) has no SQL equivalent"},
+	}
+	for _, c := range cases {
+		t.Run(c.expr, func(t *testing.T) {
+			e, err := evaluator.New(c.expr)
+			require.NoError(t, err)
+			_, _, err = evaluator.ToSQL(e, evaluator.SQLDialectSQLite)
+			require.EqualError(t, err, c.expected)
+			require.True(t, errors.Is(err, evaluator.ErrNoSQLEquivalent))
+		})
+	}
+	e, err := evaluator.New("x > 1")
+	require.NoError(t, err)
+	_, _, err = evaluator.ToSQL(e, "mysql")
+	require.EqualError(t, err, "ToSQL: unknown dialect `mysql`")
+}
+
+func TestToSQLWithCostBudget(t *testing.T) {
+	e, err := evaluator.New("a / b > 0.5 && name == `foo`", evaluator.WithCostBudget(100))
+	require.NoError(t, err)
+	actual, args, err := evaluator.ToSQL(e, evaluator.SQLDialectPostgres)
+	require.NoError(t, err)
+	require.Equal(t, `CAST("a" AS DOUBLE PRECISION) / CAST("b" AS DOUBLE PRECISION) > $1 AND "name" = $2`, actual)
+	require.Equal(t, []interface{}{0.5, "foo"}, args)
+}
+
+func TestToSQLWithRecover(t *testing.T) {
+	e, err := evaluator.New("a > 0.5", evaluator.WithCostBudget(100))
+	require.NoError(t, err)
+	actual, args, err := evaluator.ToSQL(evaluator.Recover(e), evaluator.SQLDialectPostgres)
+	require.NoError(t, err)
+	require.Equal(t, `"a" > $1`, actual)
+	require.Equal(t, []interface{}{0.5}, args)
+}
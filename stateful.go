@@ -0,0 +1,133 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// stateVariableName is the reserved name of the state in Variables, it can not be referenced by the expressions.
+const stateVariableName = "\x00state"
+
+type statefulSites map[string]*siteState
+
+// StatefulEvaluator is an Evaluator which keeps the history of the stateful functions between the evaluations,
+// like delta(x), increase(counter), moving_avg(x, 5), prev(x) and ewma(x, alpha).
+// Each call site of the stateful functions has its own history, and the history can be saved by Snapshot and loaded by Restore.
+// The evaluations are serialized, it is safe for concurrent use.
+type StatefulEvaluator struct {
+	Evaluator
+
+	mu    sync.Mutex
+	sites statefulSites
+}
+
+// stateSnapshot is the format of StatefulEvaluator.Snapshot.
+type stateSnapshot struct {
+	Expr  string        `json:"expr"`
+	Sites statefulSites `json:"sites"`
+}
+
+// NewStateful parses the expression with the options of New to create a StatefulEvaluator.
+func NewStateful(expr string, opts ...Option) (*StatefulEvaluator, error) {
+	e, err := New(expr, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return WithState(e), nil
+}
+
+// WithState wraps the evaluator to keep the history of the stateful functions.
+func WithState(e Evaluator) *StatefulEvaluator {
+	n := 0
+	assignSites(e, &n)
+	return &StatefulEvaluator{
+		Evaluator: e,
+		sites:     make(statefulSites),
+	}
+}
+
+// assignSites names the call sites of the stateful functions by the order and the expression, like `0:delta(x)`.
+func assignSites(e Evaluator, n *int) {
+	if call, ok := e.(*statefulCallEvaluator); ok {
+		call.site = fmt.Sprintf("%d:%s", *n, call)
+		*n++
+	}
+	for _, child := range childrenOf(e) {
+		assignSites(child, n)
+	}
+}
+
+// Eval performs an evaluation, and updates the history.
+func (s *StatefulEvaluator) Eval(vars Variables) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Evaluator.Eval(s.scope(vars))
+}
+
+func (s *StatefulEvaluator) scope(vars Variables) Variables {
+	scope := childScope(vars, 1)
+	scope[stateVariableName] = s.sites
+	return scope
+}
+
+// AsComparator attempts to convert to Comparator which updates the history.
+func (s *StatefulEvaluator) AsComparator() (Comparator, bool) {
+	c, ok := s.Evaluator.AsComparator()
+	if !ok {
+		return nil, false
+	}
+	return &statefulComparator{Comparator: c, s: s}, true
+}
+
+// Reset clears the history.
+func (s *StatefulEvaluator) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sites = make(statefulSites)
+}
+
+// Snapshot encodes the history to JSON, NaN and ±Inf in the history are encoded as the objects of a reserved key.
+func (s *StatefulEvaluator) Snapshot() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return json.Marshal(stateSnapshot{
+		Expr:  s.Evaluator.String(),
+		Sites: s.sites,
+	})
+}
+
+// Restore decodes the history encoded by Snapshot, it is an error if the snapshot is of the other expression.
+func (s *StatefulEvaluator) Restore(data []byte) error {
+	var snapshot stateSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("Restore(`%s`) %w", s.Evaluator, err)
+	}
+	if snapshot.Expr != s.Evaluator.String() {
+		return fmt.Errorf("Restore(`%s`) snapshot is of `%s`", s.Evaluator, snapshot.Expr)
+	}
+	if snapshot.Sites == nil {
+		snapshot.Sites = make(statefulSites)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sites = snapshot.Sites
+	return nil
+}
+
+type statefulComparator struct {
+	Comparator
+	s *StatefulEvaluator
+}
+
+func (c *statefulComparator) Compare(vars Variables) (bool, error) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+	return c.Comparator.Compare(c.s.scope(vars))
+}
+
+func (c *statefulComparator) CompareDetailed(vars Variables) (bool, []Condition, error) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+	return CompareDetailed(c.Comparator, c.s.scope(vars))
+}
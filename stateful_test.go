@@ -0,0 +1,172 @@
+package evaluator_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/mashiike/evaluator"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatefulEvaluator(t *testing.T) {
+	cases := []struct {
+		expr     string
+		inputs   []interface{}
+		expected []interface{}
+	}{
+		{
+			expr:     "prev(x)",
+			inputs:   []interface{}{1.0, 2.0, "a"},
+			expected: []interface{}{nil, 1.0, 2.0},
+		},
+		{
+			expr:     "delta(x)",
+			inputs:   []interface{}{1.0, 4.0, 2.0},
+			expected: []interface{}{nil, 3.0, -2.0},
+		},
+		{
+			expr:     "increase(x)",
+			inputs:   []interface{}{10.0, 15.0, 3.0, 5.0},
+			expected: []interface{}{nil, 5.0, 3.0, 2.0},
+		},
+		{
+			expr:     "moving_avg(x, 3)",
+			inputs:   []interface{}{3.0, 6.0, 9.0, 12.0},
+			expected: []interface{}{3.0, 4.5, 6.0, 9.0},
+		},
+		{
+			expr:     "ewma(x, 0.5)",
+			inputs:   []interface{}{4.0, 8.0, 0.0},
+			expected: []interface{}{4.0, 6.0, 3.0},
+		},
+		{
+			expr:     "coalesce(delta(x), 0) + coalesce(delta(x * 2), 0)",
+			inputs:   []interface{}{1.0, 2.0, 4.0},
+			expected: []interface{}{0.0, 3.0, 6.0},
+		},
+		{
+			expr:     "coalesce(delta(x), 0) > 0",
+			inputs:   []interface{}{1.0, 2.0, 1.0},
+			expected: []interface{}{false, true, false},
+		},
+		{
+			expr:     "delta(x)",
+			inputs:   []interface{}{1.0, nil, 3.0},
+			expected: []interface{}{nil, nil, 2.0},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.expr, func(t *testing.T) {
+			e, err := evaluator.NewStateful(c.expr)
+			require.NoError(t, err)
+			actual := make([]interface{}, 0, len(c.inputs))
+			for _, input := range c.inputs {
+				v, err := e.Eval(evaluator.Variables{"x": input})
+				require.NoError(t, err)
+				actual = append(actual, v)
+			}
+			require.Equal(t, c.expected, actual)
+		})
+	}
+}
+
+func TestStatefulEvaluatorSnapshot(t *testing.T) {
+	e, err := evaluator.NewStateful("moving_avg(x, 2) + coalesce(increase(counter), 0)")
+	require.NoError(t, err)
+	_, err = e.Eval(evaluator.Variables{"x": 2, "counter": 10})
+	require.NoError(t, err)
+	snapshot, err := e.Snapshot()
+	require.NoError(t, err)
+
+	restored, err := evaluator.NewStateful("moving_avg(x, 2) + coalesce(increase(counter), 0)")
+	require.NoError(t, err)
+	require.NoError(t, restored.Restore(snapshot))
+	v, err := restored.Eval(evaluator.Variables{"x": 4, "counter": 12})
+	require.NoError(t, err)
+	require.EqualValues(t, 5, v, "(2 + 4) / 2 + (12 - 10)")
+
+	restored.Reset()
+	v, err = restored.Eval(evaluator.Variables{"x": 4, "counter": 12})
+	require.NoError(t, err)
+	require.EqualValues(t, 4, v, "the history is cleared by reset")
+
+	other, err := evaluator.NewStateful("delta(x)")
+	require.NoError(t, err)
+	require.EqualError(t, other.Restore(snapshot), "Restore(`delta(x)`) snapshot is of `moving_avg(x, 2) + coalesce(increase(counter), 0)`")
+	require.Error(t, other.Restore([]byte("{")))
+}
+
+func TestStatefulEvaluatorSnapshotNonFinite(t *testing.T) {
+	expr := "coalesce(prev(log(x)), 0) + coalesce(ewma(x * y, 0.5), 0) + moving_avg(x * y, 2) + len(coalesce(prev(values), values))"
+	e, err := evaluator.NewStateful(expr)
+	require.NoError(t, err)
+	_, err = e.Eval(evaluator.Variables{"x": 0, "y": math.Inf(1), "values": []float64{math.NaN(), math.Inf(-1), 1}})
+	require.NoError(t, err)
+	snapshot, err := e.Snapshot()
+	require.NoError(t, err)
+
+	restored, err := evaluator.NewStateful(expr)
+	require.NoError(t, err)
+	require.NoError(t, restored.Restore(snapshot))
+	snapshot2, err := restored.Snapshot()
+	require.NoError(t, err)
+	require.JSONEq(t, string(snapshot), string(snapshot2))
+
+	e, err = evaluator.NewStateful("prev(x)")
+	require.NoError(t, err)
+	_, err = e.Eval(evaluator.Variables{"x": math.Inf(-1)})
+	require.NoError(t, err)
+	snapshot, err = e.Snapshot()
+	require.NoError(t, err)
+	restored, err = evaluator.NewStateful("prev(x)")
+	require.NoError(t, err)
+	require.NoError(t, restored.Restore(snapshot))
+	v, err := restored.Eval(evaluator.Variables{"x": 1})
+	require.NoError(t, err)
+	require.Equal(t, math.Inf(-1), v)
+}
+
+func TestStatefulEvaluatorComparator(t *testing.T) {
+	e, err := evaluator.NewStateful("coalesce(delta(x), 0) > 5")
+	require.NoError(t, err)
+	c, ok := e.AsComparator()
+	require.True(t, ok)
+	_, err = c.Compare(evaluator.Variables{"x": 1})
+	require.NoError(t, err)
+	b, err := c.Compare(evaluator.Variables{"x": 10})
+	require.NoError(t, err)
+	require.True(t, b)
+}
+
+func TestStatefulFunctionErrors(t *testing.T) {
+	e, err := evaluator.New("delta(x)")
+	require.NoError(t, err)
+	_, err = e.Eval(evaluator.Variables{"x": 1})
+	require.True(t, errors.Is(err, evaluator.ErrStateNotGiven))
+
+	_, err = evaluator.New("delta(x, y)")
+	require.EqualError(t, err, "delta() func is expected 1 arg, but given 2 args")
+
+	cases := []struct {
+		expr string
+		vars evaluator.Variables
+	}{
+		{expr: "delta(x)", vars: evaluator.Variables{"x": "a"}},
+		{expr: "moving_avg(x, 0)", vars: evaluator.Variables{"x": 1}},
+		{expr: "moving_avg(x, 1.5)", vars: evaluator.Variables{"x": 1}},
+		{expr: "moving_avg(x, 1e19)", vars: evaluator.Variables{"x": 1}},
+		{expr: "moving_avg(x, size)", vars: evaluator.Variables{"x": 1, "size": math.Inf(1)}},
+		{expr: "moving_avg(x, 65537)", vars: evaluator.Variables{"x": 1}},
+		{expr: "ewma(x, 1.5)", vars: evaluator.Variables{"x": 1}},
+		{expr: "ewma(x, alpha)", vars: evaluator.Variables{"x": 1, "alpha": math.NaN()}},
+	}
+	for _, c := range cases {
+		t.Run(c.expr, func(t *testing.T) {
+			e, err := evaluator.NewStateful(c.expr)
+			require.NoError(t, err)
+			_, err = e.Eval(c.vars)
+			require.Error(t, err)
+		})
+	}
+}
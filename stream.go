@@ -0,0 +1,323 @@
+package evaluator
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// StreamFormat is the format of the records given to EvalStream.
+type StreamFormat string
+
+// The formats of EvalStream
+const (
+	// StreamFormatJSONLines is the JSON Lines, each line is a JSON object of variables.
+	StreamFormatJSONLines StreamFormat = "jsonl"
+	// StreamFormatCSV is the CSV with the header line, the header is used as the variable names.
+	StreamFormatCSV StreamFormat = "csv"
+)
+
+// ErrorPolicy decides how EvalStream handles the row which failed.
+type ErrorPolicy int
+
+// The error policies of EvalStream
+const (
+	// ErrorPolicyAbort stops the stream and returns the RowError. It is the default.
+	ErrorPolicyAbort ErrorPolicy = iota
+	// ErrorPolicySkip drops the row from the output.
+	ErrorPolicySkip
+	// ErrorPolicyNull writes the row with the null result.
+	ErrorPolicyNull
+)
+
+// RowError is an error of a row of EvalStream, the decode error or the evaluation error.
+type RowError struct {
+	// Line is the line number of the row in the input.
+	Line int
+	Err  error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// StreamOption is an option of EvalStream.
+type StreamOption func(*streamConfig)
+
+type streamConfig struct {
+	resultColumn string
+	errorColumn  string
+	policy       ErrorPolicy
+	onError      func(*RowError)
+	base         Variables
+}
+
+// WithResultColumn sets the name of the result column, the default is `result`.
+func WithResultColumn(name string) StreamOption {
+	return func(cfg *streamConfig) {
+		cfg.resultColumn = name
+	}
+}
+
+// WithErrorColumn adds the column of the error message of the row, it is empty if the row succeeded.
+func WithErrorColumn(name string) StreamOption {
+	return func(cfg *streamConfig) {
+		cfg.errorColumn = name
+	}
+}
+
+// WithErrorPolicy sets how the failed rows are handled.
+func WithErrorPolicy(policy ErrorPolicy) StreamOption {
+	return func(cfg *streamConfig) {
+		cfg.policy = policy
+	}
+}
+
+// WithRowErrorHandler sets the function called for each failed row, before the error policy is applied.
+func WithRowErrorHandler(f func(*RowError)) StreamOption {
+	return func(cfg *streamConfig) {
+		cfg.onError = f
+	}
+}
+
+// WithBaseVariables sets the variables shared by all rows, the columns of the row override them.
+func WithBaseVariables(vars Variables) StreamOption {
+	return func(cfg *streamConfig) {
+		cfg.base = vars
+	}
+}
+
+// EvalStream evaluates the expression for each record read from r, and writes the records with the result column to w in the same format.
+// The CSV cells which are numbers are given as float64, the empty cells are given as nil, and the others are given as string.
+func EvalStream(e Evaluator, r io.Reader, format StreamFormat, w io.Writer, opts ...StreamOption) error {
+	cfg := &streamConfig{
+		resultColumn: "result",
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	switch format {
+	case StreamFormatJSONLines:
+		return cfg.evalJSONLines(e, r, w)
+	case StreamFormatCSV:
+		return cfg.evalCSV(e, r, w)
+	default:
+		return fmt.Errorf("EvalStream: unknown format `%s`", format)
+	}
+}
+
+// handle reports the row error, and returns whether the row is written and the error which stops the stream.
+func (cfg *streamConfig) handle(rowErr *RowError) (bool, error) {
+	if cfg.onError != nil {
+		cfg.onError(rowErr)
+	}
+	switch cfg.policy {
+	case ErrorPolicySkip:
+		return false, nil
+	case ErrorPolicyNull:
+		return true, nil
+	default:
+		return false, rowErr
+	}
+}
+
+func (cfg *streamConfig) eval(e Evaluator, vars Variables) (interface{}, error) {
+	if len(cfg.base) > 0 {
+		merged := make(Variables, len(cfg.base)+len(vars))
+		for k, v := range cfg.base {
+			merged[k] = v
+		}
+		for k, v := range vars {
+			merged[k] = v
+		}
+		vars = merged
+	}
+	return e.Eval(vars)
+}
+
+func (cfg *streamConfig) evalJSONLines(e Evaluator, r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	bw := bufio.NewWriter(w)
+	defer bw.Flush()
+	line := 0
+	for scanner.Scan() {
+		line++
+		bs := bytes.TrimSpace(scanner.Bytes())
+		if len(bs) == 0 {
+			continue
+		}
+		var record map[string]interface{}
+		err := json.Unmarshal(bs, &record)
+		var ret interface{}
+		if err == nil {
+			ret, err = cfg.eval(e, record)
+		}
+		var encoded []byte
+		if err == nil {
+			encoded, err = cfg.marshalJSONLine(record, ret, "")
+		}
+		if err != nil {
+			rowErr := &RowError{Line: line, Err: err}
+			write, err := cfg.handle(rowErr)
+			if err != nil {
+				return err
+			}
+			if !write {
+				continue
+			}
+			if encoded, err = cfg.marshalJSONLine(record, nil, rowErr.Err.Error()); err != nil {
+				return err
+			}
+		}
+		bw.Write(encoded)
+		if err := bw.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func (cfg *streamConfig) marshalJSONLine(record map[string]interface{}, ret interface{}, errMsg string) ([]byte, error) {
+	out := make(map[string]interface{}, len(record)+2)
+	for k, v := range record {
+		out[k] = v
+	}
+	out[cfg.resultColumn] = ret
+	if cfg.errorColumn != "" && errMsg != "" {
+		out[cfg.errorColumn] = errMsg
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(out); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+func (cfg *streamConfig) evalCSV(e Evaluator, r io.Reader, w io.Writer) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	writer := csv.NewWriter(w)
+	outHeader := append(append([]string{}, header...), cfg.resultColumn)
+	if cfg.errorColumn != "" {
+		outHeader = append(outHeader, cfg.errorColumn)
+	}
+	if err := writer.Write(outHeader); err != nil {
+		return err
+	}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return err
+			}
+			rowErr := &RowError{Line: parseErr.Line, Err: parseErr.Err}
+			if _, err := cfg.handle(rowErr); err != nil {
+				writer.Flush()
+				return err
+			}
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		var ret interface{}
+		if len(record) != len(header) {
+			err = fmt.Errorf("wrong number of fields, expected %d but given %d", len(header), len(record))
+		} else {
+			vars := make(Variables, len(header))
+			for i, name := range header {
+				vars[name] = parseCSVCell(record[i])
+			}
+			ret, err = cfg.eval(e, vars)
+		}
+		var cell string
+		if err == nil {
+			cell, err = formatCSVCell(ret)
+		}
+		out := make([]string, len(header), len(outHeader))
+		copy(out, record)
+		if err != nil {
+			rowErr := &RowError{Line: line, Err: err}
+			write, err := cfg.handle(rowErr)
+			if err != nil {
+				writer.Flush()
+				return err
+			}
+			if !write {
+				continue
+			}
+			out = append(out, "")
+			if cfg.errorColumn != "" {
+				out = append(out, rowErr.Err.Error())
+			}
+		} else {
+			out = append(out, cell)
+			if cfg.errorColumn != "" {
+				out = append(out, "")
+			}
+		}
+		if err := writer.Write(out); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func parseCSVCell(cell string) interface{} {
+	if cell == "" {
+		return nil
+	}
+	if n, err := strconv.ParseFloat(strings.TrimSpace(cell), 64); err == nil && !math.IsNaN(n) && !math.IsInf(n, 0) {
+		return n
+	}
+	return cell
+}
+
+func formatCSVCell(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	default:
+		if s, ok := isString(v); ok {
+			return s, nil
+		}
+		bs, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(bs), nil
+	}
+}
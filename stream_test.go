@@ -0,0 +1,107 @@
+package evaluator_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/mashiike/evaluator"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvalStream(t *testing.T) {
+	cases := []struct {
+		name      string
+		expr      string
+		format    evaluator.StreamFormat
+		input     string
+		opts      []evaluator.StreamOption
+		expected  string
+		errString string
+		rowErrors []int
+	}{
+		{
+			name:     "jsonl",
+			expr:     "var1 * var2",
+			format:   evaluator.StreamFormatJSONLines,
+			input:    "{\"var1\": 1, \"var2\": 2}\n\n{\"var1\": 3, \"var2\": 4}\n",
+			expected: `{"result":2,"var1":1,"var2":2}` + "\n" + `{"result":12,"var1":3,"var2":4}` + "\n",
+		},
+		{
+			name:      "jsonl abort",
+			expr:      "var1 * 2",
+			format:    evaluator.StreamFormatJSONLines,
+			input:     "{\"var1\": 1}\n{\"var1\": \"a\"}\n{\"var1\": 3}\n",
+			expected:  `{"result":2,"var1":1}` + "\n",
+			errString: "line 2: Eval(`var1 * 2`) v1[a]::string and v2[2]::float64 can not `*` comparatable",
+			rowErrors: []int{2},
+		},
+		{
+			name:      "jsonl skip",
+			expr:      "var1 * 2",
+			format:    evaluator.StreamFormatJSONLines,
+			input:     "{\"var1\": 1}\n{\"var1\": \"a\"}\n{\"var1\": 3}\n",
+			opts:      []evaluator.StreamOption{evaluator.WithErrorPolicy(evaluator.ErrorPolicySkip)},
+			expected:  `{"result":2,"var1":1}` + "\n" + `{"result":6,"var1":3}` + "\n",
+			rowErrors: []int{2},
+		},
+		{
+			name:   "jsonl null with error column",
+			expr:   "var1 * 2",
+			format: evaluator.StreamFormatJSONLines,
+			input:  "{\"var1\": 1}\n{\"var1\": \n",
+			opts: []evaluator.StreamOption{
+				evaluator.WithErrorPolicy(evaluator.ErrorPolicyNull),
+				evaluator.WithResultColumn("doubled"),
+				evaluator.WithErrorColumn("error"),
+			},
+			expected:  `{"doubled":2,"var1":1}` + "\n" + `{"doubled":null,"error":"unexpected end of JSON input"}` + "\n",
+			rowErrors: []int{2},
+		},
+		{
+			name:     "csv",
+			expr:     "upper(name) + `=` + as_string(coalesce(var2, var1) * var1)",
+			format:   evaluator.StreamFormatCSV,
+			input:    "name,var1,var2\na,1,2\nb,0.5,\n\"c,d\",3,-1e1\n",
+			opts:     []evaluator.StreamOption{evaluator.WithBaseVariables(evaluator.Variables{"var1": 100.0, "var2": 10.0})},
+			expected: "name,var1,var2,result\na,1,2,A=2\nb,0.5,,B=0.25\n\"c,d\",3,-1e1,\"C,D=-30\"\n",
+		},
+		{
+			name:   "csv null",
+			expr:   "var1 > 1",
+			format: evaluator.StreamFormatCSV,
+			input:  "var1\n2\nx\n1,2\n0\n",
+			opts: []evaluator.StreamOption{
+				evaluator.WithErrorPolicy(evaluator.ErrorPolicyNull),
+				evaluator.WithErrorColumn("error"),
+			},
+			expected:  "var1,result,error\n2,true,\nx,,Eval(`var1 > 1`) v1[x]::string and v2[1]::float64 can not `>` comparatable\n1,,\"wrong number of fields, expected 1 but given 2\"\n0,false,\n",
+			rowErrors: []int{3, 4},
+		},
+		{
+			name:      "unknown format",
+			expr:      "var1",
+			format:    "xml",
+			errString: "EvalStream: unknown format `xml`",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			e, err := evaluator.New(c.expr)
+			require.NoError(t, err)
+			var rowErrors []int
+			opts := append(c.opts, evaluator.WithRowErrorHandler(func(err *evaluator.RowError) {
+				rowErrors = append(rowErrors, err.Line)
+			}))
+			var out bytes.Buffer
+			err = evaluator.EvalStream(e, strings.NewReader(c.input), c.format, &out, opts...)
+			if c.errString != "" {
+				require.EqualError(t, err, c.errString)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, c.expected, out.String())
+			require.Equal(t, c.rowErrors, rowErrors)
+		})
+	}
+}
@@ -1,6 +1,10 @@
 package evaluator
 
-import "strconv"
+import (
+	"math"
+	"reflect"
+	"strconv"
+)
 
 func isBothStrings(v1, v2 interface{}) (s1, s2 string, ok bool) {
 	s1, ok = isString(v1)
@@ -15,7 +19,7 @@ func isString(v interface{}) (string, bool) {
 	switch v := v.(type) {
 	case string:
 		return v, true
-	case rune:
+	case []rune:
 		return string(v), true
 	default:
 		return "", false
@@ -76,6 +80,24 @@ func isRealNumber(v interface{}) (float64, bool) {
 	}
 }
 
+// isInteger is isRealNumber that also requires a finite integral value, such as the index of a string.
+func isInteger(v interface{}) (float64, bool) {
+	n, ok := isRealNumber(v)
+	if !ok || math.IsInf(n, 0) || n != math.Trunc(n) {
+		return 0, false
+	}
+	return n, true
+}
+
+// WithoutCoercion disables the implicit conversions of the values, which are applied by default:
+// `!` and the condition of if() take only bools, and the elements of join() are only strings.
+// The explicit conversions by as_string() and as_numeric() are not disabled.
+func WithoutCoercion() Option {
+	return func(cfg *config) {
+		cfg.noCoercion = true
+	}
+}
+
 func asNumber(v interface{}) (float64, bool) {
 	if n, ok := isRealNumber(v); ok {
 		return n, true
@@ -111,3 +133,68 @@ func asBool(v interface{}) (bool, bool) {
 	}
 	return false, false
 }
+
+func isList(v interface{}) ([]interface{}, bool) {
+	switch v := v.(type) {
+	case nil, string, []rune:
+		return nil, false
+	case []interface{}:
+		return v, true
+	case []float64:
+		list := make([]interface{}, 0, len(v))
+		for _, n := range v {
+			list = append(list, n)
+		}
+		return list, true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		list := make([]interface{}, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			list = append(list, rv.Index(i).Interface())
+		}
+		return list, true
+	default:
+		return nil, false
+	}
+}
+
+func lookupField(v interface{}, name string) (interface{}, bool) {
+	switch v := v.(type) {
+	case nil:
+		return nil, false
+	case Variables:
+		field, ok := v[name]
+		return field, ok
+	case map[string]interface{}:
+		field, ok := v[name]
+		return field, ok
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, false
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, false
+		}
+		field := rv.MapIndex(reflect.ValueOf(name).Convert(rv.Type().Key()))
+		if !field.IsValid() {
+			return nil, false
+		}
+		return field.Interface(), true
+	case reflect.Struct:
+		field := rv.FieldByName(name)
+		if !field.IsValid() || !field.CanInterface() {
+			return nil, false
+		}
+		return field.Interface(), true
+	default:
+		return nil, false
+	}
+}
@@ -11,6 +11,10 @@ func getUnaryFunc(op token.Token) (unaryFunc, bool) {
 	switch op {
 	case token.NOT: // !
 		return notUnaryFunc, true
+	case token.SUB: // -
+		return negUnaryFunc, true
+	case token.ADD: // +
+		return plusUnaryFunc, true
 	default:
 		return nil, false
 	}
@@ -23,3 +27,28 @@ func notUnaryFunc(v interface{}) (interface{}, error) {
 	}
 	return !b, nil
 }
+
+// exactNotUnaryFunc is `!` without the coercion, see WithoutCoercion.
+func exactNotUnaryFunc(v interface{}) (interface{}, error) {
+	b, ok := isBool(v)
+	if !ok {
+		return nil, fmt.Errorf("v[%v]::%T can not `!` operation", v, v)
+	}
+	return !b, nil
+}
+
+func negUnaryFunc(v interface{}) (interface{}, error) {
+	n, ok := isRealNumber(v)
+	if !ok {
+		return nil, fmt.Errorf("v[%v]::%T can not `-` operation", v, v)
+	}
+	return -n, nil
+}
+
+func plusUnaryFunc(v interface{}) (interface{}, error) {
+	n, ok := isRealNumber(v)
+	if !ok {
+		return nil, fmt.Errorf("v[%v]::%T can not `+` operation", v, v)
+	}
+	return n, nil
+}
@@ -0,0 +1,187 @@
+package evaluator
+
+import "sort"
+
+// node is implemented by the evaluators of this package to traverse the expression tree.
+type node interface {
+	// children returns the sub evaluators in the order of evaluation.
+	children() []Evaluator
+	// withChildren returns a shallow copy of the evaluator whose sub evaluators are replaced.
+	withChildren([]Evaluator) Evaluator
+}
+
+// unwrap returns the expression wrapped by the evaluators which only guard the evaluation, like the budget of WithCostBudget and Recover.
+// The translators translate the expression, and the guards are not translated.
+func unwrap(e Evaluator) Evaluator {
+	for {
+		switch w := e.(type) {
+		case *budgetEvaluator:
+			e = w.x
+		case *recoverEvaluator:
+			e = w.x
+		default:
+			return e
+		}
+	}
+}
+
+// unwrapComparator is unwrap for the comparators.
+func unwrapComparator(c Comparator) Comparator {
+	for {
+		switch w := c.(type) {
+		case *budgetComparator:
+			c = w.x
+		case *recoverComparator:
+			c = w.x
+		default:
+			return c
+		}
+	}
+}
+
+func childrenOf(e Evaluator) []Evaluator {
+	if n, ok := e.(node); ok {
+		return n.children()
+	}
+	return nil
+}
+
+// ReferencedVariables returns the sorted names of the variables referenced by the expression.
+// The parameters of lambda expressions are not included.
+func ReferencedVariables(e Evaluator) []string {
+	names := make(map[string]struct{})
+	collectVariables(e, nil, names)
+	ret := make([]string, 0, len(names))
+	for name := range names {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func collectVariables(e Evaluator, bound map[string]bool, names map[string]struct{}) {
+	switch e := e.(type) {
+	case *lockupVariableEvaluator:
+		if !bound[e.name] {
+			names[e.name] = struct{}{}
+		}
+		return
+	case *lambdaEvaluator:
+		scope := make(map[string]bool, len(bound)+len(e.params))
+		for name := range bound {
+			scope[name] = true
+		}
+		for _, param := range e.params {
+			scope[param] = true
+		}
+		bound = scope
+	}
+	for _, child := range childrenOf(e) {
+		collectVariables(child, bound, names)
+	}
+}
+
+func (e nilEvaluator) children() []Evaluator { return nil }
+
+func (e nilEvaluator) withChildren([]Evaluator) Evaluator { return e }
+
+func (e *lockupVariableEvaluator) children() []Evaluator { return nil }
+
+func (e *lockupVariableEvaluator) withChildren([]Evaluator) Evaluator { return e }
+
+func (e *realNumericLiteralEvaluator) children() []Evaluator { return nil }
+
+func (e *realNumericLiteralEvaluator) withChildren([]Evaluator) Evaluator { return e }
+
+func (e *stringLiteralEvaluator) children() []Evaluator { return nil }
+
+func (e *stringLiteralEvaluator) withChildren([]Evaluator) Evaluator { return e }
+
+func (e *comparativeEvaluator) children() []Evaluator { return []Evaluator{e.x, e.y} }
+
+func (e *comparativeEvaluator) withChildren(children []Evaluator) Evaluator {
+	ret := *e
+	ret.x, ret.y = children[0], children[1]
+	return &ret
+}
+
+func (e *logicalEvaluator) children() []Evaluator { return []Evaluator{e.x, e.y} }
+
+func (e *logicalEvaluator) withChildren(children []Evaluator) Evaluator {
+	ret := *e
+	ret.x, ret.y = children[0], children[1]
+	return &ret
+}
+
+func (e *computableEvaluator) children() []Evaluator { return []Evaluator{e.x, e.y} }
+
+func (e *computableEvaluator) withChildren(children []Evaluator) Evaluator {
+	ret := *e
+	ret.x, ret.y = children[0], children[1]
+	return &ret
+}
+
+func (e *parenEvaluator) children() []Evaluator { return []Evaluator{e.x} }
+
+func (e *parenEvaluator) withChildren(children []Evaluator) Evaluator {
+	ret := *e
+	ret.x = children[0]
+	return &ret
+}
+
+func (e *callEvaluator) children() []Evaluator { return e.args }
+
+func (e *callEvaluator) withChildren(children []Evaluator) Evaluator {
+	ret := *e
+	ret.args = children
+	return &ret
+}
+
+func (e *statefulCallEvaluator) children() []Evaluator { return e.args }
+
+func (e *statefulCallEvaluator) withChildren(children []Evaluator) Evaluator {
+	ret := *e
+	ret.args = children
+	return &ret
+}
+
+func (e *unaryEvaluator) children() []Evaluator { return []Evaluator{e.x} }
+
+func (e *unaryEvaluator) withChildren(children []Evaluator) Evaluator {
+	ret := *e
+	ret.x = children[0]
+	return &ret
+}
+
+func (e *selectorEvaluator) children() []Evaluator { return []Evaluator{e.x} }
+
+func (e *selectorEvaluator) withChildren(children []Evaluator) Evaluator {
+	ret := *e
+	ret.x = children[0]
+	return &ret
+}
+
+func (e *lambdaEvaluator) children() []Evaluator { return []Evaluator{e.body} }
+
+func (e *lambdaEvaluator) withChildren(children []Evaluator) Evaluator {
+	ret := *e
+	ret.body = children[0]
+	return &ret
+}
+
+// children of higherOrderEvaluator are the list, the other arguments and the lambda.
+// The lambda is passed to withChildren as *lambdaEvaluator.
+func (e *higherOrderEvaluator) children() []Evaluator {
+	children := make([]Evaluator, 0, len(e.args)+2)
+	children = append(children, e.list)
+	children = append(children, e.args...)
+	return append(children, e.lambda)
+}
+
+func (e *higherOrderEvaluator) withChildren(children []Evaluator) Evaluator {
+	ret := *e
+	ret.list = children[0]
+	ret.args = children[1 : len(children)-1]
+	ret.lambda = children[len(children)-1].(*lambdaEvaluator)
+	return &ret
+}
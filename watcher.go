@@ -0,0 +1,181 @@
+package evaluator
+
+import (
+	"math"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// ChangeEvent is the change of the result of the watched expression.
+type ChangeEvent struct {
+	// Name is the name of the watched expression.
+	Name      string
+	Value     interface{}
+	Err       error
+	PrevValue interface{}
+	PrevErr   error
+}
+
+// Watcher keeps the results of the expressions, and re-evaluates only the expressions affected by the updated variables.
+// The derived variables of the Environment are cached, and re-evaluated only when their dependencies are updated.
+// The Environment must not be changed after the Watcher is created.
+type Watcher struct {
+	mu         sync.Mutex
+	env        *Environment
+	vars       Variables
+	scope      Variables
+	watches    map[string]*watch
+	index      map[string]map[string]bool
+	dependents map[string][]string
+}
+
+type watch struct {
+	evaluator Evaluator
+	value     interface{}
+	err       error
+}
+
+// NewWatcher creates a Watcher with the initial variables, env can be nil if there is no derived variable.
+func NewWatcher(env *Environment, vars Variables) *Watcher {
+	if env == nil {
+		env = NewEnvironment()
+	}
+	w := &Watcher{
+		env:        env,
+		vars:       make(Variables, len(vars)),
+		watches:    make(map[string]*watch),
+		index:      make(map[string]map[string]bool),
+		dependents: make(map[string][]string),
+	}
+	for k, v := range vars {
+		w.vars[k] = v
+	}
+	w.scope = env.Scope(w.vars)
+	for _, name := range env.Names() {
+		for _, dep := range env.Dependencies(name) {
+			w.dependents[dep] = append(w.dependents[dep], name)
+		}
+	}
+	return w
+}
+
+// Watch registers the expression by the name, and returns the current result.
+// The expression registered by the same name is replaced.
+func (w *Watcher) Watch(name string, e Evaluator) (interface{}, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.unwatch(name)
+	wt := &watch{evaluator: e}
+	wt.value, wt.err = e.Eval(w.scope)
+	w.watches[name] = wt
+	for _, dep := range w.dependencies(e) {
+		if w.index[dep] == nil {
+			w.index[dep] = make(map[string]bool)
+		}
+		w.index[dep][name] = true
+	}
+	return wt.value, wt.err
+}
+
+// Unwatch removes the expression of the name.
+func (w *Watcher) Unwatch(name string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.unwatch(name)
+}
+
+func (w *Watcher) unwatch(name string) {
+	wt, ok := w.watches[name]
+	if !ok {
+		return
+	}
+	for _, dep := range w.dependencies(wt.evaluator) {
+		delete(w.index[dep], name)
+	}
+	delete(w.watches, name)
+}
+
+// dependencies returns the variables referenced by the expression, including the dependencies of the derived variables.
+func (w *Watcher) dependencies(e Evaluator) []string {
+	deps := ReferencedVariables(e)
+	for _, name := range deps {
+		deps = append(deps, w.env.Dependencies(name)...)
+	}
+	return deps
+}
+
+// Result returns the current result of the watched expression, ok is false if the name is not watched.
+func (w *Watcher) Result(name string) (value interface{}, err error, ok bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	wt, ok := w.watches[name]
+	if !ok {
+		return nil, nil, false
+	}
+	return wt.value, wt.err, true
+}
+
+// Update sets the variable, and returns the events of the expressions whose results are changed, sorted by the names.
+func (w *Watcher) Update(name string, value interface{}) []ChangeEvent {
+	return w.UpdateAll(Variables{name: value})
+}
+
+// UpdateAll sets the variables at once, and returns the events of the expressions whose results are changed, sorted by the names.
+func (w *Watcher) UpdateAll(vars Variables) []ChangeEvent {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	affected := make(map[string]bool)
+	for name, value := range vars {
+		if old, ok := w.vars[name]; ok && sameValue(old, value) {
+			continue
+		}
+		w.vars[name] = value
+		w.scope[name] = value
+		for _, derived := range w.dependents[name] {
+			if _, ok := w.vars[derived]; !ok {
+				w.scope[derived] = w.env.lazyValue(derived, w.scope)
+			}
+		}
+		for watchName := range w.index[name] {
+			affected[watchName] = true
+		}
+	}
+	names := make([]string, 0, len(affected))
+	for name := range affected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var events []ChangeEvent
+	for _, name := range names {
+		wt := w.watches[name]
+		value, err := wt.evaluator.Eval(w.scope)
+		if sameValue(wt.value, value) && sameError(wt.err, err) {
+			continue
+		}
+		events = append(events, ChangeEvent{
+			Name:      name,
+			Value:     value,
+			Err:       err,
+			PrevValue: wt.value,
+			PrevErr:   wt.err,
+		})
+		wt.value, wt.err = value, err
+	}
+	return events
+}
+
+func sameValue(v1, v2 interface{}) bool {
+	if f1, ok := v1.(float64); ok && math.IsNaN(f1) {
+		f2, ok := v2.(float64)
+		return ok && math.IsNaN(f2)
+	}
+	return reflect.DeepEqual(v1, v2)
+}
+
+func sameError(err1, err2 error) bool {
+	if err1 == nil || err2 == nil {
+		return err1 == err2
+	}
+	return err1.Error() == err2.Error()
+}
@@ -0,0 +1,75 @@
+package evaluator_test
+
+import (
+	"testing"
+
+	"github.com/mashiike/evaluator"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatcher(t *testing.T) {
+	env := evaluator.NewEnvironment()
+	require.NoError(t, env.Define("error_rate", "rate(errors, requests)"))
+	values := []interface{}{1.0, 2.0}
+	w := evaluator.NewWatcher(env, evaluator.Variables{"errors": 1, "requests": 100, "cpu": 50, "values": values})
+
+	watches := map[string]string{
+		"high_error": "error_rate > 0.1",
+		"error_pct":  "error_rate * 100",
+		"high_cpu":   "cpu > 90",
+		"total":      "sum(values)",
+	}
+	for name, expr := range watches {
+		e, err := evaluator.New(expr)
+		require.NoError(t, err)
+		_, err = w.Watch(name, e)
+		require.NoError(t, err)
+	}
+	value, err, ok := w.Result("error_pct")
+	require.True(t, ok)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, value)
+
+	events := w.Update("errors", 2)
+	require.Equal(t, []evaluator.ChangeEvent{
+		{Name: "error_pct", Value: 2.0, PrevValue: 1.0},
+	}, events)
+
+	events = w.Update("errors", 2)
+	require.Empty(t, events, "no change")
+
+	// values is not updated, so sum(values) is not re-evaluated.
+	values[0] = 10.0
+	events = w.UpdateAll(evaluator.Variables{"errors": 20, "cpu": 95})
+	require.Equal(t, []evaluator.ChangeEvent{
+		{Name: "error_pct", Value: 20.0, PrevValue: 2.0},
+		{Name: "high_cpu", Value: true, PrevValue: false},
+		{Name: "high_error", Value: true, PrevValue: false},
+	}, events)
+	value, _, _ = w.Result("total")
+	require.EqualValues(t, 3, value)
+
+	events = w.Update("values", []interface{}{20.0, 2.0})
+	require.Equal(t, []evaluator.ChangeEvent{
+		{Name: "total", Value: 22.0, PrevValue: 3.0},
+	}, events)
+
+	events = w.Update("requests", 0)
+	require.Len(t, events, 2)
+	require.Equal(t, "error_pct", events[0].Name)
+	require.Error(t, events[0].Err)
+	require.Equal(t, "high_error", events[1].Name)
+	require.EqualError(t, events[1].Err, "Eval(`error_rate > 0.1`) v1[<nil>]::<nil> and v2[0.1]::float64 can not `>` comparatable")
+
+	prevErrs := []error{events[0].Err, events[1].Err}
+	events = w.Update("error_rate", 0.5)
+	require.Equal(t, []evaluator.ChangeEvent{
+		{Name: "error_pct", Value: 50.0, PrevErr: prevErrs[0]},
+		{Name: "high_error", Value: true, PrevErr: prevErrs[1]},
+	}, events)
+
+	w.Unwatch("high_cpu")
+	require.Empty(t, w.Update("cpu", 10))
+	_, _, ok = w.Result("high_cpu")
+	require.False(t, ok)
+}